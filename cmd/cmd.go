@@ -65,6 +65,10 @@ var Cli = &cli.Command{
 		subcmdSearch,
 		subcmdAdd,
 		subcmdInit,
+		subcmdRemove,
+		subcmdSync,
+		subcmdUpgrade,
+		subcmdOutdated,
 	},
 }
 
@@ -19,16 +19,18 @@ package cmd
 import (
 	"context"
 	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
 
 	"lucy/tools"
 
 	"github.com/urfave/cli/v3"
 	"lucy/local"
-	"lucy/logger"
-	"lucy/lucyerrors"
 	"lucy/lucytypes"
-	"lucy/remote/modrinth"
-	"lucy/syntax"
+	"lucy/output"
+	"lucy/remote"
 	"lucy/util"
 )
 
@@ -36,12 +38,24 @@ var subcmdAdd = &cli.Command{
 	Name:  "add",
 	Usage: "Add new mods, plugins, or server modules",
 	Flags: []cli.Flag{
+		packageListFlag(),
+		sourceFlag(
+			lucytypes.Modrinth,
+			"To install mods from `SOURCE`, "+
+				"MCDR plugins, Bukkit plugins and GitHub releases always come from their own sources",
+		),
 		&cli.BoolFlag{
 			Name:    "force",
 			Aliases: []string{"f"},
 			Usage:   "Ignore version, dependency, and platform warnings",
 			Value:   false,
 		},
+		&cli.BoolFlag{
+			Name:    "yes",
+			Aliases: []string{"y"},
+			Usage:   "Install without asking for confirmation",
+			Value:   false,
+		},
 	},
 	Action: tools.Decorate(
 		actionAdd,
@@ -57,56 +71,379 @@ var subcmdAdd = &cli.Command{
 //   - Compatible with the server version
 //   - Release version
 //
-// TODO: Version specification
+// Any number of packages can be given, as arguments or in files. Required
+// dependencies are resolved with the same strategy, and the whole install set
+// is confirmed by the user before anything is downloaded. A package that
+// cannot be resolved does not stop the others, the failures are summarized at
+// the end.
+//
+// An exact version or a version constraint can be given, see package syntax.
+// The newest version within a constraint is picked with the same strategy.
+//
+// Mods come from Modrinth, or from the source chosen with --source. Their
+// dependencies come from the same source.
 var actionAdd cli.ActionFunc = func(
 	ctx context.Context,
 	cmd *cli.Command,
 ) error {
 	// TODO: Platform specification
 	// TODO: Platform compatibility check
-	// TODO: Error handling
 
-	p := syntax.Parse(cmd.Args().First())
+	args, err := packageArgs(cmd)
+	if err != nil {
+		return err
+	}
 	serverInfo := local.GetServerInfo()
 
 	if !serverInfo.HasLucy {
 		return errors.New("lucy is not installed, run `lucy init` before downloading mods")
 	}
-
 	if serverInfo.Executable == local.UnknownExecutable {
 		// Case where the server is not detected
 		return errors.New("no executable found, `lucy add` requires a server in current directory")
-	} else if p.Platform == lucytypes.Mcdr && serverInfo.Mcdr == nil {
-		// Case where MCDR is not installed but the user wants to download MCDR plugins
-		// TODO: Deal with this
-		logger.Error(errors.New("no mcdr found, while mcdr plugins requested"))
+	}
+
+	// All packages are resolved together, so a shared dependency is only
+	// installed once. requested maps the ids to resolve back to args.
+	results := make([]packageResult, len(args))
+	var ids []lucytypes.PackageId
+	var requested []int
+	for i, arg := range args {
+		results[i].Specifier = arg.Specifier
+		if results[i].Err = checkAddable(arg, &serverInfo); results[i].Err != nil {
+			continue
+		}
+		ids = append(ids, arg.Id)
+		requested = append(requested, i)
+	}
+	if len(ids) == 0 {
+		return reportResults(results)
+	}
+
+	// Installed mods are often named differently on Modrinth, they are matched
+	// by their slugs as well
+	remote.IdentifyInstalled(&serverInfo)
+	resolution := remote.ResolveInstall(
+		ids,
+		&serverInfo,
+		lucytypes.ParseSource(cmd.String("source")),
+		cmd.Bool("force"),
+	)
+	for j, r := range resolution.Requests {
+		results[requested[j]].Err = r.Err
+		if r.Package != nil {
+			results[requested[j]].Detail = r.Package.Id.StringVersion()
+		}
+	}
+	if len(resolution.Install) == 0 && len(resolution.Satisfied) == 0 {
+		return reportResults(results)
+	}
+	output.Flush(generateResolutionOutput(resolution))
+	if len(resolution.Install) == 0 {
+		return reportResults(results)
+	}
+	if !cmd.Bool("yes") && !output.PromptConfirm(
+		"Install "+strconv.Itoa(len(resolution.Install))+" packages",
+	) {
 		return nil
-	} else if p.Platform != lucytypes.AllPlatform && p.Platform != serverInfo.Executable.Platform {
+	}
+
+	manifest, err := util.ReadManifest()
+	if err != nil {
+		return err
+	}
+	lock, err := util.ReadLock()
+	if err != nil {
+		return err
+	}
+	tx := util.NewTransaction()
+	if err := installPackages(tx, resolution.Install, &serverInfo); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	// The files are only kept if lucy.toml and lucy.lock record them
+	if err := recordResolution(resolution, manifest, lock); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	err = installPythonRequirements(
+		pythonRequirementsOf(resolution.Install),
+		serverInfo.Mcdr,
+		cmd.Bool("yes"),
+	)
+	if reportErr := reportResults(results); reportErr != nil {
+		return reportErr
+	}
+	return err
+}
+
+// checkAddable tells why a package cannot be added to the server, if it
+// cannot.
+func checkAddable(arg packageArg, serverInfo *lucytypes.ServerInfo) error {
+	p := arg.Id
+	switch {
+	case arg.Err != nil:
+		return arg.Err
+	case p.Platform == lucytypes.Mcdr && serverInfo.Mcdr == nil:
+		// Case where MCDR is not installed but the user wants to download MCDR plugins
+		return errors.New("no mcdr found, `lucy add mcdr/...` requires mcdr in current directory")
+	case p.Platform != lucytypes.AllPlatform && p.Platform != lucytypes.Mcdr &&
+		p.Platform != serverInfo.Executable.Platform:
 		// Case where the platform of the mod is different from the server
 		// TODO: Deal with this
-		logger.Error(errors.New("platform mismatch"))
-		return nil
+		return fmt.Errorf("platform mismatch, the server is %s", serverInfo.Executable.Platform.Title())
+	}
+	return nil
+}
+
+// requestOf gives the request that installed was resolved for, or nil if it
+// is a dependency.
+func requestOf(requests []remote.Request, installed lucytypes.Package) *remote.Request {
+	for i, r := range requests {
+		if r.Package != nil && r.Package.Id.Name.Eq(installed.Id.Name) &&
+			r.Package.Id.Platform.Eq(installed.Id.Platform) {
+			return &requests[i]
+		}
 	}
+	return nil
+}
 
-	newestVersion := modrinth.LatestCompatibleVersion(p.Name)
-	downloadFile, err := util.DownloadFile(
-		// Not sure how to deal with multiple files
-		// As the motivation for publishers to provide multiple files is unclear
-		// TODO: Maybe add a prompt to let the user choose
-		newestVersion.Files[0].Url,
-		"mod",
-		newestVersion.Files[0].Filename,
+// installPythonRequirements installs the requirements missing from the Python
+// environment of MCDR with pip, after asking the user unless yes is set.
+func installPythonRequirements(
+	requirements []string,
+	mcdr *lucytypes.McdrInstallation,
+	yes bool,
+) error {
+	if len(requirements) == 0 || mcdr == nil {
+		return nil
+	}
+	missing, err := local.MissingPythonRequirements(requirements)
+	if err != nil {
+		return fmt.Errorf("cannot check python requirements: %w", err)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	output.Flush(
+		&lucytypes.OutputData{
+			Fields: []lucytypes.Field{
+				&output.FieldMultiShortText{
+					Title:     "Missing Python Requirements",
+					Texts:     missing,
+					ShowTotal: true,
+				},
+			},
+		},
 	)
+	if !yes && !output.PromptConfirm(
+		"Install "+strconv.Itoa(len(missing))+" Python packages with pip",
+	) {
+		return nil
+	}
+	if err := local.Python.Install(missing, mcdr.PipInstallExtraArgs); err != nil {
+		return fmt.Errorf("failed at installing python requirements: %w", err)
+	}
+	return nil
+}
+
+// pythonRequirementsOf gives the Python requirements of the packages, without
+// duplicates.
+func pythonRequirementsOf(packages []lucytypes.Package) (requirements []string) {
+	for _, p := range packages {
+		if p.Dependencies == nil {
+			continue
+		}
+		for _, requirement := range p.Dependencies.PythonRequirements {
+			if !slices.Contains(requirements, requirement) {
+				requirements = append(requirements, requirement)
+			}
+		}
+	}
+	return requirements
+}
+
+// installPackages downloads every package before moving any of them into the
+// server, so a failed download leaves the server untouched. The caller decides
+// whether to commit or roll back tx.
+func installPackages(
+	tx *util.Transaction,
+	packages []lucytypes.Package,
+	serverInfo *lucytypes.ServerInfo,
+) error {
+	downloaded, err := downloadPackages(packages)
+	defer discardDownloads(downloaded)
 	if err != nil {
-		if errors.Is(err, lucyerrors.NoLucyError) {
-			logger.Warning(err)
-		} else {
-			logger.Error(errors.New("failed at downloading: " + err.Error()))
-			return nil
+		return err
+	}
+	return installDownloaded(tx, packages, downloaded, serverInfo)
+}
+
+// downloadPackages gives the downloaded file of each package. The files are
+// given even on an error, for the caller to discard them.
+func downloadPackages(packages []lucytypes.Package) (downloaded []string, err error) {
+	downloaded = make([]string, 0, len(packages))
+	for _, p := range packages {
+		if p.Remote == nil || p.Remote.FileUrl == "" {
+			return downloaded, fmt.Errorf("%s has no download url", p.Id.FullString())
+		}
+		f, err := util.DownloadFile(p.Remote, p.Id.Name.String())
+		if err != nil {
+			return downloaded, fmt.Errorf("failed at downloading %s: %w", p.Id.FullString(), err)
+		}
+		downloaded = append(downloaded, f.Name())
+	}
+	return downloaded, nil
+}
+
+// discardDownloads removes the downloaded files that were not moved into the
+// server.
+func discardDownloads(downloaded []string) {
+	for _, f := range downloaded {
+		_ = os.Remove(f)
+	}
+}
+
+// installDownloaded moves the downloaded file of each package into the server.
+func installDownloaded(
+	tx *util.Transaction,
+	packages []lucytypes.Package,
+	downloaded []string,
+	serverInfo *lucytypes.ServerInfo,
+) error {
+	for i, p := range packages {
+		dir, err := packageDirectory(p.Id.Platform, serverInfo)
+		if err != nil {
+			return err
+		}
+		if err := tx.Install(downloaded[i], dir, p.Remote.Filename); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// packageDirectory gives the directory that a package of the platform should
+// be installed into.
+func packageDirectory(
+	platform lucytypes.Platform,
+	serverInfo *lucytypes.ServerInfo,
+) (string, error) {
+	if platform == lucytypes.Mcdr {
+		if serverInfo.Mcdr == nil || len(serverInfo.Mcdr.PluginPaths) == 0 {
+			return "", errors.New("no mcdr plugin directory found")
 		}
+		return serverInfo.Mcdr.PluginPaths[0], nil
+	}
+	if platform.IsBukkit() {
+		if serverInfo.PluginPath == "" {
+			return "", errors.New("no plugin directory found")
+		}
+		return serverInfo.PluginPath, nil
+	}
+	if serverInfo.ModPath == "" {
+		return "", errors.New("no mod directory found")
+	}
+	return serverInfo.ModPath, nil
+}
+
+func generateResolutionOutput(resolution *remote.Resolution) *lucytypes.OutputData {
+	install := make([]string, 0, len(resolution.Install))
+	files := make([]string, 0, len(resolution.Install))
+	for _, p := range resolution.Install {
+		install = append(install, p.Id.FullString())
+		files = append(files, p.Remote.Filename)
+	}
+	satisfied := make([]string, 0, len(resolution.Satisfied))
+	for _, p := range resolution.Satisfied {
+		satisfied = append(satisfied, p.Id.FullString())
+	}
+
+	o := &lucytypes.OutputData{
+		Fields: []lucytypes.Field{
+			&output.FieldMultiShortTextWithAnnot{
+				Title:     "Install",
+				Texts:     install,
+				Annots:    files,
+				ShowTotal: true,
+			},
+			&output.FieldMultiShortText{
+				Title: "Installed",
+				Texts: satisfied,
+			},
+		},
+	}
+	if len(install) == 0 {
+		o.Fields = append(
+			o.Fields,
+			&output.FieldAnnotation{Annotation: "Nothing to install"},
+		)
 	}
 
-	util.MoveFile(downloadFile, serverInfo.ModPath)
+	// Missing ones are installed after the packages
+	if requirements := pythonRequirementsOf(resolution.Install); len(requirements) != 0 {
+		o.Fields = append(
+			o.Fields,
+			&output.FieldMultiShortText{
+				Title: "Python Requirements",
+				Texts: requirements,
+			},
+		)
+	}
+	return o
+}
+
+// recordResolution adds the installed packages to the manifest and pins them
+// in the lock, then writes both. Only the packages the user asked for are
+// recorded as requested, their dependencies are recorded without a version and
+// marked as dependencies in the lock, unless the user asked for them before.
+// If the lock cannot be written, the manifest is written back as it was.
+func recordResolution(
+	resolution *remote.Resolution,
+	manifest *util.LucyManifest,
+	lock *util.LucyLock,
+) error {
+	previous := slices.Clone(manifest.Packages)
+	for _, installed := range resolution.Install {
+		requested := installed.Id
+		requested.Version = lucytypes.AllVersion
+		r := requestOf(resolution.Requests, installed)
+		if r != nil {
+			if _, fromGitHub := r.Id.Name.GitHubRepo(); !fromGitHub {
+				requested = r.Id
+			} else if r.Id.Version != lucytypes.AllVersion {
+				// A release tag is not a version of the package, so the
+				// installed version is requested instead. The repository is
+				// kept in the lock.
+				requested.Version = installed.Id.Version
+			}
+		}
+		requested.Platform = installed.Id.Platform
+		manifest.Add(requested)
 
+		locked, err := util.LockPackage(installed)
+		if err != nil {
+			return err
+		}
+		dependency := r == nil
+		if before := lock.Get(installed.Id); before != nil && !before.Dependency {
+			dependency = false
+		}
+		locked.Dependency = dependency
+		lock.Set(locked)
+	}
+
+	if err := util.WriteManifest(manifest); err != nil {
+		return err
+	}
+	if err := util.WriteLock(lock); err != nil {
+		manifest.Packages = previous
+		return errors.Join(err, util.WriteManifest(manifest))
+	}
 	return nil
 }
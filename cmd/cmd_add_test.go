@@ -0,0 +1,107 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"os"
+	"slices"
+	"testing"
+
+	"lucy/lucytypes"
+	"lucy/remote"
+	"lucy/testutil"
+	"lucy/util"
+)
+
+// testResolution is create asked for with a constraint, and architectury
+// installed as its dependency.
+func testResolution() *remote.Resolution {
+	resolved := func(name lucytypes.PackageName) lucytypes.Package {
+		return lucytypes.Package{
+			Id: lucytypes.PackageId{Platform: lucytypes.Fabric, Name: name, Version: "1.0.0"},
+			Remote: &lucytypes.PackageRemote{
+				Source:   lucytypes.Modrinth,
+				Filename: name.String() + "-1.0.0.jar",
+				Sha512:   "sha512-" + name.String(),
+			},
+		}
+	}
+	create, architectury := resolved("create"), resolved("architectury")
+	return &remote.Resolution{
+		Install: []lucytypes.Package{create, architectury},
+		Requests: []remote.Request{
+			{
+				Id: lucytypes.PackageId{
+					Platform:   lucytypes.Fabric,
+					Name:       "create",
+					Version:    lucytypes.AllVersion,
+					Constraint: &lucytypes.VersionConstraint{Raw: ">=1.0"},
+				},
+				Package: &create,
+			},
+		},
+	}
+}
+
+func TestRecordResolution(t *testing.T) {
+	testutil.ServerDir(t)
+	manifest := &util.LucyManifest{Packages: []string{"fabric/lithium"}}
+	lock := &util.LucyLock{}
+
+	if err := recordResolution(testResolution(), manifest, lock); err != nil {
+		t.Fatal(err)
+	}
+	written, err := util.ReadManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"fabric/lithium", "fabric/create@>=1.0", "fabric/architectury"}; !slices.Equal(written.Packages, want) {
+		t.Errorf("manifest = %v, want %v", written.Packages, want)
+	}
+	writtenLock, err := util.ReadLock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	create := writtenLock.Get(lucytypes.PackageId{Platform: lucytypes.Fabric, Name: "create"})
+	architectury := writtenLock.Get(lucytypes.PackageId{Platform: lucytypes.Fabric, Name: "architectury"})
+	if create == nil || create.Dependency || architectury == nil || !architectury.Dependency {
+		t.Errorf("lock = %+v, want architectury marked as a dependency", writtenLock.Packages)
+	}
+}
+
+func TestRecordResolutionRestoresManifest(t *testing.T) {
+	testutil.ServerDir(t)
+	manifest := &util.LucyManifest{Packages: []string{"fabric/lithium"}}
+	if err := util.WriteManifest(manifest); err != nil {
+		t.Fatal(err)
+	}
+	// The lock cannot be written over a directory
+	if err := os.Mkdir(util.LockFile, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := recordResolution(testResolution(), manifest, &util.LucyLock{}); err == nil {
+		t.Fatal("no error writing the lock")
+	}
+	written, err := util.ReadManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(written.Packages, []string{"fabric/lithium"}) {
+		t.Errorf("manifest = %v, want it as it was", written.Packages)
+	}
+}
@@ -36,17 +36,46 @@ var flagLongOutput = &cli.BoolFlag{
 	Aliases: []string{"l"},
 }
 
-func sourceFlag(absent lucytypes.Source) *cli.StringFlag {
+// packageListFlag reads package specifiers from files, one per line, like the
+// requirement files of pip. The alias is -r like pip, on every command.
+func packageListFlag() *cli.StringSliceFlag {
+	return &cli.StringSliceFlag{
+		Name:    "requirement",
+		Aliases: []string{"r"},
+		Usage:   "Read packages from `FILE`, one per line, can be given more than once",
+	}
+}
+
+// sourceFlag chooses a source, absent being the default. The usage should
+// name the source as `SOURCE`.
+func sourceFlag(absent lucytypes.Source, usage string) *cli.StringFlag {
 	return &cli.StringFlag{
 		Name:    "source",
 		Aliases: []string{"s"},
-		Usage:   "To fetch info from `SOURCE`",
+		Usage:   usage,
 		Value:   absent.String(),
 		Validator: func(s string) error {
-			if s != lucytypes.UnknownSource.String() {
+			if lucytypes.ParseSource(s) == lucytypes.UnknownSource {
 				return errors.New("unknown source " + s)
 			}
 			return nil
 		},
 	}
 }
+
+// sourceOf gives the source chosen with the source flag. If it was not set,
+// MCDR plugins default to the MCDR catalogue, plugins of Bukkit servers and
+// proxies to Hangar, and others to the default value of the flag.
+func sourceOf(cmd *cli.Command, platform lucytypes.Platform) lucytypes.Source {
+	if !cmd.IsSet("source") {
+		switch {
+		case platform == lucytypes.Mcdr:
+			return lucytypes.McdrRepo
+		case platform.IsBukkit(),
+			platform == lucytypes.Velocity,
+			platform == lucytypes.Waterfall:
+			return lucytypes.Hangar
+		}
+	}
+	return lucytypes.ParseSource(cmd.String("source"))
+}
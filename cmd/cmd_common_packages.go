@@ -0,0 +1,158 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+	"lucy/lucyerrors"
+	"lucy/lucytypes"
+	"lucy/output"
+	"lucy/syntax"
+	"lucy/tools"
+)
+
+var ErrorPackagesFailed = errors.New("some packages failed")
+
+// packageArg is a package given to a command, either as an argument or in a
+// file of the requirement flag.
+type packageArg struct {
+	Specifier string
+	Id        lucytypes.PackageId
+	// Err is set if Specifier is not valid, Id is empty then.
+	Err error
+}
+
+// packageArgs gives the arguments, followed by the packages in the files of
+// the requirement flag. Invalid specifiers are kept with their error, so they
+// are reported along with the others.
+func packageArgs(cmd *cli.Command) (args []packageArg, err error) {
+	specifiers := cmd.Args().Slice()
+	for _, file := range cmd.StringSlice("requirement") {
+		listed, err := readPackageList(file)
+		if err != nil {
+			return nil, err
+		}
+		specifiers = append(specifiers, listed...)
+	}
+
+	for _, s := range specifiers {
+		id, err := syntax.Parse(s)
+		args = append(args, packageArg{Specifier: s, Id: id, Err: err})
+	}
+	return args, nil
+}
+
+// readPackageList gives the specifiers in file. Empty lines are skipped, and
+// anything after '#' is a comment.
+func readPackageList(file string) (specifiers []string, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer tools.CloseReader(f, func(error) {})
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			specifiers = append(specifiers, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", file, err)
+	}
+	return specifiers, nil
+}
+
+// packageResult is the outcome of one of the packages given to a command.
+type packageResult struct {
+	Specifier string
+	// Detail is shown next to a package that succeeded.
+	Detail string
+	Err    error
+}
+
+// reportResults prints a summary if more than one package was given. It gives
+// the error of the package if only one was given, or ErrorPackagesFailed if
+// any of several failed.
+func reportResults(results []packageResult) error {
+	var succeeded, details, failed, reasons []string
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, r.Specifier)
+			reasons = append(reasons, r.Err.Error())
+		} else {
+			succeeded = append(succeeded, r.Specifier)
+			details = append(details, r.Detail)
+		}
+	}
+
+	if len(results) > 1 {
+		output.Flush(
+			&lucytypes.OutputData{
+				Fields: []lucytypes.Field{
+					&output.FieldMultiShortTextWithAnnot{
+						Title:     "Succeeded",
+						Texts:     succeeded,
+						Annots:    details,
+						ShowTotal: true,
+					},
+					&output.FieldMultiShortTextWithAnnot{
+						Title:     "Failed",
+						Texts:     failed,
+						Annots:    reasons,
+						ShowTotal: true,
+					},
+				},
+			},
+		)
+	}
+
+	switch {
+	case len(failed) == 0:
+		return nil
+	case len(results) == 1:
+		return results[0].Err
+	}
+	err := fmt.Errorf("%w: %d of %d", ErrorPackagesFailed, len(failed), len(results))
+	if kind := commonKind(results); kind != nil {
+		return lucyerrors.Of(kind, err)
+	}
+	return err
+}
+
+// commonKind gives the kind of the failures in results if they all have the
+// same one, so that the exit code still tells what went wrong.
+func commonKind(results []packageResult) (kind error) {
+	for _, r := range results {
+		if r.Err == nil {
+			continue
+		}
+		k := lucyerrors.KindOf(r.Err)
+		if k == nil || (kind != nil && k != kind) {
+			return nil
+		}
+		kind = k
+	}
+	return kind
+}
@@ -21,6 +21,7 @@ import (
 
 	"github.com/urfave/cli/v3"
 	"lucy/logger"
+	"lucy/util"
 )
 
 // globalFlagsDecorator is a high-order function that appends global flag actions
@@ -36,6 +37,9 @@ func globalFlagsDecorator(f cli.ActionFunc) cli.ActionFunc {
 		if cmd.Bool("debug") {
 			logger.UseDebug()
 		}
+		if err := util.RecoverTransaction(); err != nil {
+			logger.Error(err)
+		}
 		return f(ctx, cmd)
 	}
 }
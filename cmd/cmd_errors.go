@@ -0,0 +1,74 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"lucy/logger"
+	"lucy/lucyerrors"
+	"lucy/tools"
+)
+
+// The exit codes of lucy. Scripts can tell the kind of a failure by them, so
+// they must not be changed once released.
+const (
+	ExitOk = iota
+	ExitError
+	ExitSyntax
+	ExitNotFound
+	ExitIncompatible
+	ExitNetwork
+	ExitRateLimited
+)
+
+var exitCodes = map[error]int{
+	lucyerrors.PackageSyntaxError: ExitSyntax,
+	lucyerrors.NotFoundError:      ExitNotFound,
+	lucyerrors.IncompatibleError:  ExitIncompatible,
+	lucyerrors.NetworkError:       ExitNetwork,
+	lucyerrors.RateLimitedError:   ExitRateLimited,
+}
+
+var hints = map[error]string{
+	lucyerrors.PackageSyntaxError: "packages are written as [platform/]name[@version], e.g. fabric/jade@1.0.0",
+	lucyerrors.NotFoundError:      "check the spelling, or try `lucy search`",
+	lucyerrors.IncompatibleError:  "use --force to install it anyway",
+	lucyerrors.NetworkError:       "check your internet connection and try again",
+	lucyerrors.RateLimitedError:   "the remote limits how often it can be used, wait a while and try again",
+}
+
+// HandleError reports err to the user, with a hint on what to do about it if
+// its kind has one, and gives the exit code for it.
+func HandleError(err error) int {
+	if err == nil {
+		return ExitOk
+	}
+	// Printed once, even with --verbose
+	logger.ErrorToFile(err)
+	_, _ = fmt.Fprintln(os.Stderr, tools.Red("error:"), err)
+
+	kind := lucyerrors.KindOf(err)
+	if hint, ok := hints[kind]; ok {
+		_, _ = fmt.Fprintln(os.Stderr, tools.Dim("hint:"), hint)
+	}
+	if code, ok := exitCodes[kind]; ok {
+		return code
+	}
+	return ExitError
+}
@@ -26,9 +26,8 @@ import (
 	"lucy/logger"
 	"lucy/lucytypes"
 	"lucy/output"
-	"lucy/remote/mcdr"
+	"lucy/remote"
 	"lucy/remote/modrinth"
-	"lucy/syntax"
 	"lucy/tools"
 
 	"github.com/urfave/cli/v3"
@@ -45,7 +44,8 @@ var subcmdInfo = &cli.Command{
 			Usage:   "Print raw Markdown",
 			Value:   false,
 		},
-		sourceFlag(lucytypes.Modrinth),
+		sourceFlag(lucytypes.Modrinth, "To fetch info from `SOURCE`"),
+		packageListFlag(),
 	},
 	Action: tools.Decorate(
 		actionInfo,
@@ -54,30 +54,68 @@ var subcmdInfo = &cli.Command{
 	),
 }
 
+// actionInfo prints the information of every package given, as arguments or
+// in files, one after another.
 var actionInfo cli.ActionFunc = func(
 	ctx context.Context,
 	cmd *cli.Command,
 ) error {
-	p := syntax.Parse(cmd.Args().First())
+	args, err := packageArgs(cmd)
+	if err != nil {
+		return err
+	}
 
-	var multiSourceData []*lucytypes.OutputData
+	results := make([]packageResult, 0, len(args))
+	for _, arg := range args {
+		result := packageResult{Specifier: arg.Specifier, Err: arg.Err}
+		if result.Err == nil {
+			var data []*lucytypes.OutputData
+			data, result.Err = packageInfo(cmd, arg.Id)
+			for _, d := range data {
+				output.Flush(d)
+			}
+		}
+		results = append(results, result)
+	}
+	return reportResults(results)
+}
+
+func packageInfo(cmd *cli.Command, p lucytypes.PackageId) (
+	multiSourceData []*lucytypes.OutputData,
+	err error,
+) {
+	// The remote packages give the same information for every source
+	if source := sourceOf(cmd, p.Platform); source != lucytypes.Modrinth {
+		info := lucytypes.Package{Id: p}
+		var remoteErr error
+		info.Remote, remoteErr = remote.FetchSource(source, p)
+		info.Information, err = remote.GetInformation(source, p)
+		if remoteErr != nil && err != nil {
+			return nil, err
+		}
+		info.Dependencies = packageDependencies(remote.GetDependencies(source, p))
+		return []*lucytypes.OutputData{cInfoOutput(info)}, nil
+	}
 
 	switch p.Platform {
 	case lucytypes.AllPlatform:
 		var packageFromModrinth lucytypes.Package
-		packageFromModrinth.Remote, _ = modrinth.Fetch(p)
-		packageFromModrinth.Information, _ = modrinth.Information(p.Name)
-		packageFromModrinth.Dependencies = modrinth.Dependencies(p)
+		var remoteErr error
+		packageFromModrinth.Remote, remoteErr = modrinth.Fetch(p)
+		packageFromModrinth.Information, err = modrinth.Information(p.Name)
+		if remoteErr != nil && err != nil {
+			return nil, err
+		}
+		packageFromModrinth.Dependencies = packageDependencies(modrinth.Dependencies(p))
 		multiSourceData = append(
 			multiSourceData,
 			cInfoOutput(packageFromModrinth),
 		)
-	case lucytypes.Fabric:
+	case lucytypes.Fabric, lucytypes.Quilt:
 		// TODO: Fabric specific search
 		modrinthProject, err := modrinth.GetProjectByName(p.Name)
 		if err != nil {
-			logger.Warning(err)
-			break
+			return nil, err
 		}
 		multiSourceData = append(
 			multiSourceData,
@@ -85,24 +123,26 @@ var actionInfo cli.ActionFunc = func(
 		)
 	case lucytypes.Forge:
 		// TODO: Forge
-		logger.Fatal(fmt.Errorf("forge is not yet supported"))
-	case lucytypes.Mcdr:
-		mcdrPlugin, err := mcdr.SearchMcdrPluginCatalogue(p.Name)
-		if err != nil {
-			logger.Warning(err)
-			break
-		}
-		multiSourceData = append(
-			multiSourceData,
-			mcdrPluginInfoToInfo(mcdrPlugin),
-		)
+		return nil, fmt.Errorf("forge is not yet supported")
 	}
 
-	for _, data := range multiSourceData {
-		output.Flush(data)
+	if len(multiSourceData) == 0 {
+		return nil, fmt.Errorf("no information found for %s", p.String())
 	}
+	return multiSourceData, nil
+}
 
-	return nil
+// packageDependencies leaves the dependencies out of the information if they
+// cannot be found, as the rest of it is still worth showing.
+func packageDependencies(
+	dependencies *lucytypes.PackageDependencies,
+	err error,
+) *lucytypes.PackageDependencies {
+	if err != nil {
+		logger.Warning(fmt.Errorf("cannot get dependencies: %w", err))
+		return nil
+	}
+	return dependencies
 }
 
 // TODO: Link to newest version
@@ -133,47 +173,25 @@ func modrinthProjectToInfo(source *datatypes.ModrinthProject) *lucytypes.OutputD
 	}
 }
 
-func mcdrPluginInfoToInfo(source *datatypes.McdrPluginInfo) *lucytypes.OutputData {
-	info := &lucytypes.OutputData{
-		Fields: []lucytypes.Field{
-			&output.FieldShortText{
-				Title: "Name",
-				Text:  source.Id,
-			},
-			&output.FieldShortText{
-				Title: "Description",
-				Text:  source.Introduction.EnUs,
-			},
-			&output.FieldMultiShortTextWithAnnot{
-				Title:  "Authors",
-				Texts:  []string{},
-				Annots: []string{},
-			},
-			&output.FieldShortText{
-				Title: "Source Code",
-				Text:  tools.Underline(source.Repository),
-			},
-		},
-	}
-
-	// This is temporary TODO: Use iota for fields instead
-	const authorsField = 2
-	a := info.Fields[authorsField].(*output.FieldMultiShortTextWithAnnot)
-
-	for _, p := range source.Authors {
-		a.Texts = append(a.Texts, p.Name)
-		a.Annots = append(a.Annots, tools.Underline(p.Link))
-	}
-
-	return info
-}
-
 func cInfoOutput(p lucytypes.Package) *lucytypes.OutputData {
-	o := &lucytypes.OutputData{
-		Fields: []lucytypes.Field{
-			&output.FieldAnnotation{
+	o := &lucytypes.OutputData{}
+	if p.Remote != nil {
+		o.Fields = append(
+			o.Fields, &output.FieldAnnotation{
 				Annotation: "(from " + p.Remote.Source.Title() + ")",
 			},
+		)
+	}
+	if p.Information == nil {
+		o.Fields = append(
+			o.Fields, &output.FieldShortText{
+				Title: "Name",
+				Text:  p.Id.Name.String(),
+			},
+		)
+	} else {
+		o.Fields = append(
+			o.Fields,
 			&output.FieldShortText{
 				Title: "Name",
 				Text:  p.Information.Name,
@@ -182,38 +200,32 @@ func cInfoOutput(p lucytypes.Package) *lucytypes.OutputData {
 				Title: "Description",
 				Text:  p.Information.Brief,
 			},
-		},
-	}
-
-	var authorNames []string
-	var authorLinks []string
-	for _, author := range p.Information.Author {
-		authorNames = append(authorNames, author.Name)
-		// TODO: Improve author info annotation format
-		authorLinks = append(authorLinks, author.Url)
+		)
+		for _, url := range p.Information.Urls {
+			o.Fields = append(
+				o.Fields, &output.FieldShortText{
+					Title: url.Name,
+					Text:  tools.Underline(url.Url),
+				},
+			)
+		}
 	}
 
-	for _, url := range p.Information.Urls {
+	if p.Remote != nil {
 		o.Fields = append(
-			o.Fields, &output.FieldShortText{
-				Title: url.Name,
-				Text:  tools.Underline(url.Url),
+			o.Fields, &output.FieldAnnotatedShortText{
+				Title:      "Download",
+				Text:       tools.Underline(p.Remote.FileUrl),
+				Annotation: p.Remote.Filename,
+				NoTab:      true,
 			},
 		)
 	}
 
-	o.Fields = append(
-		o.Fields, &output.FieldAnnotatedShortText{
-			Title:      "Download",
-			Text:       tools.Underline(p.Remote.FileUrl),
-			Annotation: p.Remote.Filename,
-			NoTab:      true,
-		},
-	)
-
 	// TODO: Put current server version on the top
 	// TODO: Hide snapshot versions, except if the current server is using it
-	if !slices.Contains(p.Dependencies.SupportedPlatforms, lucytypes.Mcdr) &&
+	if p.Dependencies != nil &&
+		!slices.Contains(p.Dependencies.SupportedPlatforms, lucytypes.Mcdr) &&
 		(p.Dependencies.SupportedPlatforms != nil || len(p.Dependencies.SupportedPlatforms) != 0) {
 		f := &output.FieldLabels{
 			Title:    "Game Versions",
@@ -227,5 +239,14 @@ func cInfoOutput(p lucytypes.Package) *lucytypes.OutputData {
 		o.Fields = append(o.Fields, f)
 	}
 
+	if p.Dependencies != nil && len(p.Dependencies.PythonRequirements) != 0 {
+		o.Fields = append(
+			o.Fields, &output.FieldMultiShortText{
+				Title: "Python Requirements",
+				Texts: p.Dependencies.PythonRequirements,
+			},
+		)
+	}
+
 	return o
 }
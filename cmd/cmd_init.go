@@ -18,19 +18,139 @@ package cmd
 
 import (
 	"context"
+	"errors"
+	"strconv"
 
 	"github.com/urfave/cli/v3"
+	"lucy/local"
+	"lucy/lucytypes"
+	"lucy/output"
+	"lucy/remote"
+	"lucy/tools"
+	"lucy/util"
 )
 
 var subcmdInit = &cli.Command{
 	Name:   "init",
 	Usage:  "Initialize Lucy on current directory",
-	Action: actionEmpty, // tools.Decorate(actionInit, globalFlagsDecorator),
+	Action: tools.Decorate(actionInit, globalFlagsDecorator),
 }
 
+// actionInit adopts an existing server. Mods and MCDR plugins that are already
+// present are imported into the manifest and the lock as they are, nothing is
+// downloaded or reinstalled.
 var actionInit cli.ActionFunc = func(
 	ctx context.Context,
 	cmd *cli.Command,
 ) error {
+	serverInfo := local.GetServerInfo()
+
+	if serverInfo.HasLucy {
+		return errors.New("lucy is already installed in current directory")
+	}
+	if serverInfo.Executable == local.UnknownExecutable {
+		return errors.New("no executable found, `lucy init` requires a server in current directory")
+	}
+
+	if err := util.InstallLucy(); err != nil {
+		return err
+	}
+
+	config := &util.LucyEnvConfig{
+		Executable:    serverInfo.Executable.Path,
+		Platform:      serverInfo.Executable.Platform,
+		GameVersion:   serverInfo.Executable.GameVersion,
+		LoaderVersion: serverInfo.Executable.LoaderVersion,
+		ModPath:       serverInfo.ModPath,
+	}
+	if serverInfo.PluginPath != "" {
+		config.PluginPaths = append(config.PluginPaths, serverInfo.PluginPath)
+	}
+	if serverInfo.Mcdr != nil {
+		config.PluginPaths = append(config.PluginPaths, serverInfo.Mcdr.PluginPaths...)
+	}
+	if err := util.WriteConfig(config); err != nil {
+		return err
+	}
+
+	manifest, err := util.ReadManifest()
+	if err != nil {
+		return err
+	}
+	lock, err := util.ReadLock()
+	if err != nil {
+		return err
+	}
+	// Mods found on Modrinth are locked with their slugs, which their mod ids
+	// are often not
+	remote.IdentifyInstalled(&serverInfo)
+	imported := installedPackages(&serverInfo)
+	for _, p := range imported {
+		// The manifest leaves the version open, the exact file is pinned by
+		// the lock
+		id := p.Id
+		id.Version = lucytypes.AllVersion
+		manifest.Add(id)
+		locked, err := util.LockPackage(p)
+		if err != nil {
+			return err
+		}
+		// What the other packages require is taken as installed for them
+		locked.Dependency = len(reverseDependencies(imported, p.Id)) != 0
+		lock.Set(locked)
+	}
+	if err := util.WriteManifest(manifest); err != nil {
+		return err
+	}
+	if err := util.WriteLock(lock); err != nil {
+		return err
+	}
+
+	output.Flush(generateInitOutput(config, imported))
 	return nil
 }
+
+func generateInitOutput(
+	config *util.LucyEnvConfig,
+	imported []lucytypes.Package,
+) *lucytypes.OutputData {
+	o := &lucytypes.OutputData{
+		Fields: []lucytypes.Field{
+			&output.FieldAnnotatedShortText{
+				Title:      "Game",
+				Text:       config.GameVersion,
+				Annotation: config.Executable,
+				NoTab:      true,
+			},
+		},
+	}
+
+	if config.Platform != lucytypes.Minecraft {
+		o.Fields = append(
+			o.Fields, &output.FieldAnnotatedShortText{
+				Title:      "Modding",
+				Text:       config.Platform.Title(),
+				Annotation: config.LoaderVersion,
+				NoTab:      true,
+			},
+		)
+	}
+
+	names := make([]string, 0, len(imported))
+	for _, p := range imported {
+		names = append(names, p.Id.FullString())
+	}
+	o.Fields = append(
+		o.Fields,
+		&output.FieldMultiShortText{
+			Title:     "Imported",
+			Texts:     names,
+			ShowTotal: true,
+		},
+		&output.FieldAnnotation{
+			Annotation: "Lucy initialized, " + strconv.Itoa(len(imported)) + " packages recorded in " + util.ManifestFile + " and " + util.LockFile,
+		},
+	)
+
+	return o
+}
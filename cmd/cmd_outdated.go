@@ -0,0 +1,228 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+	"lucy/local"
+	"lucy/logger"
+	"lucy/lucytypes"
+	"lucy/output"
+	"lucy/remote"
+	"lucy/syntax"
+	"lucy/tools"
+	"lucy/util"
+)
+
+var subcmdOutdated = &cli.Command{
+	Name:  "outdated",
+	Usage: "List installed mods and plugins that have newer versions, without changing anything",
+	Flags: []cli.Flag{
+		flagJsonOutput,
+	},
+	Action: tools.Decorate(actionOutdated, globalFlagsDecorator),
+}
+
+// outdatedEntry is a row of the report, it is what gets printed with --json.
+type outdatedEntry struct {
+	Platform         lucytypes.Platform       `json:"platform"`
+	Name             lucytypes.PackageName    `json:"name"`
+	Path             string                   `json:"path"`
+	Installed        lucytypes.PackageVersion `json:"installed"`
+	LatestCompatible lucytypes.PackageVersion `json:"latest_compatible,omitempty"`
+	Latest           lucytypes.PackageVersion `json:"latest,omitempty"`
+	Outdated         bool                     `json:"outdated"`
+	Pinned           bool                     `json:"pinned"`
+	// RequiresGameUpgrade is true if the newest version cannot run on the
+	// server, for MCDR plugins this means a newer MCDR is needed.
+	RequiresGameUpgrade bool `json:"requires_game_upgrade"`
+	// Constraint is the version range from the manifest. A newest version
+	// outside of it is reported as such instead of requiring an upgrade.
+	Constraint string `json:"constraint,omitempty"`
+}
+
+// actionOutdated works without Lucy being installed in the server, pinned
+// packages are only known if it is.
+var actionOutdated cli.ActionFunc = func(
+	ctx context.Context,
+	cmd *cli.Command,
+) error {
+	serverInfo := local.GetServerInfo()
+	if serverInfo.Executable == local.UnknownExecutable && serverInfo.Mcdr == nil {
+		return errors.New("no server found in current directory")
+	}
+	remote.IdentifyInstalled(&serverInfo)
+
+	manifest, err := util.ReadManifest()
+	if err != nil {
+		return err
+	}
+	lock, err := util.ReadLock()
+	if err != nil {
+		return err
+	}
+
+	updates := checkUpdates(installedPackages(&serverInfo), manifest, lock)
+	for i := range updates {
+		updates[i].Newest = newestVersion(&updates[i], manifest, lock)
+	}
+
+	entries := make([]outdatedEntry, 0, len(updates))
+	for _, u := range updates {
+		id := managedId(u.Current, manifest, lock)
+		requested, _ := manifestEntry(manifest, lock.Get(id), id)
+		entries = append(entries, toOutdatedEntry(u, requested))
+	}
+	if cmd.Bool("json") {
+		tools.PrintAsJson(entries)
+	} else {
+		output.Flush(generateOutdatedOutput(entries))
+	}
+	return nil
+}
+
+// newestVersion looks up the newest version of the package for its platform,
+// whatever game version it needs, so a version only for another loader is never
+// reported as requiring a newer game version. Releases on GitHub are already
+// picked by the server, so the newest compatible one is all that is known about
+// them.
+func newestVersion(
+	u *lucytypes.PackageUpdate,
+	manifest *util.LucyManifest,
+	lock *util.LucyLock,
+) *lucytypes.Package {
+	if u.Latest == nil {
+		return nil
+	}
+	if u.Latest.Remote != nil && u.Latest.Remote.Source == lucytypes.GitHub {
+		return u.Latest
+	}
+
+	id := managedId(u.Current, manifest, lock)
+	newest, err := remote.ResolveLocked(
+		lucytypes.PackageId{
+			Platform: id.Platform,
+			Name:     id.Name,
+			Version:  lucytypes.LatestVersion,
+		},
+		knownRemote(u.Current, lock.Get(id)),
+	)
+	if err != nil {
+		logger.Warning(fmt.Errorf("cannot find the newest version of %s: %w", u.Current.Id.StringVersion(), err))
+		return nil
+	}
+	return newest
+}
+
+func toOutdatedEntry(
+	u lucytypes.PackageUpdate,
+	requested lucytypes.PackageId,
+) outdatedEntry {
+	entry := outdatedEntry{
+		Platform:  u.Current.Id.Platform,
+		Name:      u.Current.Id.Name,
+		Installed: u.Current.Id.Version,
+		Outdated:  u.Outdated,
+		Pinned:    u.Pinned,
+	}
+	if requested.Constraint != nil {
+		entry.Constraint = requested.Constraint.Raw
+	}
+	if u.Current.Local != nil {
+		entry.Path = u.Current.Local.Path
+	}
+	if u.Latest != nil {
+		entry.LatestCompatible = u.Latest.Id.Version
+	}
+	if u.Newest != nil {
+		entry.Latest = u.Newest.Id.Version
+		// Without a compatible version, any newer one needs an upgrade
+		entry.RequiresGameUpgrade = isNewer(*u.Newest, u.Current) &&
+			(u.Latest == nil || isNewer(*u.Newest, *u.Latest)) &&
+			syntax.SatisfiesConstraint(requested, u.Newest.Id.Version)
+	}
+	return entry
+}
+
+// heldBack reports whether a newer version exists but is excluded by the
+// version constraint in the manifest.
+func heldBack(e outdatedEntry) bool {
+	return e.Constraint != "" && !e.RequiresGameUpgrade &&
+		e.Latest != "" && e.Latest != e.LatestCompatible
+}
+
+func generateOutdatedOutput(entries []outdatedEntry) *lucytypes.OutputData {
+	var outdated, outdatedAnnots, unknown []string
+	upToDate := 0
+	for _, e := range entries {
+		id := lucytypes.PackageId{Platform: e.Platform, Name: e.Name, Version: e.Installed}
+		switch {
+		case e.LatestCompatible == "" && e.Latest == "":
+			unknown = append(unknown, id.FullString())
+		case !e.Outdated && !e.RequiresGameUpgrade && !heldBack(e):
+			upToDate++
+		default:
+			var versions []string
+			if e.LatestCompatible != "" {
+				versions = append(versions, "compatible "+e.LatestCompatible.String())
+			}
+			if e.Latest != "" {
+				versions = append(versions, "latest "+e.Latest.String())
+			}
+			annot := strings.Join(versions, ", ")
+			if heldBack(e) {
+				annot += " (outside " + e.Constraint + ")"
+			}
+			if e.RequiresGameUpgrade {
+				annot += tools.Ternary(
+					e.Platform == lucytypes.Mcdr,
+					" (requires a newer MCDR)",
+					" (requires a newer game version)",
+				)
+			}
+			if e.Pinned {
+				annot += " (pinned)"
+			}
+			outdated = append(outdated, id.FullString())
+			outdatedAnnots = append(outdatedAnnots, annot)
+		}
+	}
+
+	return &lucytypes.OutputData{
+		Fields: []lucytypes.Field{
+			&output.FieldMultiShortTextWithAnnot{
+				Title:     "Outdated",
+				Texts:     outdated,
+				Annots:    outdatedAnnots,
+				ShowTotal: true,
+			},
+			&output.FieldMultiShortText{
+				Title: "Unknown",
+				Texts: unknown,
+			},
+			&output.FieldShortText{
+				Title: "Up to Date",
+				Text:  fmt.Sprintf("%d packages", upToDate),
+			},
+		},
+	}
+}
@@ -0,0 +1,303 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+	"lucy/local"
+	"lucy/logger"
+	"lucy/lucytypes"
+	"lucy/output"
+	"lucy/remote"
+	"lucy/tools"
+	"lucy/util"
+)
+
+var subcmdRemove = &cli.Command{
+	Name:  "remove",
+	Usage: "Remove mods or plugins, removed files are kept in " + util.TrashPath,
+	Flags: []cli.Flag{
+		packageListFlag(),
+		&cli.BoolFlag{
+			Name:    "force",
+			Aliases: []string{"f"},
+			Usage:   "Remove even if other packages depend on it",
+			Value:   false,
+		},
+		&cli.BoolFlag{
+			Name:    "recursive",
+			Aliases: []string{"R"},
+			Usage:   "Also remove dependencies that are no longer required",
+			Value:   false,
+		},
+	},
+	Action: tools.Decorate(
+		actionRemove,
+		globalFlagsDecorator,
+		helpOnNoInputDecorator,
+	),
+}
+
+// actionRemove removes every package given, as arguments or in files. A
+// package required by another one that stays is not removed, unless forced.
+var actionRemove cli.ActionFunc = func(
+	ctx context.Context,
+	cmd *cli.Command,
+) error {
+	args, err := packageArgs(cmd)
+	if err != nil {
+		return err
+	}
+	serverInfo := local.GetServerInfo()
+
+	if !serverInfo.HasLucy {
+		return errors.New("lucy is not installed, run `lucy init` before removing packages")
+	}
+
+	// Mods added by their slugs are found by them as well
+	remote.IdentifyInstalled(&serverInfo)
+	installed := installedPackages(&serverInfo)
+	results := make([]packageResult, len(args))
+	targets := make([]*lucytypes.Package, len(args))
+	for i, arg := range args {
+		results[i].Specifier = arg.Specifier
+		if arg.Err != nil {
+			results[i].Err = arg.Err
+			continue
+		}
+		if targets[i] = findInstalled(installed, arg.Id); targets[i] == nil {
+			results[i].Err = fmt.Errorf("%s is not installed", arg.Id.String())
+			continue
+		}
+		results[i].Detail = targets[i].Id.StringVersion()
+	}
+
+	// Packages removed together may depend on each other
+	var removing []lucytypes.Package
+	for _, t := range targets {
+		if t != nil {
+			removing = append(removing, *t)
+		}
+	}
+	var toRemove []lucytypes.Package
+	for i, target := range targets {
+		if target == nil {
+			continue
+		}
+		var names []string
+		for _, d := range reverseDependencies(installed, target.Id) {
+			if findInstalled(removing, d.Id) == nil {
+				names = append(names, d.Id.StringVersion())
+			}
+		}
+		if len(names) != 0 {
+			if !cmd.Bool("force") {
+				results[i].Err = fmt.Errorf(
+					"%s is required by %s, use --force to remove anyway",
+					target.Id.StringVersion(),
+					strings.Join(names, ", "),
+				)
+				continue
+			}
+			logger.Warning(
+				fmt.Errorf(
+					"removing %s, which is required by %s",
+					target.Id.StringVersion(),
+					strings.Join(names, ", "),
+				),
+			)
+		}
+		if findInstalled(toRemove, target.Id) == nil {
+			toRemove = append(toRemove, *target)
+		}
+	}
+
+	manifest, err := util.ReadManifest()
+	if err != nil {
+		return err
+	}
+	lock, err := util.ReadLock()
+	if err != nil {
+		return err
+	}
+	if len(toRemove) != 0 && cmd.Bool("recursive") {
+		toRemove = append(toRemove, orphanedDependencies(installed, toRemove, manifest, lock)...)
+	}
+
+	// Either every file is removed or none is
+	tx := util.NewTransaction()
+	var removed, trashed []string
+	for _, r := range toRemove {
+		t, err := tx.Remove(r.Local.Path)
+		if err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("cannot remove %s: %w", r.Local.Path, err)
+		}
+		id := managedId(r, manifest, lock)
+		manifest.Remove(id)
+		lock.Remove(id)
+		removed = append(removed, r.Id.FullString())
+		trashed = append(trashed, t)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if err := util.WriteManifest(manifest); err != nil {
+		return err
+	}
+	if err := util.WriteLock(lock); err != nil {
+		return err
+	}
+
+	output.Flush(
+		&lucytypes.OutputData{
+			Fields: []lucytypes.Field{
+				&output.FieldMultiShortTextWithAnnot{
+					Title:     "Removed",
+					Texts:     removed,
+					Annots:    trashed,
+					ShowTotal: true,
+				},
+			},
+		},
+	)
+
+	return reportResults(results)
+}
+
+// installedPackages gives every package that has a local file, i.e., mods,
+// Bukkit plugins and MCDR plugins.
+func installedPackages(serverInfo *lucytypes.ServerInfo) (installed []lucytypes.Package) {
+	installed = append(installed, serverInfo.Mods...)
+	installed = append(installed, serverInfo.Plugins...)
+	if serverInfo.Mcdr != nil {
+		installed = append(installed, serverInfo.Mcdr.PluginList...)
+	}
+	return installed
+}
+
+// findInstalled gives the installed package called id, by its id or by its
+// slug, see remote.HasName.
+func findInstalled(
+	installed []lucytypes.Package,
+	id lucytypes.PackageId,
+) *lucytypes.Package {
+	for _, p := range installed {
+		if !remote.HasName(p, id.Name) || !p.Id.Platform.Eq(id.Platform) {
+			continue
+		}
+		if id.Version != lucytypes.AllVersion && id.Version != p.Id.Version {
+			continue
+		}
+		return &p
+	}
+	return nil
+}
+
+// reverseDependencies gives the installed packages that list id in their
+// required dependencies.
+func reverseDependencies(
+	installed []lucytypes.Package,
+	id lucytypes.PackageId,
+) (dependents []lucytypes.Package) {
+	for _, p := range installed {
+		if p.Id.Name.Eq(id.Name) || p.Dependencies == nil {
+			continue
+		}
+		for _, required := range p.Dependencies.Required {
+			if required.Name.Eq(id.Name) && required.Platform.Eq(id.Platform) {
+				dependents = append(dependents, p)
+				break
+			}
+		}
+	}
+	return dependents
+}
+
+// orphanedDependencies gives the dependencies of the removed packages that no
+// remaining package requires. It repeats until no more orphans are found, so
+// dependencies of dependencies are also collected. Packages the user asked for
+// are never orphans, see isRequested.
+func orphanedDependencies(
+	installed []lucytypes.Package,
+	removed []lucytypes.Package,
+	manifest *util.LucyManifest,
+	lock *util.LucyLock,
+) (orphans []lucytypes.Package) {
+	isRemoved := func(p lucytypes.Package) bool {
+		for _, r := range slices.Concat(removed, orphans) {
+			if r.Id.Name.Eq(p.Id.Name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for found := true; found; {
+		found = false
+		var remaining []lucytypes.Package
+		for _, p := range installed {
+			if !isRemoved(p) {
+				remaining = append(remaining, p)
+			}
+		}
+
+		for _, r := range slices.Concat(removed, orphans) {
+			if r.Dependencies == nil {
+				continue
+			}
+			for _, required := range r.Dependencies.Required {
+				dependency := findInstalled(remaining, required)
+				if dependency == nil || isRequested(*dependency, manifest, lock) {
+					continue
+				}
+				if len(reverseDependencies(remaining, dependency.Id)) == 0 {
+					orphans = append(orphans, *dependency)
+					found = true
+					break
+				}
+			}
+			if found {
+				break
+			}
+		}
+	}
+
+	return orphans
+}
+
+// isRequested tells whether the user asked for the package, that is, it is in
+// the manifest and was not installed as a dependency of another package.
+func isRequested(
+	p lucytypes.Package,
+	manifest *util.LucyManifest,
+	lock *util.LucyLock,
+) bool {
+	id := managedId(p, manifest, lock)
+	locked := lock.Get(id)
+	if _, listed := manifestEntry(manifest, locked, id); !listed {
+		return false
+	}
+	return locked == nil || !locked.Dependency
+}
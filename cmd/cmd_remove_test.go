@@ -0,0 +1,82 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"testing"
+
+	"lucy/lucytypes"
+	"lucy/util"
+)
+
+func fabricMod(name, slug lucytypes.PackageName, required ...lucytypes.PackageName) lucytypes.Package {
+	p := lucytypes.Package{
+		Id:    lucytypes.PackageId{Platform: lucytypes.Fabric, Name: name, Version: "1.0.0"},
+		Local: &lucytypes.PackageInstallation{Path: "mods/" + name.String() + ".jar"},
+	}
+	if slug != "" {
+		p.Remote = &lucytypes.PackageRemote{Source: lucytypes.Modrinth, Slug: slug}
+	}
+	p.Dependencies = &lucytypes.PackageDependencies{}
+	for _, r := range required {
+		p.Dependencies.Required = append(
+			p.Dependencies.Required,
+			lucytypes.PackageId{Platform: lucytypes.Fabric, Name: r, Version: lucytypes.AllVersion},
+		)
+	}
+	return p
+}
+
+func TestFindInstalledBySlug(t *testing.T) {
+	installed := []lucytypes.Package{fabricMod("fabric", "fabric-api")}
+	id := lucytypes.PackageId{Platform: lucytypes.Fabric, Name: "fabric-api", Version: lucytypes.AllVersion}
+	if p := findInstalled(installed, id); p == nil || p.Id.Name != "fabric" {
+		t.Errorf("findInstalled(fabric-api) = %v, want the fabric jar", p)
+	}
+}
+
+func TestOrphanedDependencies(t *testing.T) {
+	create := fabricMod("create", "", "architectury", "flywheel", "fabric")
+	installed := []lucytypes.Package{
+		create,
+		fabricMod("architectury", ""),
+		fabricMod("flywheel", ""),
+		fabricMod("fabric", "fabric-api"),
+	}
+	// Everything is in the manifest, as add records dependencies too. Only
+	// flywheel was asked for by the user, and fabric-api is locked by its slug.
+	manifest := &util.LucyManifest{
+		Packages: []string{"fabric/create", "fabric/architectury", "fabric/flywheel", "fabric/fabric-api"},
+	}
+	lock := &util.LucyLock{
+		Packages: []util.LockedPackage{
+			{Platform: lucytypes.Fabric, Name: "create", Version: "1.0.0"},
+			{Platform: lucytypes.Fabric, Name: "architectury", Version: "1.0.0", Dependency: true},
+			{Platform: lucytypes.Fabric, Name: "flywheel", Version: "1.0.0"},
+			{Platform: lucytypes.Fabric, Name: "fabric-api", Version: "1.0.0", Dependency: true},
+		},
+	}
+
+	orphans := orphanedDependencies(installed, []lucytypes.Package{create}, manifest, lock)
+	var names []lucytypes.PackageName
+	for _, o := range orphans {
+		names = append(names, o.Id.Name)
+	}
+	if len(names) != 2 || names[0] != "architectury" || names[1] != "fabric" {
+		t.Errorf("orphans = %v, want architectury and fabric", names)
+	}
+}
@@ -22,10 +22,9 @@ import (
 	"strconv"
 
 	"github.com/urfave/cli/v3"
-	"lucy/logger"
 	"lucy/lucytypes"
 	"lucy/output"
-	"lucy/remote/modrinth"
+	"lucy/remote"
 	"lucy/syntax"
 	"lucy/tools"
 )
@@ -34,8 +33,7 @@ var subcmdSearch = &cli.Command{
 	Name:  "search",
 	Usage: "Search for mods and plugins",
 	Flags: []cli.Flag{
-		// TODO: This flag is not yet implemented
-		sourceFlag(lucytypes.Modrinth),
+		sourceFlag(lucytypes.Modrinth, "To fetch info from `SOURCE`"),
 		&cli.StringFlag{
 			Name:    "index",
 			Aliases: []string{"i"},
@@ -68,12 +66,16 @@ var actionSearch cli.ActionFunc = func(
 	_ context.Context,
 	cmd *cli.Command,
 ) error {
-	p := syntax.Parse(cmd.Args().First())
+	p, err := syntax.Parse(cmd.Args().First())
+	if err != nil {
+		return err
+	}
 	_ = cmd.String("index")
 	showClientPackage := cmd.Bool("client")
 	indexBy := lucytypes.SearchIndex(cmd.String("index"))
 
-	res, err := modrinth.Search(
+	res, err := remote.Search(
+		sourceOf(cmd, p.Platform),
 		p,
 		lucytypes.SearchOptions{
 			ShowClientPackage: showClientPackage,
@@ -81,7 +83,7 @@ var actionSearch cli.ActionFunc = func(
 		},
 	)
 	if err != nil {
-		logger.Fatal(err)
+		return err
 	}
 	output.Flush(generateSearchOutput(res, cmd.Bool("long")))
 
@@ -19,11 +19,14 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"slices"
 
 	"github.com/urfave/cli/v3"
 	"lucy/local"
+	"lucy/logger"
 	"lucy/lucytypes"
 	"lucy/output"
+	"lucy/syntax"
 	"lucy/tools"
 )
 
@@ -59,10 +62,14 @@ longOutput bool,
 		Fields: []lucytypes.Field{},
 	}
 
+	gameVersion := data.Executable.GameVersion
+	if v := lucytypes.PackageVersion(gameVersion); syntax.IsMinecraftSnapshot(v) {
+		gameVersion += fmt.Sprintf(" (%s)", syntax.GetMinecraftVersionType(v))
+	}
 	status.Fields = append(
 		status.Fields, &output.FieldAnnotatedShortText{
 			Title:      "Game",
-			Text:       data.Executable.GameVersion,
+			Text:       gameVersion,
 			Annotation: data.Executable.Path,
 			NoTab:      true,
 		},
@@ -106,7 +113,7 @@ longOutput bool,
 	}
 
 	// Modding related fields only shown when modding platform detected
-	if data.Executable.Platform != lucytypes.Minecraft {
+	if data.Executable.Platform != lucytypes.Minecraft && !data.Executable.Platform.IsBukkit() {
 		if len(data.Mods) > 0 {
 			modNames := make([]string, 0, len(data.Mods))
 			modPaths := make([]string, 0, len(modNames))
@@ -143,6 +150,36 @@ longOutput bool,
 		}
 	}
 
+	if data.Executable.Platform.IsBukkit() {
+		pluginNames := make([]string, 0, len(data.Plugins))
+		pluginPaths := make([]string, 0, len(data.Plugins))
+		for _, plugin := range data.Plugins {
+			pluginNames = append(
+				pluginNames,
+				tools.Ternary(
+					longOutput,
+					plugin.Id.FullString(),
+					plugin.Id.StringVersion(),
+				),
+			)
+			pluginPaths = append(
+				pluginPaths,
+				tools.Ternary(longOutput, plugin.Local.Path, ""),
+			)
+		}
+		if len(pluginNames) == 0 {
+			pluginNames = append(pluginNames, tools.Dim("(None)"))
+		}
+		status.Fields = append(
+			status.Fields, &output.FieldMultiShortTextWithAnnot{
+				Title:     "Plugins",
+				Texts:     pluginNames,
+				Annots:    pluginPaths,
+				ShowTotal: len(data.Plugins) > 0,
+			},
+		)
+	}
+
 	if data.Mcdr != nil {
 		pluginNames := make([]string, 0, len(data.Mcdr.PluginList))
 		pluginPaths := make([]string, 0, len(data.Mcdr.PluginList))
@@ -168,8 +205,59 @@ longOutput bool,
 				ShowTotal: true,
 			},
 		)
+		status.Fields = append(
+			status.Fields,
+			pythonRequirementsField(data.Mcdr.PluginList)...,
+		)
 
 	}
 
 	return status
 }
+
+// pythonRequirementsField lists the Python requirements of the MCDR plugins,
+// each annotated with the plugin needing it. Requirements missing from the
+// Python environment of MCDR are marked.
+func pythonRequirementsField(plugins []lucytypes.Package) []lucytypes.Field {
+	var requirements, requiredBy []string
+	for _, plugin := range plugins {
+		if plugin.Dependencies == nil {
+			continue
+		}
+		for _, requirement := range plugin.Dependencies.PythonRequirements {
+			requirements = append(requirements, requirement)
+			requiredBy = append(requiredBy, plugin.Id.Name.String())
+		}
+	}
+	if len(requirements) == 0 {
+		return nil
+	}
+
+	missing, err := local.MissingPythonRequirements(requirements)
+	if err != nil {
+		logger.Debug("cannot check python requirements: " + err.Error())
+	}
+	for i, requirement := range requirements {
+		switch {
+		case err != nil:
+			requiredBy[i] += " (Unknown)"
+		case slices.Contains(missing, requirement):
+			requiredBy[i] += " (Missing)"
+		}
+	}
+	fields := []lucytypes.Field{
+		&output.FieldMultiShortTextWithAnnot{
+			Title:  "Python Requirements",
+			Texts:  requirements,
+			Annots: requiredBy,
+		},
+	}
+	if len(missing) != 0 {
+		fields = append(
+			fields, &output.FieldAnnotation{
+				Annotation: "Install the missing ones in the Python environment of MCDR",
+			},
+		)
+	}
+	return fields
+}
@@ -0,0 +1,363 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+	"lucy/local"
+	"lucy/logger"
+	"lucy/lucytypes"
+	"lucy/output"
+	"lucy/remote"
+	"lucy/syntax"
+	"lucy/tools"
+	"lucy/util"
+)
+
+var subcmdSync = &cli.Command{
+	Name:  "sync",
+	Usage: "Make installed mods and plugins match " + util.LockFile,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Only print the plan, do not change anything",
+			Value: false,
+		},
+		&cli.BoolFlag{
+			Name:  "frozen",
+			Usage: "Fail if " + util.LockFile + " is not up to date with " + util.ManifestFile,
+			Value: false,
+		},
+	},
+	Action: tools.Decorate(actionSync, globalFlagsDecorator),
+}
+
+var actionSync cli.ActionFunc = func(
+	ctx context.Context,
+	cmd *cli.Command,
+) error {
+	serverInfo := local.GetServerInfo()
+	if !serverInfo.HasLucy {
+		return errors.New("lucy is not installed, run `lucy init` before syncing")
+	}
+
+	manifest, err := util.ReadManifest()
+	if err != nil {
+		return err
+	}
+	lock, err := util.ReadLock()
+	if err != nil {
+		return err
+	}
+
+	lockChanged, err := updateLock(manifest, lock, &serverInfo, cmd.Bool("frozen"))
+	if err != nil {
+		return err
+	}
+
+	remote.IdentifyInstalled(&serverInfo)
+	plan := computeSyncPlan(lock, installedPackages(&serverInfo))
+	output.Flush(generateSyncPlanOutput(plan))
+
+	if cmd.Bool("dry-run") {
+		return nil
+	}
+
+	if err := applySyncPlan(plan, &serverInfo); err != nil {
+		return err
+	}
+	if lockChanged {
+		return util.WriteLock(lock)
+	}
+	return nil
+}
+
+// updateLock pins the manifest entries that are not in the lock yet, or whose
+// requested version differs from the locked one, and drops locked packages that
+// are no longer in the manifest. With frozen, any such change is an error.
+func updateLock(
+	manifest *util.LucyManifest,
+	lock *util.LucyLock,
+	serverInfo *lucytypes.ServerInfo,
+	frozen bool,
+) (changed bool, err error) {
+	var wanted []lucytypes.PackageId
+	for _, entry := range manifest.Packages {
+		id, err := syntax.Parse(entry)
+		if err != nil {
+			return false, fmt.Errorf("invalid entry in %s: %w", util.ManifestFile, err)
+		}
+		if id.Platform == lucytypes.AllPlatform {
+			id.Platform = serverInfo.Executable.Platform
+		}
+		wanted = append(wanted, id)
+
+		locked := lock.Get(id)
+		upToDate := locked != nil &&
+			(id.Version == lucytypes.AllVersion || id.Version == locked.Version) &&
+			syntax.SatisfiesConstraint(id, locked.Version)
+		if repo, ok := id.Name.GitHubRepo(); ok {
+			// Entries in the "github:owner/repo@tag" format are matched by the
+			// repository and the release tag kept in the lock
+			var tag string
+			locked, tag = lockedFromGitHub(lock, repo)
+			upToDate = locked != nil &&
+				(id.Version == lucytypes.AllVersion || id.Version == lucytypes.PackageVersion(tag)) &&
+				syntax.SatisfiesConstraint(id, lucytypes.PackageVersion(strings.TrimPrefix(tag, "v")))
+			if locked != nil {
+				wanted[len(wanted)-1].Name = locked.Name
+			}
+		}
+		if upToDate {
+			continue
+		}
+		if frozen {
+			return false, fmt.Errorf("%s is not locked, %s needs to be updated", entry, util.LockFile)
+		}
+		var lockedRemote *lucytypes.PackageRemote
+		if locked != nil {
+			lockedRemote = locked.Remote()
+		}
+		p, err := remote.ResolveLocked(id, lockedRemote)
+		if err != nil {
+			return false, err
+		}
+		wanted[len(wanted)-1].Name = p.Id.Name
+		resolved, err := util.LockPackage(*p)
+		if err != nil {
+			return false, err
+		}
+		if locked != nil {
+			resolved.Dependency = locked.Dependency
+		}
+		lock.Set(resolved)
+		changed = true
+	}
+
+	for _, locked := range lock.Packages {
+		declared := false
+		for _, id := range wanted {
+			if locked.Platform.Eq(id.Platform) && locked.Name.Eq(id.Name) {
+				declared = true
+				break
+			}
+		}
+		if declared {
+			continue
+		}
+		if frozen {
+			id := locked.Id()
+			return false, fmt.Errorf("%s is locked but not in %s", id.FullString(), util.ManifestFile)
+		}
+		lock.Remove(locked.Id())
+		changed = true
+	}
+
+	return changed, nil
+}
+
+// lockedFromGitHub finds the package locked from a release of the repository,
+// and gives the tag of the release.
+func lockedFromGitHub(lock *util.LucyLock, repo string) (*util.LockedPackage, string) {
+	for _, locked := range lock.Packages {
+		lockedRepo, tag, _ := strings.Cut(locked.RemoteId, "@")
+		if lucytypes.ParseSource(locked.Source) == lucytypes.GitHub &&
+			strings.EqualFold(lockedRepo, repo) {
+			return &locked, tag
+		}
+	}
+	return nil, ""
+}
+
+type syncReplacement struct {
+	Installed lucytypes.Package
+	Locked    lucytypes.Package
+}
+
+// syncPlan is the difference between the lock and the files on disk.
+type syncPlan struct {
+	Download []lucytypes.Package
+	Replace  []syncReplacement
+	Remove   []lucytypes.Package
+}
+
+func (p *syncPlan) empty() bool {
+	return len(p.Download) == 0 && len(p.Replace) == 0 && len(p.Remove) == 0
+}
+
+// computeSyncPlan matches the locked packages with the installed ones by their
+// ids or slugs, so IdentifyInstalled should be called first. A matched package
+// is replaced if its file is not the locked one.
+func computeSyncPlan(
+	lock *util.LucyLock,
+	installed []lucytypes.Package,
+) *syncPlan {
+	plan := &syncPlan{}
+
+	// A jar can contain several mods, it is only removed if none of them is
+	// locked, and only once
+	kept := map[string]bool{}
+	for _, locked := range lock.Packages {
+		p := lucytypes.Package{Id: locked.Id(), Remote: locked.Remote()}
+		current := findInstalled(installed, lucytypes.PackageId{
+			Platform: locked.Platform,
+			Name:     locked.Name,
+			Version:  lucytypes.AllVersion,
+		})
+		if current == nil {
+			plan.Download = append(plan.Download, p)
+			continue
+		}
+		kept[current.Local.Path] = true
+		if !isLockedFile(*current, locked) {
+			plan.Replace = append(plan.Replace, syncReplacement{Installed: *current, Locked: p})
+		}
+	}
+
+	for _, p := range installed {
+		if kept[p.Local.Path] {
+			continue
+		}
+		kept[p.Local.Path] = true
+		plan.Remove = append(plan.Remove, p)
+	}
+
+	return plan
+}
+
+// isLockedFile tells whether the installed file is the one in the lock, by its
+// hash, or by its name if the lock has no hash. An entry with neither, such as
+// one imported by hand, is compared by version.
+func isLockedFile(installed lucytypes.Package, locked util.LockedPackage) bool {
+	if locked.Sha512 != "" {
+		digest, err := util.FileSha512(installed.Local.Path)
+		if err == nil {
+			return strings.EqualFold(digest, locked.Sha512)
+		}
+		logger.Warning(err)
+	}
+	if locked.Filename != "" {
+		return path.Base(installed.Local.Path) == locked.Filename
+	}
+	lockedId := locked.Id()
+	c, err := syntax.ComparePackageVersions(&installed.Id, &lockedId)
+	if err != nil {
+		return installed.Id.Version == locked.Version
+	}
+	return c == 0
+}
+
+func generateSyncPlanOutput(plan *syncPlan) *lucytypes.OutputData {
+	if plan.empty() {
+		return &lucytypes.OutputData{
+			Fields: []lucytypes.Field{
+				&output.FieldAnnotation{Annotation: "Already in sync with " + util.LockFile},
+			},
+		}
+	}
+
+	download := make([]string, 0, len(plan.Download))
+	for _, p := range plan.Download {
+		download = append(download, p.Id.FullString())
+	}
+	replace := make([]string, 0, len(plan.Replace))
+	for _, r := range plan.Replace {
+		replace = append(
+			replace,
+			r.Installed.Id.FullString()+" -> "+r.Locked.Id.Version.String(),
+		)
+	}
+	remove := make([]string, 0, len(plan.Remove))
+	for _, p := range plan.Remove {
+		remove = append(remove, p.Id.FullString())
+	}
+
+	return &lucytypes.OutputData{
+		Fields: []lucytypes.Field{
+			&output.FieldMultiShortText{Title: "Download", Texts: download},
+			&output.FieldMultiShortText{Title: "Replace", Texts: replace},
+			&output.FieldMultiShortText{Title: "Remove", Texts: remove},
+		},
+	}
+}
+
+// applySyncPlan downloads everything first, so nothing in the server is touched
+// if a download fails. Then the removed and replaced files are moved to the
+// trash and the downloaded files are moved in, all in one transaction.
+func applySyncPlan(plan *syncPlan, serverInfo *lucytypes.ServerInfo) (err error) {
+	if plan.empty() {
+		return nil
+	}
+
+	toDownload := append([]lucytypes.Package{}, plan.Download...)
+	toTrash := append([]lucytypes.Package{}, plan.Remove...)
+	for _, r := range plan.Replace {
+		toDownload = append(toDownload, r.Locked)
+		toTrash = append(toTrash, r.Installed)
+	}
+	// Mods locked from the same jar share the file, which is downloaded once
+	seen := map[string]bool{}
+	toDownload = slices.DeleteFunc(
+		toDownload,
+		func(p lucytypes.Package) bool {
+			duplicate := seen[p.Remote.FileUrl]
+			seen[p.Remote.FileUrl] = true
+			return duplicate
+		},
+	)
+	for _, p := range toDownload {
+		if p.Remote.FileUrl == "" {
+			return fmt.Errorf(
+				"%s has no download url in %s, it was imported from a local file",
+				p.Id.FullString(),
+				util.LockFile,
+			)
+		}
+	}
+
+	downloaded, err := downloadPackages(toDownload)
+	defer discardDownloads(downloaded)
+	if err != nil {
+		return err
+	}
+
+	// A jar with several locked mods is replaced once for all of them
+	tx := util.NewTransaction()
+	trashed := map[string]bool{}
+	for _, p := range toTrash {
+		if trashed[p.Local.Path] {
+			continue
+		}
+		trashed[p.Local.Path] = true
+		if _, err := tx.Remove(p.Local.Path); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	if err := installDownloaded(tx, toDownload, downloaded, serverInfo); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"lucy/lucytypes"
+	"lucy/testutil"
+	"lucy/util"
+)
+
+func TestComputeSyncPlan(t *testing.T) {
+	testutil.ServerDir(t)
+	if err := os.Mkdir("mods", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	mod := func(name, slug, version, content string) lucytypes.Package {
+		p := lucytypes.Package{
+			Id:    lucytypes.PackageId{Platform: lucytypes.Fabric, Name: lucytypes.PackageName(name), Version: lucytypes.PackageVersion(version)},
+			Local: &lucytypes.PackageInstallation{Path: path.Join("mods", name+"-"+version+".jar")},
+		}
+		if slug != "" {
+			p.Remote = &lucytypes.PackageRemote{Source: lucytypes.Modrinth, Slug: lucytypes.PackageName(slug)}
+		}
+		if err := os.WriteFile(p.Local.Path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	lockFile := func(name, version, file string) util.LockedPackage {
+		digest, err := util.FileSha512(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return util.LockedPackage{
+			Platform: lucytypes.Fabric,
+			Name:     lucytypes.PackageName(name),
+			Version:  lucytypes.PackageVersion(version),
+			Sha512:   digest,
+		}
+	}
+
+	// Added by its slug, the jar has the mod id fabric
+	fabricApi := mod("fabric", "fabric-api", "0.92.2+1.20.1", "fabric-api")
+	// Rebuilt with the same version, the file is not the locked one
+	lithium := mod("lithium", "", "0.11.2", "lithium")
+	lockedLithium := lockFile("lithium", "0.11.2", lithium.Local.Path)
+	lithium = mod("lithium", "", "0.11.2", "lithium, rebuilt")
+	byHand := mod("krypton", "", "0.2.3", "krypton")
+
+	lock := &util.LucyLock{
+		Packages: []util.LockedPackage{
+			lockFile("fabric-api", "0.92.2+1.20.1", fabricApi.Local.Path),
+			lockedLithium,
+			{Platform: lucytypes.Fabric, Name: "sodium", Version: "0.5.8", Filename: "sodium-0.5.8.jar"},
+		},
+	}
+	plan := computeSyncPlan(lock, []lucytypes.Package{fabricApi, lithium, byHand})
+
+	if len(plan.Download) != 1 || plan.Download[0].Id.Name != "sodium" {
+		t.Errorf("download = %v, want sodium", plan.Download)
+	}
+	if len(plan.Replace) != 1 || plan.Replace[0].Installed.Id.Name != "lithium" {
+		t.Errorf("replace = %v, want lithium", plan.Replace)
+	}
+	if len(plan.Remove) != 1 || plan.Remove[0].Id.Name != "krypton" {
+		t.Errorf("remove = %v, want krypton", plan.Remove)
+	}
+}
@@ -0,0 +1,441 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/urfave/cli/v3"
+	"lucy/local"
+	"lucy/logger"
+	"lucy/lucytypes"
+	"lucy/output"
+	"lucy/remote"
+	"lucy/syntax"
+	"lucy/tools"
+	"lucy/util"
+)
+
+var subcmdUpgrade = &cli.Command{
+	Name:      "upgrade",
+	Usage:     "Upgrade installed packages to their newest compatible versions",
+	ArgsUsage: "[package...]",
+	Flags: []cli.Flag{
+		packageListFlag(),
+		&cli.BoolFlag{
+			Name:    "yes",
+			Aliases: []string{"y"},
+			Usage:   "Upgrade without asking for confirmation",
+			Value:   false,
+		},
+	},
+	Action: tools.Decorate(actionUpgrade, globalFlagsDecorator),
+}
+
+// actionUpgrade checks the packages given, as arguments or in files, or every
+// package that Lucy manages if there is none. Packages pinned to a version in
+// the manifest are reported but never upgraded. A package that is not installed
+// is reported, and the others are still upgraded.
+var actionUpgrade cli.ActionFunc = func(
+	ctx context.Context,
+	cmd *cli.Command,
+) error {
+	args, err := packageArgs(cmd)
+	if err != nil {
+		return err
+	}
+	serverInfo := local.GetServerInfo()
+	if !serverInfo.HasLucy {
+		return errors.New("lucy is not installed, run `lucy init` before upgrading packages")
+	}
+	remote.IdentifyInstalled(&serverInfo)
+
+	manifest, err := util.ReadManifest()
+	if err != nil {
+		return err
+	}
+	lock, err := util.ReadLock()
+	if err != nil {
+		return err
+	}
+
+	targets, results := upgradeTargets(
+		args,
+		installedPackages(&serverInfo),
+		manifest,
+		lock,
+	)
+	if len(targets) == 0 {
+		return reportResults(results)
+	}
+	updates := checkUpdates(targets, manifest, lock)
+	output.Flush(generateUpgradeOutput(updates))
+
+	var upgrades []lucytypes.PackageUpdate
+	for _, u := range updates {
+		if u.Outdated && !u.Pinned {
+			upgrades = append(upgrades, u)
+		}
+	}
+	if len(upgrades) == 0 {
+		return reportResults(results)
+	}
+	if !cmd.Bool("yes") && !output.PromptConfirm(
+		"Upgrade "+strconv.Itoa(len(upgrades))+" packages",
+	) {
+		return nil
+	}
+
+	if err := applyUpgrades(upgrades, &serverInfo); err != nil {
+		return err
+	}
+	if err := recordUpgrades(upgrades, manifest, lock); err != nil {
+		return err
+	}
+	return reportResults(results)
+}
+
+// upgradeTargets gives the installed packages named in args, by their ids or
+// slugs, with a result for each of args. Without args, it gives every installed
+// package that is in the manifest or the lock, so files that were put in the
+// server by hand are left alone.
+func upgradeTargets(
+	args []packageArg,
+	installed []lucytypes.Package,
+	manifest *util.LucyManifest,
+	lock *util.LucyLock,
+) (targets []lucytypes.Package, results []packageResult) {
+	if len(args) != 0 {
+		results = make([]packageResult, len(args))
+		for i, arg := range args {
+			results[i].Specifier = arg.Specifier
+			if arg.Err != nil {
+				results[i].Err = arg.Err
+				continue
+			}
+			id := arg.Id
+			id.Version = lucytypes.AllVersion
+			p := findInstalled(installed, id)
+			if p == nil {
+				results[i].Err = fmt.Errorf("%s is not installed", id.String())
+				continue
+			}
+			results[i].Detail = p.Id.StringVersion()
+			targets = append(targets, *p)
+		}
+		return targets, results
+	}
+
+	// A jar can contain several mods, it is only upgraded once
+	seen := map[string]bool{}
+	for _, p := range installed {
+		if seen[p.Local.Path] {
+			continue
+		}
+		id := managedId(p, manifest, lock)
+		locked := lock.Get(id)
+		if _, listed := manifestEntry(manifest, locked, id); !listed && locked == nil {
+			continue
+		}
+		seen[p.Local.Path] = true
+		targets = append(targets, p)
+	}
+	return targets, nil
+}
+
+// managedId gives the id that Lucy records an installed package by. That is the
+// id found in the package, unless only its slug is in the manifest or the lock,
+// as for a mod added by its slug and identified by the hash of its file.
+func managedId(
+	p lucytypes.Package,
+	manifest *util.LucyManifest,
+	lock *util.LucyLock,
+) lucytypes.PackageId {
+	if p.Remote == nil || p.Remote.Slug == "" || lock.Get(p.Id) != nil {
+		return p.Id
+	}
+	if _, listed := manifestEntry(manifest, nil, p.Id); listed {
+		return p.Id
+	}
+	id := p.Id
+	id.Name = p.Remote.Slug
+	if _, listed := manifestEntry(manifest, nil, id); listed || lock.Get(id) != nil {
+		return id
+	}
+	return p.Id
+}
+
+// manifestEntry gives the package as requested in the manifest, and whether
+// the manifest lists it at all. A package from GitHub is matched by the
+// repository in its lock entry, as it is listed in the "github:owner/repo@tag"
+// format.
+func manifestEntry(
+	manifest *util.LucyManifest,
+	locked *util.LockedPackage,
+	id lucytypes.PackageId,
+) (requested lucytypes.PackageId, listed bool) {
+	for _, entry := range manifest.Packages {
+		requested, err := syntax.Parse(entry)
+		if err != nil {
+			logger.Warning(fmt.Errorf("invalid entry in %s: %w", util.ManifestFile, err))
+			continue
+		}
+		if repo, ok := requested.Name.GitHubRepo(); ok {
+			if locked == nil || lucytypes.ParseSource(locked.Source) != lucytypes.GitHub {
+				continue
+			}
+			lockedRepo, _, _ := strings.Cut(locked.RemoteId, "@")
+			if !strings.EqualFold(repo, lockedRepo) {
+				continue
+			}
+		} else if !requested.Platform.Eq(id.Platform) || !requested.Name.Eq(id.Name) {
+			continue
+		}
+		return requested, true
+	}
+	return lucytypes.PackageId{}, false
+}
+
+// isPinned tells whether a version requested in the manifest fixes the package
+// at that version.
+func isPinned(version lucytypes.PackageVersion) bool {
+	switch version {
+	case "", lucytypes.AllVersion, lucytypes.NoVersion,
+		lucytypes.LatestVersion, lucytypes.LatestCompatibleVersion:
+		return false
+	}
+	return true
+}
+
+// checkUpdates looks up the newest compatible version of every package. A
+// package that cannot be found remotely is reported without a Latest.
+func checkUpdates(
+	packages []lucytypes.Package,
+	manifest *util.LucyManifest,
+	lock *util.LucyLock,
+) (updates []lucytypes.PackageUpdate) {
+	for _, p := range packages {
+		id := managedId(p, manifest, lock)
+		locked := lock.Get(id)
+		requested, _ := manifestEntry(manifest, locked, id)
+		update := lucytypes.PackageUpdate{
+			Current: p,
+			Pinned:  isPinned(requested.Version),
+		}
+
+		latest, err := remote.ResolveLocked(
+			lucytypes.PackageId{
+				Platform:   id.Platform,
+				Name:       id.Name,
+				Version:    lucytypes.AllVersion,
+				Constraint: requested.Constraint,
+			},
+			knownRemote(p, locked),
+		)
+		if err != nil {
+			logger.Warning(fmt.Errorf("cannot check %s for updates: %w", p.Id.StringVersion(), err))
+		} else {
+			update.Latest = latest
+			update.Outdated = isNewer(*latest, p)
+		}
+		updates = append(updates, update)
+	}
+	return updates
+}
+
+// knownRemote is where the package was installed from according to the lock,
+// or where its file was found if it is not locked. The slug found by the file
+// is kept for a lock entry that does not have one, as written before slugs
+// were recorded.
+func knownRemote(p lucytypes.Package, locked *util.LockedPackage) *lucytypes.PackageRemote {
+	if locked == nil {
+		return p.Remote
+	}
+	r := locked.Remote()
+	if r.Slug == "" && p.Remote != nil &&
+		(r.Source == lucytypes.UnknownSource || r.Source == p.Remote.Source) {
+		r.Slug = p.Remote.Slug
+	}
+	return r
+}
+
+// isNewer tells whether latest is newer than current. Versions that cannot be
+// ordered, either by their version strings or by when they were published, are
+// only compared for equality. Versions that only differ in what the comparison
+// ignores, like build metadata or a loader suffix, are not newer.
+func isNewer(latest, current lucytypes.Package) bool {
+	if latest.Id.Version == current.Id.Version {
+		return false
+	}
+	c, err := syntax.ComparePackages(&latest, &current)
+	if err != nil {
+		logger.Debug(err.Error())
+		return false
+	}
+	return c > 0
+}
+
+const changelogSnippetLength = 60
+
+// changelogSnippet gives the first line of text in a changelog, without the
+// markdown heading or list markers in front of it.
+func changelogSnippet(changelog string) string {
+	for _, line := range strings.Split(changelog, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "#*-+> "))
+		if line == "" {
+			continue
+		}
+		if utf8.RuneCountInString(line) > changelogSnippetLength {
+			line = string([]rune(line)[:changelogSnippetLength-3]) + "..."
+		}
+		return line
+	}
+	return ""
+}
+
+func generateUpgradeOutput(updates []lucytypes.PackageUpdate) *lucytypes.OutputData {
+	var upgrade, changelogs, pinned, pinnedAnnots, unknown []string
+	upToDate := 0
+	for _, u := range updates {
+		switch {
+		case u.Latest == nil:
+			unknown = append(unknown, u.Current.Id.FullString())
+		case !u.Outdated:
+			upToDate++
+		case u.Pinned:
+			pinned = append(pinned, u.Current.Id.FullString())
+			pinnedAnnots = append(
+				pinnedAnnots,
+				u.Latest.Id.Version.String()+" is available",
+			)
+		default:
+			upgrade = append(
+				upgrade,
+				u.Current.Id.FullString()+" -> "+u.Latest.Id.Version.String(),
+			)
+			changelogs = append(changelogs, changelogSnippet(u.Latest.Remote.Changelog))
+		}
+	}
+
+	o := &lucytypes.OutputData{
+		Fields: []lucytypes.Field{
+			&output.FieldMultiShortTextWithAnnot{
+				Title:     "Upgrade",
+				Texts:     upgrade,
+				Annots:    changelogs,
+				ShowTotal: true,
+			},
+			&output.FieldMultiShortTextWithAnnot{
+				Title:  "Pinned",
+				Texts:  pinned,
+				Annots: pinnedAnnots,
+			},
+			&output.FieldMultiShortText{
+				Title: "Unknown",
+				Texts: unknown,
+			},
+		},
+	}
+	if len(upgrade) == 0 {
+		o.Fields = append(
+			o.Fields,
+			&output.FieldAnnotation{
+				Annotation: strconv.Itoa(upToDate) + " packages are up to date, nothing to upgrade",
+			},
+		)
+	}
+	return o
+}
+
+// applyUpgrades downloads the new versions before anything in the server is
+// touched, then swaps the old files for them in one transaction.
+func applyUpgrades(
+	upgrades []lucytypes.PackageUpdate,
+	serverInfo *lucytypes.ServerInfo,
+) error {
+	latest := make([]lucytypes.Package, 0, len(upgrades))
+	for _, u := range upgrades {
+		latest = append(latest, *u.Latest)
+	}
+	downloaded, err := downloadPackages(latest)
+	defer discardDownloads(downloaded)
+	if err != nil {
+		return err
+	}
+
+	tx := util.NewTransaction()
+	trashed := map[string]bool{}
+	for _, u := range upgrades {
+		if trashed[u.Current.Local.Path] {
+			continue
+		}
+		trashed[u.Current.Local.Path] = true
+		if _, err := tx.Remove(u.Current.Local.Path); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	if err := installDownloaded(tx, latest, downloaded, serverInfo); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// recordUpgrades pins the new versions in the lock. The manifest only changes
+// for a package that was not in it yet, as an unpinned entry.
+func recordUpgrades(
+	upgrades []lucytypes.PackageUpdate,
+	manifest *util.LucyManifest,
+	lock *util.LucyLock,
+) error {
+	for _, u := range upgrades {
+		// The latest version may have been looked up by its slug or repository,
+		// it is recorded under the key the jar already has, so each jar has
+		// one lock entry
+		id := managedId(u.Current, manifest, lock)
+		latest := *u.Latest
+		latest.Id.Name = id.Name
+		if _, listed := manifestEntry(manifest, lock.Get(id), id); !listed {
+			manifest.Add(
+				lucytypes.PackageId{
+					Platform: latest.Id.Platform,
+					Name:     latest.Id.Name,
+					Version:  lucytypes.AllVersion,
+				},
+			)
+		}
+		locked, err := util.LockPackage(latest)
+		if err != nil {
+			return err
+		}
+		if previous := lock.Get(id); previous != nil {
+			locked.Dependency = previous.Dependency
+		}
+		lock.Set(locked)
+	}
+
+	if err := util.WriteManifest(manifest); err != nil {
+		return err
+	}
+	return util.WriteLock(lock)
+}
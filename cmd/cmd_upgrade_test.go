@@ -0,0 +1,110 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"testing"
+
+	"lucy/lucytypes"
+	"lucy/syntax"
+	"lucy/testutil"
+	"lucy/util"
+)
+
+func TestUpgradeTargetsContinuesPastFailures(t *testing.T) {
+	installed := []lucytypes.Package{
+		{Id: lucytypes.PackageId{Platform: lucytypes.Fabric, Name: "lithium", Version: "0.11.2"}},
+		{Id: lucytypes.PackageId{Platform: lucytypes.Fabric, Name: "sodium", Version: "0.5.8"}},
+	}
+	var args []packageArg
+	for _, s := range []string{"lithium", "krypton", "notaplatform/x", "sodium@0.5.3"} {
+		id, err := syntax.Parse(s)
+		args = append(args, packageArg{Specifier: s, Id: id, Err: err})
+	}
+
+	targets, results := upgradeTargets(args, installed, nil, nil)
+	if len(targets) != 2 || targets[0].Id.Name != "lithium" || targets[1].Id.Name != "sodium" {
+		t.Errorf("targets = %v", targets)
+	}
+	if len(results) != len(args) {
+		t.Fatalf("%d results for %d args", len(results), len(args))
+	}
+	for i, failed := range []bool{false, true, true, false} {
+		if (results[i].Err != nil) != failed {
+			t.Errorf("%s: error = %v", results[i].Specifier, results[i].Err)
+		}
+	}
+	if results[3].Detail != "sodium@0.5.8" {
+		t.Errorf("detail = %q, want the installed version", results[3].Detail)
+	}
+}
+
+func TestUpgradeTargetsBySlug(t *testing.T) {
+	// Identified by its file, the jar of fabric-api has the mod id fabric
+	installed := []lucytypes.Package{fabricMod("fabric", "fabric-api")}
+	id, _ := syntax.Parse("fabric/fabric-api")
+	args := []packageArg{{Specifier: "fabric/fabric-api", Id: id}}
+
+	targets, results := upgradeTargets(args, installed, nil, nil)
+	if results[0].Err != nil {
+		t.Fatal(results[0].Err)
+	}
+	if len(targets) != 1 || targets[0].Id.Name != "fabric" {
+		t.Errorf("targets = %v, want the fabric jar", targets)
+	}
+}
+
+func TestRecordUpgradesKeepsTheLockKey(t *testing.T) {
+	testutil.ServerDir(t)
+	fabricApi := func(version lucytypes.PackageVersion) lucytypes.Package {
+		return lucytypes.Package{
+			Id: lucytypes.PackageId{Platform: lucytypes.Fabric, Name: "fabric", Version: version},
+			Remote: &lucytypes.PackageRemote{
+				Source:   lucytypes.Modrinth,
+				Slug:     "fabric-api",
+				Filename: "fabric-api-" + string(version) + ".jar",
+				Sha512:   "sha512-" + string(version),
+			},
+		}
+	}
+	current := fabricApi("0.92.0")
+	current.Local = &lucytypes.PackageInstallation{Path: "mods/fabric-api-0.92.0.jar"}
+	// Resolved from the lock, the latest version is named by its slug
+	latest := fabricApi("0.92.2")
+	latest.Id.Name = "fabric-api"
+
+	manifest := &util.LucyManifest{Packages: []string{"fabric/fabric"}}
+	locked, err := util.LockPackage(current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock := &util.LucyLock{Packages: []util.LockedPackage{locked}}
+
+	upgrades := []lucytypes.PackageUpdate{{Current: current, Latest: &latest, Outdated: true}}
+	if err := recordUpgrades(upgrades, manifest, lock); err != nil {
+		t.Fatal(err)
+	}
+	if len(lock.Packages) != 1 {
+		t.Fatalf("lock has %d entries, want 1: %v", len(lock.Packages), lock.Packages)
+	}
+	if got := lock.Packages[0]; got.Name != "fabric" || got.Version != "0.92.2" || got.Slug != "fabric-api" {
+		t.Errorf("lock entry = %+v", got)
+	}
+	if len(manifest.Packages) != 1 {
+		t.Errorf("manifest = %v", manifest.Packages)
+	}
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package datatypes
+
+import "gopkg.in/yaml.v3"
+
+// BukkitPluginIdentifier is plugin.yml, see
+// https://docs.papermc.io/paper/dev/plugin-yml
+type BukkitPluginIdentifier struct {
+	Name        string   `yaml:"name"`
+	Version     string   `yaml:"version"`
+	Main        string   `yaml:"main"`
+	Description string   `yaml:"description"`
+	ApiVersion  string   `yaml:"api-version"`
+	Author      string   `yaml:"author"`
+	Authors     []string `yaml:"authors"`
+	Website     string   `yaml:"website"`
+	Depend      []string `yaml:"depend"`
+	SoftDepend  []string `yaml:"softdepend"`
+	LoadBefore  []string `yaml:"loadbefore"`
+}
+
+// PaperPluginIdentifier is paper-plugin.yml, see
+// https://docs.papermc.io/paper/dev/getting-started/paper-plugins
+type PaperPluginIdentifier struct {
+	Name         string                  `yaml:"name"`
+	Version      string                  `yaml:"version"`
+	Main         string                  `yaml:"main"`
+	Description  string                  `yaml:"description"`
+	ApiVersion   string                  `yaml:"api-version"`
+	Authors      []string                `yaml:"authors"`
+	Website      string                  `yaml:"website"`
+	Dependencies PaperPluginDependencies `yaml:"dependencies"`
+}
+
+type PaperPluginDependency struct {
+	Name     string `yaml:"name"`
+	Required *bool  `yaml:"required"`
+}
+
+// PaperPluginDependencies are the dependencies needed when the server runs.
+// Paper's format was first a list of dependencies, then changed to maps of
+// plugin names under "bootstrap" and "server". Both are accepted, and the
+// bootstrap dependencies are left out.
+type PaperPluginDependencies []PaperPluginDependency
+
+func (d *PaperPluginDependencies) UnmarshalYAML(node *yaml.Node) error {
+	var list []PaperPluginDependency
+	if node.Kind == yaml.SequenceNode {
+		if err := node.Decode(&list); err != nil {
+			return err
+		}
+		*d = list
+		return nil
+	}
+
+	var sections struct {
+		Server map[string]PaperPluginDependency `yaml:"server"`
+	}
+	if err := node.Decode(&sections); err != nil {
+		return err
+	}
+	for name, dependency := range sections.Server {
+		dependency.Name = name
+		list = append(list, dependency)
+	}
+	*d = list
+	return nil
+}
@@ -16,6 +16,8 @@ limitations under the License.
 
 package datatypes
 
+import "lucy/tools"
+
 type FabricModIdentifier struct {
 	SchemaVersion int      `json:"schemaVersion"`
 	Id            string   `json:"id"`
@@ -37,9 +39,7 @@ type FabricModIdentifier struct {
 	} `json:"entrypoints"`
 	Mixins        []string `json:"mixins"`
 	AccessWidener string   `json:"accessWidener"`
-	Depends       struct {
-		Minecraft    string `json:"minecraft"`
-		Fabricloader string `json:"fabricloader"`
-		Java         string `json:"java"`
-	} `json:"depends"`
+	// Depends maps mod ids to version expressions. An expression is either a
+	// single string or an array of strings, e.g., "minecraft": ["1.21", "1.21.1"]
+	Depends map[string]tools.StringOrStringSlice `json:"depends"`
 }
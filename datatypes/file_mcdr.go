@@ -18,22 +18,6 @@ package datatypes
 
 import "lucy/tools"
 
-type McdrPluginInfo struct {
-	Id      string `json:"id"`
-	Authors []struct {
-		Name string `json:"name"`
-		Link string `json:"link"`
-	} `json:"authors"`
-	Repository   string   `json:"repository"`
-	Branch       string   `json:"branch"`
-	RelatedPath  string   `json:"related_path"`
-	Labels       []string `json:"labels"`
-	Introduction struct {
-		EnUs string `json:"en_us"`
-		ZhCn string `json:"zh_cn"`
-	} `json:"introduction"`
-}
-
 type McdrPluginIdentifierFile struct {
 	Id          string `json:"id"`
 	Version     string `json:"version"`
@@ -42,10 +26,10 @@ type McdrPluginIdentifierFile struct {
 		EnUs string `json:"en_us"`
 		ZhCn string `json:"zh_cn"`
 	} `json:"description"`
-	Author       tools.StringOrStringSlice `json:"author"`
-	Link         string                    `json:"link"`
-	Dependencies struct {
-		Mcdreforged string `json:"mcdreforged"`
-	} `json:"dependencies"`
-	Resources []string `json:"resources"`
+	Author tools.StringOrStringSlice `json:"author"`
+	Link   string                    `json:"link"`
+	// Dependencies maps a plugin id, or "mcdreforged" for MCDR itself, to a
+	// version requirement.
+	Dependencies map[string]string `json:"dependencies"`
+	Resources    []string          `json:"resources"`
 }
@@ -15,37 +15,44 @@ limitations under the License.
 */
 
 // 1.13+ forge&neoforge
+//
+// Forge reads META-INF/mods.toml, NeoForge reads META-INF/neoforge.mods.toml
+// since 1.20.5 and META-INF/mods.toml before that. The two formats only differ
+// in how a dependency is marked as required.
 package datatypes
 
-type newForgeModIdentifier struct {
-	ModLoader       string `toml:"modLoader"`
-	LoaderVersion   string `toml:"loaderVersion"`
-	IssueTrackerURL string `toml:"issueTrackerURL"`
-	License         string `toml:"license"`
-	Mods            []struct {
-		ModID         string `toml:"modId"`
-		Version       string `toml:"version"`
-		DisplayName   string `toml:"displayName"`
-		ItemIcon      string `toml:"itemIcon"`
-		DisplayURL    string `toml:"displayURL"`
-		UpdateJSONURL string `toml:"updateJSONURL"`
-		LogoFile      string `toml:"logoFile"`
-		Credits       string `toml:"credits"`
-		Authors       string `toml:"authors"`
-		Description   string `toml:"description"`
-	} `toml:"mods"`
-	Dependencies struct {
-		Twilightforest []struct {
-			ModID        string `toml:"modId"`
-			Mandatory    bool   `toml:"mandatory"`
-			VersionRange string `toml:"versionRange"`
-			Ordering     string `toml:"ordering"`
-			Side         string `toml:"side"`
-		} `toml:"twilightforest"`
-	} `toml:"dependencies"`
-	Modproperties struct {
-		Twilightforest struct {
-			ConfiguredBackground string `toml:"configuredBackground"`
-		} `toml:"twilightforest"`
-	} `toml:"modproperties"`
+type NewForgeModIdentifier struct {
+	ModLoader       string             `toml:"modLoader"`
+	LoaderVersion   string             `toml:"loaderVersion"`
+	IssueTrackerURL string             `toml:"issueTrackerURL"`
+	License         string             `toml:"license"`
+	Mods            []NewForgeModEntry `toml:"mods"`
+	// Dependencies maps a mod id in Mods to its [[dependencies.<modid>]] tables.
+	Dependencies map[string][]NewForgeDependency `toml:"dependencies"`
+}
+
+type NewForgeModEntry struct {
+	ModID         string `toml:"modId"`
+	Version       string `toml:"version"`
+	DisplayName   string `toml:"displayName"`
+	ItemIcon      string `toml:"itemIcon"`
+	DisplayURL    string `toml:"displayURL"`
+	UpdateJSONURL string `toml:"updateJSONURL"`
+	LogoFile      string `toml:"logoFile"`
+	Credits       string `toml:"credits"`
+	Authors       string `toml:"authors"`
+	Description   string `toml:"description"`
+}
+
+type NewForgeDependency struct {
+	ModID string `toml:"modId"`
+	// Mandatory is used by Forge and older NeoForge.
+	Mandatory *bool `toml:"mandatory"`
+	// Type is used by NeoForge instead of Mandatory. It is one of "required"
+	// (default), "optional", "incompatible" or "discouraged".
+	Type         string `toml:"type"`
+	VersionRange string `toml:"versionRange"`
+	Ordering     string `toml:"ordering"`
+	// Side is one of "BOTH", "CLIENT" or "SERVER".
+	Side string `toml:"side"`
 }
@@ -17,17 +17,44 @@ limitations under the License.
 // 1.12 and older forge mod, metadata in json
 package datatypes
 
-type oldForgeModIdentifier []struct {
-	Modid        string        `json:"modid"`
-	Name         string        `json:"name"`
-	Description  string        `json:"description"`
-	Version      string        `json:"version"`
-	Mcversion    string        `json:"mcversion"`
-	URL          string        `json:"url"`
-	UpdateURL    string        `json:"updateUrl"`
-	AuthorList   []string      `json:"authorList"`
-	Credits      string        `json:"credits"`
-	LogoFile     string        `json:"logoFile"`
-	Screenshots  []interface{} `json:"screenshots"`
-	Dependencies []interface{} `json:"dependencies"`
+import "encoding/json"
+
+// OldForgeModIdentifier is mcmod.info. The file is either a bare array of mods,
+// or an object with the array under "modList" (modListVersion 2).
+type OldForgeModIdentifier []OldForgeModEntry
+
+type OldForgeModEntry struct {
+	Modid       string        `json:"modid"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Version     string        `json:"version"`
+	Mcversion   string        `json:"mcversion"`
+	URL         string        `json:"url"`
+	UpdateURL   string        `json:"updateUrl"`
+	AuthorList  []string      `json:"authorList"`
+	Credits     string        `json:"credits"`
+	LogoFile    string        `json:"logoFile"`
+	Screenshots []interface{} `json:"screenshots"`
+	// RequiredMods and Dependencies are in the "modid@versionRange" format, the
+	// version range is optional. RequiredMods must be present, Dependencies
+	// only need to load first if they are.
+	RequiredMods []string `json:"requiredMods"`
+	Dependencies []string `json:"dependencies"`
+}
+
+func (m *OldForgeModIdentifier) UnmarshalJSON(data []byte) error {
+	var list []OldForgeModEntry
+	if err := json.Unmarshal(data, &list); err == nil {
+		*m = list
+		return nil
+	}
+
+	var v2 struct {
+		ModList []OldForgeModEntry `json:"modList"`
+	}
+	if err := json.Unmarshal(data, &v2); err != nil {
+		return err
+	}
+	*m = v2.ModList
+	return nil
 }
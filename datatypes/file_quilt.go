@@ -0,0 +1,65 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package datatypes
+
+import "encoding/json"
+
+// QuiltModIdentifier is quilt.mod.json. Only the fields that Lucy uses are
+// included, see https://github.com/QuiltMC/rfcs/blob/main/specification/0002-quilt.mod.json.md
+type QuiltModIdentifier struct {
+	SchemaVersion int `json:"schema_version"`
+	QuiltLoader   struct {
+		Group    string `json:"group"`
+		Id       string `json:"id"`
+		Version  string `json:"version"`
+		Metadata struct {
+			Name        string `json:"name"`
+			Description string `json:"description"`
+		} `json:"metadata"`
+		Depends []QuiltDependency `json:"depends"`
+		Breaks  []QuiltDependency `json:"breaks"`
+	} `json:"quilt_loader"`
+}
+
+// QuiltDependency is written as a mod id string, an object, or an array of
+// either. An array means any one of the listed mods will do, and is stored
+// in AnyOf.
+type QuiltDependency struct {
+	// Id is either "modId" or "mavenGroup:modId".
+	Id string `json:"id"`
+	// Versions is a version specifier string, or an array of them.
+	Versions json.RawMessage   `json:"versions"`
+	Optional bool              `json:"optional"`
+	AnyOf    []QuiltDependency `json:"-"`
+}
+
+func (d *QuiltDependency) UnmarshalJSON(data []byte) error {
+	var id string
+	if err := json.Unmarshal(data, &id); err == nil {
+		d.Id = id
+		return nil
+	}
+
+	var anyOf []QuiltDependency
+	if err := json.Unmarshal(data, &anyOf); err == nil {
+		d.AnyOf = anyOf
+		return nil
+	}
+
+	type plain QuiltDependency
+	return json.Unmarshal(data, (*plain)(d))
+}
@@ -1,15 +1,15 @@
 module lucy
 
-go 1.23.4
+go 1.24
 
 require (
+	github.com/BurntSushi/toml v1.4.0
 	github.com/emirpasic/gods v1.18.1
 	github.com/google/go-github/v50 v50.2.0
 	github.com/manifoldco/promptui v0.9.0
 	github.com/russross/blackfriday/v2 v2.1.0
 	github.com/schollz/progressbar/v3 v3.17.1
 	github.com/urfave/cli/v3 v3.0.0-beta1
-	golang.org/x/mod v0.22.0
 	golang.org/x/sys v0.28.0
 	golang.org/x/term v0.27.0
 	gopkg.in/ini.v1 v1.67.0
@@ -29,11 +29,13 @@ import (
 	"archive/zip"
 	"encoding/json"
 	"errors"
-	"io"
 	"os"
 	"path"
+	"slices"
 	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"gopkg.in/ini.v1"
 
@@ -41,6 +43,7 @@ import (
 	"lucy/logger"
 	"lucy/lucytypes"
 	"lucy/tools"
+	"lucy/util"
 )
 
 // GetServerInfo is the exposed function for external packages to get serverInfo.
@@ -64,23 +67,32 @@ func buildServerInfo() lucytypes.ServerInfo {
 		defer wg.Done()
 		mcdrConfig := getMcdrConfig()
 		if mcdrConfig != nil {
+			// The url is null in the config to use the default
+			catalogueMetaUrl, _ := mcdrConfig.CatalogueMetaUrl.(string)
+			version := getMcdrVersion()
 			mu.Lock()
 			serverInfo.Mcdr = &lucytypes.McdrInstallation{
-				PluginPaths: mcdrConfig.PluginDirectories,
+				Version:          version,
+				PluginPaths:      mcdrConfig.PluginDirectories,
+				CatalogueMetaUrl: catalogueMetaUrl,
+				CatalogueMetaCacheTtl: time.Duration(mcdrConfig.CatalogueMetaCacheTtl) *
+					time.Second,
+				CatalogueMetaFetchTimeout: time.Duration(mcdrConfig.CatalogueMetaFetchTimeout) *
+					time.Second,
+				PipInstallExtraArgs: pipInstallExtraArgs(mcdrConfig.PluginPipInstallExtraArgs),
 			}
 			mu.Unlock()
 		}
 	}()
 
-	// MCDR Plugins
+	// MCDR Plugins, they are put into serverInfo.Mcdr after it is created by the
+	// stage above
+	var mcdrPlugins []lucytypes.Package
 	if getMcdrConfig() != nil {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			plugins := getMcdrPlugins()
-			mu.Lock()
-			serverInfo.Mcdr.PluginList = plugins
-			mu.Unlock()
+			mcdrPlugins = getMcdrPlugins()
 		}()
 	}
 
@@ -124,6 +136,26 @@ func buildServerInfo() lucytypes.ServerInfo {
 		mu.Unlock()
 	}()
 
+	// Plugin Path
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		pluginPath := getServerPluginPath()
+		mu.Lock()
+		serverInfo.PluginPath = pluginPath
+		mu.Unlock()
+	}()
+
+	// Plugin List
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		pluginList := getPlugins()
+		mu.Lock()
+		serverInfo.Plugins = pluginList
+		mu.Unlock()
+	}()
+
 	// Save Path
 	wg.Add(1)
 	go func() {
@@ -165,6 +197,9 @@ func buildServerInfo() lucytypes.ServerInfo {
 	}()
 
 	wg.Wait()
+	if serverInfo.Mcdr != nil {
+		serverInfo.Mcdr.PluginList = mcdrPlugins
+	}
 	return serverInfo
 }
 
@@ -175,7 +210,8 @@ func buildServerInfo() lucytypes.ServerInfo {
 var getServerModPath = tools.Memoize(
 	func() string {
 		exec := getExecutableInfo()
-		if exec.Platform == lucytypes.Fabric || exec.Platform == lucytypes.Forge {
+		switch exec.Platform {
+		case lucytypes.Fabric, lucytypes.Quilt, lucytypes.Forge, lucytypes.Neoforge:
 			return path.Join(getServerWorkPath(), "mods")
 		}
 		return ""
@@ -227,7 +263,7 @@ var getSavePath = tools.Memoize(
 
 var checkHasLucy = tools.Memoize(
 	func() bool {
-		_, err := os.Stat(".lucy")
+		_, err := os.Stat(util.ProgramPath)
 		return err == nil
 	},
 )
@@ -236,10 +272,14 @@ var getMods = tools.Memoize(
 	func() (mods []lucytypes.Package) {
 		path := getServerModPath()
 		jars := findJar(path)
+		platform := getExecutableInfo().Platform
 		for _, jar := range jars {
-			mod := analyzeModJar(jar)
-			if mod != nil {
-				mods = append(mods, *mod)
+			mods = append(mods, analyzeModJar(jar, platform)...)
+		}
+		// Quilt loads Fabric mods as well
+		if platform == lucytypes.Quilt {
+			for i := range mods {
+				mods[i] = asQuiltPackage(mods[i])
 			}
 		}
 		sort.Slice(
@@ -250,25 +290,37 @@ var getMods = tools.Memoize(
 	},
 )
 
-const (
-	fabricModIdentifierFile   = "fabric.mod.json"
-	oldForgeModIdentifierFile = "mcmod.info"
-	newForgeModIdentifierFile = "mods.toml"
-)
+// AnalyzeJar gives the mods or plugins in a jar that is not installed yet, as
+// they would be seen on a server of the platform.
+func AnalyzeJar(file *os.File, platform lucytypes.Platform) []lucytypes.Package {
+	if platform.IsBukkit() {
+		return analyzePluginJar(file, platform)
+	}
+	packages := analyzeModJar(file, platform)
+	if platform == lucytypes.Quilt {
+		for i := range packages {
+			packages[i] = asQuiltPackage(packages[i])
+		}
+	}
+	return packages
+}
 
-// const forgeModIdentifierFile =
-// TODO: forgeModIdentifierFile
+const fabricModIdentifierFile = "fabric.mod.json"
 
-// analyzeModJar is now a single large function, but it will be later split into
-// smaller functions according to different mod loaders. This function will keep
-// serve as an entry point to the mod analysis process.
+// analyzeModJar is the entry point to the mod analysis process. It looks for the
+// identifier file of each mod loader and hands the jar to the matching function.
+// A jar can contain several mods, so several packages can be given.
+//
+// Some jars support several loaders. The identifier of the server's platform is
+// used then, so that the mod is recorded as the server loads it, otherwise the
+// one of the loader that the others are forks of.
 //
 // According to current information, all mod analysis can be summarized into the
 // following process:
 // 1. Check for the identifier file
 // 2. Analyze informative files
 // 3. Fill in the Package struct
-func analyzeModJar(file *os.File) *lucytypes.Package {
+func analyzeModJar(file *os.File, platform lucytypes.Platform) []lucytypes.Package {
 	stat, err := file.Stat()
 	if err != nil {
 		return nil
@@ -278,89 +330,107 @@ func analyzeModJar(file *os.File) *lucytypes.Package {
 		return nil
 	}
 
+	identifiers := map[string]*zip.File{}
 	for _, f := range r.File {
-		// fabric check
-		if f.Name == fabricModIdentifierFile {
-			rr, err := f.Open()
-			data, err := io.ReadAll(rr)
-			if err != nil {
-				return nil
-			}
-			modInfo := &datatypes.FabricModIdentifier{}
-			err = json.Unmarshal(data, modInfo)
-			if err != nil {
-				return nil
-			}
-			p := &lucytypes.Package{
-				Id: lucytypes.PackageId{
-					Platform: lucytypes.Fabric,
-					Name:     lucytypes.PackageName(modInfo.Id),
-					Version:  lucytypes.PackageVersion(modInfo.Version),
-				},
-				Local: &lucytypes.PackageInstallation{
-					Path: file.Name(),
-				},
-				Dependencies: nil, // TODO: This is not yet implemented, because the deps field is an expression, we need to parse it
-			}
-			return p
+		switch f.Name {
+		case quiltModIdentifierFile,
+			fabricModIdentifierFile,
+			neoforgeModIdentifierFile,
+			forgeModIdentifierFile,
+			oldForgeModIdentifierFile:
+			identifiers[f.Name] = f
 		}
+	}
 
-		// forge check
-		// if f.Name == oldForgeModIdentifierFile {
-		// 	rr, err := f.Open()
-		// 	data, err := io.ReadAll(rr)
-		// 	if err != nil {
-		// 		return nil
-		// 	}
-		// 	modInfo := &datatypes.oldForgeModIdentifier{}
-		// 	err = json.Unmarshal(data, modInfo)
-		// 	if err != nil {
-		// 		return nil
-		// 	}
-		// 	p := &lucytypes.Package{
-		// 		Id: lucytypes.PackageId{
-		// 			Platform: lucytypes.oldForge, // notice pkg name
-		// 			Name:     lucytypes.PackageName(modInfo.Id),
-		// 			Version:  lucytypes.PackageVersion(modInfo.Version),
-		// 		},
-		// 		Local: &lucytypes.PackageInstallation{
-		// 			Path: file.Name(),
-		// 		},
-		// 		Dependencies: nil, // TODO: This is not yet implemented, because the deps field is an expression, we need to parse it
-		// 	}
-		// 	return p
-		// }
-
-		// newForge check
-		// if f.Name == newForgeModIdentifierFile {
-		// 	rr, err := f.Open()
-		// 	data, err := io.ReadAll(rr)
-		// 	if err != nil {
-		// 		return nil
-		// 	}
-		// 	modInfo := &datatypes.newForgeModIdentifier{} // notice name
-		//
-		// 	err = json.Unmarshal(data, modInfo)
-		// 	if err != nil {
-		// 		return nil
-		// 	}
-		// 	p := &lucytypes.Package{
-		// 		Id: lucytypes.PackageId{
-		// 			Platform: lucytypes.newForge, // notice pkg name
-		// 			Name:     lucytypes.PackageName(modInfo.Id),
-		// 			Version:  lucytypes.PackageVersion(modInfo.Version),
-		// 		},
-		// 		Local: &lucytypes.PackageInstallation{
-		// 			Path: file.Name(),
-		// 		}
-		// 		Dependencies: nil, // TODO: This is not yet implemented, because the deps field is an expression, we need to parse it
-		// 	}
-		// 	return p
-		// }
+	if f, ok := identifiers[quiltModIdentifierFile]; ok && platform == lucytypes.Quilt {
+		return analyzeQuiltMod(file, f)
+	}
+	if f, ok := identifiers[neoforgeModIdentifierFile]; ok && platform == lucytypes.Neoforge {
+		return analyzeNewForgeMod(file, r, f, lucytypes.Neoforge)
+	}
+	if f, ok := identifiers[fabricModIdentifierFile]; ok {
+		return analyzeFabricMod(file, f)
+	}
+	if f, ok := identifiers[quiltModIdentifierFile]; ok {
+		return analyzeQuiltMod(file, f)
+	}
+	if f, ok := identifiers[forgeModIdentifierFile]; ok {
+		return analyzeNewForgeMod(file, r, f, lucytypes.Forge)
+	}
+	if f, ok := identifiers[neoforgeModIdentifierFile]; ok {
+		return analyzeNewForgeMod(file, r, f, lucytypes.Neoforge)
+	}
+	if f, ok := identifiers[oldForgeModIdentifierFile]; ok {
+		return analyzeOldForgeMod(file, r, f)
+	}
+	return nil
+}
 
+func analyzeFabricMod(file *os.File, identifier *zip.File) []lucytypes.Package {
+	data, err := readZipFile(identifier)
+	if err != nil {
+		return nil
 	}
+	modInfo := &datatypes.FabricModIdentifier{}
+	err = json.Unmarshal(data, modInfo)
+	if err != nil {
+		return nil
+	}
+	p := lucytypes.Package{
+		Id: lucytypes.PackageId{
+			Platform: lucytypes.Fabric,
+			Name:     lucytypes.PackageName(modInfo.Id),
+			Version:  lucytypes.PackageVersion(modInfo.Version),
+		},
+		Local: &lucytypes.PackageInstallation{
+			Path: file.Name(),
+		},
+		Dependencies: fabricModDependencies(modInfo),
+	}
+	return []lucytypes.Package{p}
+}
 
-	return nil
+// fabricNonModDependencies are keys in the "depends" object that refer to the
+// environment rather than to another mod.
+var fabricNonModDependencies = []string{"minecraft", "java", "fabricloader", "fabric-loader"}
+
+// fabricModDependencies only records the ids of the required mods. The version
+// expressions are not parsed yet, so any version is treated as satisfying. The
+// game versions are recorded only if they are exact versions.
+func fabricModDependencies(modInfo *datatypes.FabricModIdentifier) *lucytypes.PackageDependencies {
+	dependencies := &lucytypes.PackageDependencies{
+		SupportedPlatforms: []lucytypes.Platform{lucytypes.Fabric},
+		Required:           []lucytypes.PackageId{},
+	}
+	for _, expression := range modInfo.Depends["minecraft"].Value {
+		if strings.ContainsAny(expression, "<>=~^*xX ") {
+			continue
+		}
+		dependencies.SupportedVersions = append(
+			dependencies.SupportedVersions,
+			lucytypes.PackageVersion(expression),
+		)
+	}
+	for id := range modInfo.Depends {
+		if slices.Contains(fabricNonModDependencies, id) {
+			continue
+		}
+		dependencies.Required = append(
+			dependencies.Required,
+			lucytypes.PackageId{
+				Platform: lucytypes.Fabric,
+				Name:     lucytypes.PackageName(id),
+				Version:  lucytypes.AllVersion,
+			},
+		)
+	}
+	sort.Slice(
+		dependencies.Required,
+		func(i, j int) bool {
+			return dependencies.Required[i].Name < dependencies.Required[j].Name
+		},
+	)
+	return dependencies
 }
 
 // func TJLanalyzeModJar(file *os.File) *lucytypes
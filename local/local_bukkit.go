@@ -0,0 +1,307 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	"archive/zip"
+	"bufio"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"lucy/datatypes"
+	"lucy/logger"
+	"lucy/lucytypes"
+	"lucy/tools"
+)
+
+var getServerPluginPath = tools.Memoize(
+	func() string {
+		if getExecutableInfo().Platform.IsBukkit() {
+			return path.Join(getServerWorkPath(), "plugins")
+		}
+		return ""
+	},
+)
+
+var getPlugins = tools.Memoize(
+	func() (plugins []lucytypes.Package) {
+		pluginPath := getServerPluginPath()
+		if pluginPath == "" {
+			return nil
+		}
+		platform := getExecutableInfo().Platform
+		for _, jar := range findJar(pluginPath) {
+			plugins = append(plugins, analyzePluginJar(jar, platform)...)
+			tools.CloseReader(jar, logger.Warning)
+		}
+		sort.Slice(
+			plugins,
+			func(i, j int) bool { return plugins[i].Id.Name < plugins[j].Id.Name },
+		)
+		return plugins
+	},
+)
+
+const (
+	bukkitPluginIdentifierFile = "plugin.yml"
+	paperPluginIdentifierFile  = "paper-plugin.yml"
+)
+
+// analyzePluginJar labels the plugin with the server's platform, since a plugin
+// for Spigot runs on Paper and Purpur as well. Paper prefers paper-plugin.yml
+// over plugin.yml if a jar has both.
+func analyzePluginJar(file *os.File, platform lucytypes.Platform) []lucytypes.Package {
+	stat, err := file.Stat()
+	if err != nil {
+		return nil
+	}
+	r, err := zip.NewReader(file, stat.Size())
+	if err != nil {
+		return nil
+	}
+
+	identifiers := map[string]*zip.File{}
+	for _, f := range r.File {
+		if f.Name == bukkitPluginIdentifierFile || f.Name == paperPluginIdentifierFile {
+			identifiers[f.Name] = f
+		}
+	}
+
+	paperPlugin, hasPaperPlugin := identifiers[paperPluginIdentifierFile]
+	bukkitPlugin, hasBukkitPlugin := identifiers[bukkitPluginIdentifierFile]
+	switch {
+	case hasPaperPlugin && (platform == lucytypes.Paper || platform == lucytypes.Purpur || !hasBukkitPlugin):
+		if !platform.IsBukkit() {
+			platform = lucytypes.Paper
+		}
+		return analyzePaperPlugin(file, paperPlugin, platform)
+	case hasBukkitPlugin:
+		if !platform.IsBukkit() {
+			platform = lucytypes.Spigot
+		}
+		return analyzeBukkitPlugin(file, bukkitPlugin, platform)
+	}
+	return nil
+}
+
+func analyzeBukkitPlugin(
+	file *os.File,
+	identifier *zip.File,
+	platform lucytypes.Platform,
+) []lucytypes.Package {
+	data, err := readZipFile(identifier)
+	if err != nil {
+		return nil
+	}
+	pluginInfo := &datatypes.BukkitPluginIdentifier{}
+	if err := yaml.Unmarshal(data, pluginInfo); err != nil {
+		return nil
+	}
+
+	dependencies := pluginDependencies(platform, pluginInfo.ApiVersion)
+	for _, name := range pluginInfo.Depend {
+		dependencies.Required = append(dependencies.Required, pluginId(platform, name))
+	}
+	for _, name := range pluginInfo.SoftDepend {
+		dependencies.Optional = append(dependencies.Optional, pluginId(platform, name))
+	}
+	sortPackageIds(dependencies.Required)
+	sortPackageIds(dependencies.Optional)
+
+	return []lucytypes.Package{
+		{
+			Id: lucytypes.PackageId{
+				Platform: platform,
+				Name:     lucytypes.PackageName(pluginInfo.Name),
+				Version:  lucytypes.PackageVersion(pluginInfo.Version),
+			},
+			Local: &lucytypes.PackageInstallation{
+				Path: file.Name(),
+			},
+			Dependencies: dependencies,
+		},
+	}
+}
+
+func analyzePaperPlugin(
+	file *os.File,
+	identifier *zip.File,
+	platform lucytypes.Platform,
+) []lucytypes.Package {
+	data, err := readZipFile(identifier)
+	if err != nil {
+		return nil
+	}
+	pluginInfo := &datatypes.PaperPluginIdentifier{}
+	if err := yaml.Unmarshal(data, pluginInfo); err != nil {
+		return nil
+	}
+
+	dependencies := pluginDependencies(platform, pluginInfo.ApiVersion)
+	for _, d := range pluginInfo.Dependencies {
+		// Dependencies are required unless stated otherwise
+		if d.Required == nil || *d.Required {
+			dependencies.Required = append(dependencies.Required, pluginId(platform, d.Name))
+		} else {
+			dependencies.Optional = append(dependencies.Optional, pluginId(platform, d.Name))
+		}
+	}
+	sortPackageIds(dependencies.Required)
+	sortPackageIds(dependencies.Optional)
+
+	return []lucytypes.Package{
+		{
+			Id: lucytypes.PackageId{
+				Platform: platform,
+				Name:     lucytypes.PackageName(pluginInfo.Name),
+				Version:  lucytypes.PackageVersion(pluginInfo.Version),
+			},
+			Local: &lucytypes.PackageInstallation{
+				Path: file.Name(),
+			},
+			Dependencies: dependencies,
+		},
+	}
+}
+
+// pluginDependencies records api-version as the supported game version. It is
+// the lowest version the plugin supports, e.g. "1.20".
+func pluginDependencies(
+	platform lucytypes.Platform,
+	apiVersion string,
+) *lucytypes.PackageDependencies {
+	dependencies := &lucytypes.PackageDependencies{
+		SupportedPlatforms: []lucytypes.Platform{platform},
+		Required:           []lucytypes.PackageId{},
+	}
+	if apiVersion != "" {
+		dependencies.SupportedVersions = []lucytypes.PackageVersion{
+			lucytypes.PackageVersion(apiVersion),
+		}
+	}
+	return dependencies
+}
+
+func pluginId(platform lucytypes.Platform, name string) lucytypes.PackageId {
+	return lucytypes.PackageId{
+		Platform: platform,
+		Name:     lucytypes.PackageName(name),
+		Version:  lucytypes.AllVersion,
+	}
+}
+
+const (
+	paperclipVersionsList = "META-INF/versions.list"
+	legacyPaperclipPatch  = "patch.json"
+	spigotPomProperties   = "META-INF/maven/org.spigotmc/spigot/pom.properties"
+)
+
+var (
+	bukkitGameVersion = regexp.MustCompile(`\d+\.\d+(?:\.\d+)?`)
+	// bukkitJarName matches jars downloaded from Paper and Purpur, whose names
+	// end with the build number, e.g. paper-1.20.4-435.jar
+	bukkitJarName = regexp.MustCompile(`^(paper|purpur|spigot)-(\d+\.\d+(?:\.\d+)?)(?:-(\d+))?\.jar$`)
+)
+
+// analyzeBukkitServer detects Paper and Purpur by the paperclip launcher that
+// they are shipped in, and Spigot by the jar built by BuildTools. Paperclip
+// lists the server jar it extracts in META-INF/versions.list, e.g.
+//
+//	<sha256>	paper-1.20.4	paper-1.20.4.jar
+//
+// The build number only appears in the name of the downloaded jar.
+func analyzeBukkitServer(file *os.File, r *zip.Reader) (exec *lucytypes.ExecutableInfo) {
+	var versionsList, legacyPatch, spigotPom *zip.File
+	for _, f := range r.File {
+		switch f.Name {
+		case paperclipVersionsList:
+			versionsList = f
+		case legacyPaperclipPatch:
+			legacyPatch = f
+		case spigotPomProperties:
+			spigotPom = f
+		}
+	}
+
+	nameMatch := bukkitJarName.FindStringSubmatch(strings.ToLower(path.Base(file.Name())))
+	switch {
+	case versionsList != nil:
+		data, err := readZipFile(versionsList)
+		if err != nil {
+			return nil
+		}
+		exec = &lucytypes.ExecutableInfo{}
+		line, _, _ := strings.Cut(string(data), "\n")
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil
+		}
+		id := strings.ToLower(strings.Join(fields[1:], " "))
+		exec.Platform = bukkitPlatformFromName(id)
+		exec.GameVersion = bukkitGameVersion.FindString(id)
+	case spigotPom != nil:
+		data, err := readZipFile(spigotPom)
+		if err != nil {
+			return nil
+		}
+		exec = &lucytypes.ExecutableInfo{Platform: lucytypes.Spigot}
+		scanner := bufio.NewScanner(strings.NewReader(string(data)))
+		for scanner.Scan() {
+			if version, found := strings.CutPrefix(scanner.Text(), "version="); found {
+				exec.GameVersion = bukkitGameVersion.FindString(version)
+				exec.LoaderVersion = strings.TrimSpace(version)
+			}
+		}
+	case legacyPatch != nil && nameMatch != nil:
+		exec = &lucytypes.ExecutableInfo{}
+	default:
+		return nil
+	}
+
+	if nameMatch != nil {
+		if exec.Platform == "" || exec.Platform == lucytypes.UnknownPlatform {
+			exec.Platform = bukkitPlatformFromName(nameMatch[1])
+		}
+		if exec.GameVersion == "" {
+			exec.GameVersion = nameMatch[2]
+		}
+		if nameMatch[3] != "" {
+			exec.LoaderVersion = nameMatch[3]
+		}
+	}
+	if exec.Platform == lucytypes.UnknownPlatform {
+		return nil
+	}
+	return exec
+}
+
+func bukkitPlatformFromName(name string) lucytypes.Platform {
+	switch {
+	case strings.Contains(name, "purpur"):
+		return lucytypes.Purpur
+	case strings.Contains(name, "paper"):
+		return lucytypes.Paper
+	case strings.Contains(name, "spigot"):
+		return lucytypes.Spigot
+	}
+	return lucytypes.UnknownPlatform
+}
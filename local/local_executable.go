@@ -22,16 +22,17 @@ import (
 	"io"
 	"os"
 	"path"
+	"slices"
 	"strings"
 
 	"lucy/logger"
 	"lucy/lucytypes"
 	"lucy/output"
 	"lucy/tools"
+	"lucy/util"
 )
 
 // TODO: Improve probe logic, plain executable unpacking do not work well
-// TODO: Research on forge installation
 
 var getExecutableInfo = tools.Memoize(
 	func() *lucytypes.ExecutableInfo {
@@ -45,6 +46,10 @@ var getExecutableInfo = tools.Memoize(
 			}
 			valid = append(valid, exec)
 		}
+		// Forge 1.20.3+ also leaves a shim jar, which was already found above
+		if !slices.ContainsFunc(valid, isForgeExecutable) {
+			valid = append(valid, findForgeInstallations(workPath)...)
+		}
 
 		if len(valid) == 0 {
 			logger.Info("no server under current directory")
@@ -52,6 +57,16 @@ var getExecutableInfo = tools.Memoize(
 		} else if len(valid) == 1 {
 			return valid[0]
 		}
+
+		// Use the executable recorded by `lucy init` if there is one
+		if config, err := util.ReadConfig(); err == nil {
+			for _, exec := range valid {
+				if exec.Path == config.Executable {
+					return exec
+				}
+			}
+		}
+
 		index := output.PromptSelectExecutable(valid)
 		return valid[index]
 	},
@@ -88,16 +103,27 @@ var UnknownExecutable = &lucytypes.ExecutableInfo{
 	Platform:    lucytypes.UnknownPlatform,
 }
 
+func isForgeExecutable(exec *lucytypes.ExecutableInfo) bool {
+	return exec.Platform == lucytypes.Forge || exec.Platform == lucytypes.Neoforge
+}
+
 const (
 	fabricSingleIdentifierFile   = "install.properties"
 	vanillaIdentifierFile        = "version.json"
 	fabricLauncherIdentifierFile = "fabric-server-launch.properties"
 	fabricLauncherManifest       = "META-INF/MANIFEST.MF"
+	quiltLauncherManifest        = "META-INF/MANIFEST.MF"
 )
 
 // analyzeExecutable gives nil if the jar file is invalid. The constant UnknownExecutable
 // is not yet used in the codebase, however still reserved for future use.
 func analyzeExecutable(file *os.File) (exec *lucytypes.ExecutableInfo) {
+	// Forge jars also contain a version.json, so they must be told apart by
+	// their names before the content is checked
+	if exec = analyzeLegacyForgeJar(file); exec != nil {
+		return exec
+	}
+
 	// exec is a nil before an analysis function is called
 	// Anything other than exec.Path is set in the analysis function
 	stat, err := file.Stat()
@@ -109,6 +135,12 @@ func analyzeExecutable(file *os.File) (exec *lucytypes.ExecutableInfo) {
 		return nil
 	}
 
+	// Paperclip also contains a version.json
+	if exec = analyzeBukkitServer(file, reader); exec != nil {
+		exec.Path = file.Name()
+		return exec
+	}
+
 	for _, f := range reader.File {
 		switch f.Name {
 		case fabricSingleIdentifierFile:
@@ -133,6 +165,13 @@ func analyzeExecutable(file *os.File) (exec *lucytypes.ExecutableInfo) {
 		}
 	}
 
+	if exec == nil {
+		for _, f := range reader.File {
+			if f.Name == quiltLauncherManifest {
+				exec = analyzeQuiltLauncher(f)
+			}
+		}
+	}
 	if exec == nil {
 		return
 	}
@@ -0,0 +1,192 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"lucy/lucytypes"
+)
+
+// Forge 1.17+ and NeoForge do not put an executable jar in the server
+// directory. They are started by run.sh or run.bat, which pass an argument file
+// under libraries/ to java, for example:
+//
+//	java @user_jvm_args.txt @libraries/net/minecraftforge/forge/1.20.1-47.2.0/unix_args.txt "$@"
+//
+// Forge and NeoForge for 1.20.1 name their library directory after both the
+// game and loader versions, NeoForge for 1.20.2+ only after its own version.
+
+var forgeStartFiles = []string{"run.sh", "run.bat", "user_jvm_args.txt"}
+
+const (
+	forgeLibraryGroup          = "minecraftforge/forge"
+	neoforgeLibraryGroup       = "neoforged/neoforge"
+	neoforgeLegacyLibraryGroup = "neoforged/forge"
+)
+
+var forgeLibraryReference = regexp.MustCompile(
+	`libraries/net/(` + forgeLibraryGroup + `|` + neoforgeLibraryGroup + `|` + neoforgeLegacyLibraryGroup + `)/([^/\s"]+)/`,
+)
+
+// legacyForgeJar matches the jar of Forge 1.16 and older, e.g.
+// forge-1.12.2-14.23.5.2860.jar, and the shim jar of Forge 1.20.3+. The
+// installer jar is left out.
+var legacyForgeJar = regexp.MustCompile(
+	`^forge-(\d+(?:\.\d+)+)-(\d[\w.+\-]*?)(-universal|-shim)?\.jar$`,
+)
+
+func analyzeLegacyForgeJar(file *os.File) (exec *lucytypes.ExecutableInfo) {
+	match := legacyForgeJar.FindStringSubmatch(path.Base(file.Name()))
+	if match == nil || strings.HasSuffix(match[2], "-installer") {
+		return nil
+	}
+	return &lucytypes.ExecutableInfo{
+		Path:          file.Name(),
+		Platform:      lucytypes.Forge,
+		GameVersion:   match[1],
+		LoaderVersion: match[2],
+	}
+}
+
+// findForgeInstallations gives a Forge or NeoForge installation for every
+// library directory referenced by the start script, or for every library
+// directory present if the script does not reference any.
+func findForgeInstallations(workPath string) (execs []*lucytypes.ExecutableInfo) {
+	startFile := ""
+	for _, name := range forgeStartFiles {
+		if _, err := os.Stat(path.Join(workPath, name)); err == nil {
+			startFile = path.Join(workPath, name)
+			break
+		}
+	}
+	if startFile == "" {
+		return nil
+	}
+
+	for _, script := range forgeStartFiles[:2] {
+		data, err := os.ReadFile(path.Join(workPath, script))
+		if err != nil {
+			continue
+		}
+		for _, match := range forgeLibraryReference.FindAllStringSubmatch(string(data), -1) {
+			exec := analyzeForgeLibrary(workPath, match[1], match[2])
+			exec.Path = path.Join(workPath, script)
+			execs = append(execs, exec)
+		}
+		if len(execs) > 0 {
+			return execs
+		}
+	}
+
+	for _, group := range []string{forgeLibraryGroup, neoforgeLibraryGroup, neoforgeLegacyLibraryGroup} {
+		entries, err := os.ReadDir(path.Join(workPath, "libraries/net", group))
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			exec := analyzeForgeLibrary(workPath, group, entry.Name())
+			exec.Path = path.Join(workPath, "libraries/net", group, entry.Name())
+			execs = append(execs, exec)
+		}
+	}
+	return execs
+}
+
+// analyzeForgeLibrary takes the versions from the directory name first, then
+// from the --fml.* arguments in the argument file if it exists.
+func analyzeForgeLibrary(
+	workPath string,
+	group string,
+	version string,
+) (exec *lucytypes.ExecutableInfo) {
+	exec = &lucytypes.ExecutableInfo{}
+	switch group {
+	case forgeLibraryGroup:
+		exec.Platform = lucytypes.Forge
+		exec.GameVersion, exec.LoaderVersion, _ = strings.Cut(version, "-")
+	case neoforgeLegacyLibraryGroup:
+		exec.Platform = lucytypes.Neoforge
+		exec.GameVersion, exec.LoaderVersion, _ = strings.Cut(version, "-")
+	case neoforgeLibraryGroup:
+		exec.Platform = lucytypes.Neoforge
+		exec.LoaderVersion = version
+		exec.GameVersion = neoforgeGameVersion(version)
+	}
+
+	dir := path.Join(workPath, "libraries/net", group, version)
+	for _, argsFile := range []string{"unix_args.txt", "win_args.txt"} {
+		args := readForgeArgs(path.Join(dir, argsFile))
+		if args == nil {
+			continue
+		}
+		if v := args["--fml.mcVersion"]; v != "" {
+			exec.GameVersion = v
+		}
+		if v := args["--fml.neoForgeVersion"]; v != "" {
+			exec.LoaderVersion = v
+		} else if v := args["--fml.forgeVersion"]; v != "" {
+			exec.LoaderVersion = v
+		}
+		break
+	}
+	return exec
+}
+
+// neoforgeGameVersion follows NeoForge's versioning, where the first two parts
+// are the game version without the leading "1.", e.g. 21.1.77 is for 1.21.1
+// and 21.0.167 is for 1.21.
+func neoforgeGameVersion(version string) string {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return ""
+	}
+	if parts[1] == "0" {
+		return "1." + parts[0]
+	}
+	return "1." + parts[0] + "." + parts[1]
+}
+
+// readForgeArgs reads the "--key value" pairs in an argument file, which has one
+// or more arguments per line. It gives nil if the file cannot be read.
+func readForgeArgs(filename string) map[string]string {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	args := map[string]string{}
+	var fields []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields = append(fields, strings.Fields(scanner.Text())...)
+	}
+	for i := 0; i+1 < len(fields); i++ {
+		if strings.HasPrefix(fields[i], "--") {
+			args[fields[i]] = fields[i+1]
+		}
+	}
+	return args
+}
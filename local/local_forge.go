@@ -0,0 +1,281 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+
+	"lucy/datatypes"
+	"lucy/logger"
+	"lucy/lucytypes"
+	"lucy/syntax"
+)
+
+const (
+	forgeModIdentifierFile    = "META-INF/mods.toml"
+	neoforgeModIdentifierFile = "META-INF/neoforge.mods.toml"
+	oldForgeModIdentifierFile = "mcmod.info"
+	jarManifestFile           = "META-INF/MANIFEST.MF"
+)
+
+// jarVersionPlaceholder is substituted by the loader with Implementation-Version
+// from the jar manifest. Most mods built with ForgeGradle use it.
+const jarVersionPlaceholder = "${file.jarVersion}"
+
+// forgeNonModDependencies are dependency ids that refer to the environment
+// rather than to another mod.
+var forgeNonModDependencies = []string{"minecraft", "forge", "neoforge", "java", "javafml"}
+
+// analyzeNewForgeMod reads a mods.toml or neoforge.mods.toml. A jar can contain
+// several mods, each of them is a package.
+func analyzeNewForgeMod(
+	file *os.File,
+	r *zip.Reader,
+	identifier *zip.File,
+	platform lucytypes.Platform,
+) []lucytypes.Package {
+	data, err := readZipFile(identifier)
+	if err != nil {
+		return nil
+	}
+	modInfo := &datatypes.NewForgeModIdentifier{}
+	if _, err := toml.Decode(string(data), modInfo); err != nil {
+		return nil
+	}
+
+	// NeoForge before 1.20.5 still used mods.toml, it can only be told apart by
+	// its dependency on neoforge
+	if platform == lucytypes.Forge && dependsOnNeoforge(modInfo) {
+		platform = lucytypes.Neoforge
+	}
+
+	var packages []lucytypes.Package
+	for _, mod := range modInfo.Mods {
+		version := mod.Version
+		if version == jarVersionPlaceholder {
+			version = jarImplementationVersion(r)
+		}
+		packages = append(
+			packages,
+			lucytypes.Package{
+				Id: lucytypes.PackageId{
+					Platform: platform,
+					Name:     lucytypes.PackageName(mod.ModID),
+					Version:  lucytypes.PackageVersion(version),
+				},
+				Local: &lucytypes.PackageInstallation{
+					Path: file.Name(),
+				},
+				Dependencies: newForgeModDependencies(
+					modInfo.Dependencies[mod.ModID],
+					platform,
+				),
+			},
+		)
+	}
+	return packages
+}
+
+func dependsOnNeoforge(modInfo *datatypes.NewForgeModIdentifier) bool {
+	for _, dependencies := range modInfo.Dependencies {
+		for _, d := range dependencies {
+			if d.ModID == "neoforge" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// newForgeModDependencies leaves out client-only dependencies, since Lucy only
+// manages servers.
+func newForgeModDependencies(
+	entries []datatypes.NewForgeDependency,
+	platform lucytypes.Platform,
+) *lucytypes.PackageDependencies {
+	dependencies := &lucytypes.PackageDependencies{
+		SupportedPlatforms: []lucytypes.Platform{platform},
+		Required:           []lucytypes.PackageId{},
+	}
+	for _, d := range entries {
+		if strings.EqualFold(d.Side, "CLIENT") {
+			continue
+		}
+		if d.ModID == "minecraft" {
+			if v, _ := dependencyVersion(d.VersionRange); v != lucytypes.AllVersion {
+				dependencies.SupportedVersions = append(dependencies.SupportedVersions, v)
+			}
+			continue
+		}
+		if slices.Contains(forgeNonModDependencies, d.ModID) {
+			continue
+		}
+
+		id := lucytypes.PackageId{
+			Platform: platform,
+			Name:     lucytypes.PackageName(d.ModID),
+		}
+		id.Version, id.Constraint = dependencyVersion(d.VersionRange)
+		switch {
+		case d.Mandatory != nil && *d.Mandatory,
+			d.Mandatory == nil && (d.Type == "" || strings.EqualFold(d.Type, "required")):
+			dependencies.Required = append(dependencies.Required, id)
+		case strings.EqualFold(d.Type, "incompatible"):
+			dependencies.Incompatible = append(dependencies.Incompatible, id)
+		default:
+			dependencies.Optional = append(dependencies.Optional, id)
+		}
+	}
+	sortPackageIds(dependencies.Required)
+	sortPackageIds(dependencies.Optional)
+	sortPackageIds(dependencies.Incompatible)
+	return dependencies
+}
+
+// analyzeOldForgeMod reads a mcmod.info, used by Forge 1.12 and older.
+func analyzeOldForgeMod(
+	file *os.File,
+	r *zip.Reader,
+	identifier *zip.File,
+) []lucytypes.Package {
+	data, err := readZipFile(identifier)
+	if err != nil {
+		return nil
+	}
+	modInfo := datatypes.OldForgeModIdentifier{}
+	if err := json.Unmarshal(data, &modInfo); err != nil {
+		return nil
+	}
+
+	var packages []lucytypes.Package
+	for _, mod := range modInfo {
+		version := mod.Version
+		if version == jarVersionPlaceholder {
+			version = jarImplementationVersion(r)
+		}
+		dependencies := &lucytypes.PackageDependencies{
+			SupportedPlatforms: []lucytypes.Platform{lucytypes.Forge},
+			Required:           []lucytypes.PackageId{},
+		}
+		if mod.Mcversion != "" {
+			dependencies.SupportedVersions = []lucytypes.PackageVersion{
+				lucytypes.PackageVersion(mod.Mcversion),
+			}
+		}
+		for _, required := range mod.RequiredMods {
+			modId, versionRange, _ := strings.Cut(required, "@")
+			if slices.Contains(forgeNonModDependencies, strings.ToLower(modId)) {
+				continue
+			}
+			id := lucytypes.PackageId{
+				Platform: lucytypes.Forge,
+				Name:     lucytypes.PackageName(modId),
+			}
+			id.Version, id.Constraint = dependencyVersion(versionRange)
+			dependencies.Required = append(dependencies.Required, id)
+		}
+		sortPackageIds(dependencies.Required)
+
+		packages = append(
+			packages,
+			lucytypes.Package{
+				Id: lucytypes.PackageId{
+					Platform: lucytypes.Forge,
+					Name:     lucytypes.PackageName(mod.Modid),
+					Version:  lucytypes.PackageVersion(version),
+				},
+				Local: &lucytypes.PackageInstallation{
+					Path: file.Name(),
+				},
+				Dependencies: dependencies,
+			},
+		)
+	}
+	return packages
+}
+
+// dependencyVersion gives the version of a Maven version range that only
+// allows a single version, such as "[1.2.3]". Any other range is given as a
+// constraint with AllVersion, see syntax.ParseVersionConstraint. A version
+// without brackets is only a recommendation in Maven, so it allows any version,
+// as does a range that cannot be parsed.
+func dependencyVersion(versionRange string) (
+	version lucytypes.PackageVersion,
+	constraint *lucytypes.VersionConstraint,
+) {
+	versionRange = strings.TrimSpace(versionRange)
+	if !strings.HasPrefix(versionRange, "[") && !strings.HasPrefix(versionRange, "(") {
+		return lucytypes.AllVersion, nil
+	}
+	if len(versionRange) > 2 &&
+		strings.HasPrefix(versionRange, "[") &&
+		strings.HasSuffix(versionRange, "]") &&
+		!strings.Contains(versionRange, ",") {
+		return lucytypes.PackageVersion(versionRange[1 : len(versionRange)-1]), nil
+	}
+	constraint, err := syntax.ParseVersionConstraint(versionRange)
+	if err != nil {
+		logger.Debug("ignoring version range: " + err.Error())
+		return lucytypes.AllVersion, nil
+	}
+	return lucytypes.AllVersion, constraint
+}
+
+// jarImplementationVersion gives an empty string if the manifest does not exist
+// or has no Implementation-Version.
+func jarImplementationVersion(r *zip.Reader) string {
+	for _, f := range r.File {
+		if f.Name != jarManifestFile {
+			continue
+		}
+		data, err := readZipFile(f)
+		if err != nil {
+			return ""
+		}
+		scanner := bufio.NewScanner(strings.NewReader(string(data)))
+		for scanner.Scan() {
+			key, value, found := strings.Cut(scanner.Text(), ":")
+			if found && strings.TrimSpace(key) == "Implementation-Version" {
+				return strings.TrimSpace(value)
+			}
+		}
+		return ""
+	}
+	return ""
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rr, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rr.Close()
+	return io.ReadAll(rr)
+}
+
+func sortPackageIds(ids []lucytypes.PackageId) {
+	sort.Slice(ids, func(i, j int) bool { return ids[i].Name < ids[j].Name })
+}
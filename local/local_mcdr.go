@@ -23,6 +23,8 @@ import (
 	"log"
 	"os"
 	"path"
+	"slices"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 
@@ -107,6 +109,19 @@ var getMcdrPlugins = tools.Memoize(
 	},
 )
 
+// getMcdrVersion asks the Python environment for the version of the installed
+// mcdreforged distribution.
+var getMcdrVersion = tools.Memoize(mcdrVersion)
+
+func mcdrVersion() string {
+	version, err := Python.Version("mcdreforged")
+	if err != nil {
+		logger.Debug("cannot get mcdr version: " + err.Error())
+		return ""
+	}
+	return version
+}
+
 const mcdrPluginIdentifierFile = "mcdreforged.plugin.json"
 
 func analyzeMcdrPlugin(file *os.File) (
@@ -143,10 +158,79 @@ func analyzeMcdrPlugin(file *os.File) (
 				Local: &lucytypes.PackageInstallation{
 					Path: file.Name(),
 				},
-				Dependencies: nil, // TODO: This is not yet implemented, mcdr includes external (python packages) dependencies
+				Dependencies: mcdrPluginDependencies(pluginInfo, r),
 			}, nil
 		}
 	}
 
 	return
 }
+
+const mcdrPluginRequirementsFile = "requirements.txt"
+
+// mcdrPluginDependencies records the ids of the required plugins and the
+// Python requirements from the requirements.txt in the archive. Like
+// fabricModDependencies, the version requirements of plugins are not kept.
+func mcdrPluginDependencies(
+	pluginInfo *datatypes.McdrPluginIdentifierFile,
+	r *zip.Reader,
+) *lucytypes.PackageDependencies {
+	dependencies := &lucytypes.PackageDependencies{
+		SupportedPlatforms: []lucytypes.Platform{lucytypes.Mcdr},
+	}
+	for id := range pluginInfo.Dependencies {
+		if id == "mcdreforged" {
+			continue
+		}
+		dependencies.Required = append(
+			dependencies.Required,
+			lucytypes.PackageId{
+				Platform: lucytypes.Mcdr,
+				Name:     lucytypes.PackageName(id),
+			},
+		)
+	}
+	slices.SortFunc(
+		dependencies.Required, func(a, b lucytypes.PackageId) int {
+			return strings.Compare(string(a.Name), string(b.Name))
+		},
+	)
+
+	for _, f := range r.File {
+		if f.Name != mcdrPluginRequirementsFile {
+			continue
+		}
+		rr, err := f.Open()
+		if err != nil {
+			logger.Warning(err)
+			break
+		}
+		data, err := io.ReadAll(rr)
+		_ = rr.Close()
+		if err != nil {
+			logger.Warning(err)
+			break
+		}
+		dependencies.PythonRequirements = ReadPythonRequirements(string(data))
+		break
+	}
+	return dependencies
+}
+
+// pipInstallExtraArgs reads plugin_pip_install_extra_args from MCDR's config,
+// which is either a single string of arguments or a list of them.
+func pipInstallExtraArgs(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		args := make([]string, 0, len(v))
+		for _, arg := range v {
+			if s, ok := arg.(string); ok {
+				args = append(args, s)
+			}
+		}
+		return args
+	}
+	return nil
+}
@@ -0,0 +1,344 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"lucy/tools"
+)
+
+var ErrorNoPython = errors.New("no python found")
+
+// PythonEnvironment is where MCDR and the Python packages of its plugins are
+// installed.
+type PythonEnvironment interface {
+	// Distributions gives the version of every installed distribution, keyed by
+	// its normalized name, see normalizeDistributionName.
+	Distributions() (map[string]string, error)
+	// Version gives the version of one installed distribution, which is
+	// quicker than listing all of them.
+	Version(distribution string) (string, error)
+	// Install installs the requirements with pip. extraArgs are passed to pip
+	// before the requirements.
+	Install(requirements []string, extraArgs []string) error
+}
+
+// Python is the environment that MCDR runs in. It is a variable so that it can
+// be replaced, for example by a fake that does not run pip.
+var Python PythonEnvironment = pipEnvironment{executable: pythonExecutable}
+
+// pythonExecutable prefers a virtual environment in the working directory, as
+// MCDR is commonly installed in one. An empty string is given if there is no
+// Python at all.
+var pythonExecutable = tools.Memoize(
+	func() string {
+		for _, venv := range []string{".venv", "venv"} {
+			for _, bin := range []string{"bin/python3", "bin/python", "Scripts/python.exe"} {
+				if _, err := os.Stat(path.Join(venv, bin)); err == nil {
+					return path.Join(venv, bin)
+				}
+			}
+		}
+		for _, name := range []string{"python3", "python"} {
+			if p, err := exec.LookPath(name); err == nil {
+				return p
+			}
+		}
+		return ""
+	},
+)
+
+// pipEnvironment runs pip as a module of the Python that executable gives,
+// normally pythonExecutable, so the pip of the right environment is used even if
+// another one comes first in PATH.
+type pipEnvironment struct {
+	executable func() string
+}
+
+func (e pipEnvironment) Distributions() (map[string]string, error) {
+	python := e.executable()
+	if python == "" {
+		return nil, ErrorNoPython
+	}
+	out, err := exec.Command(
+		python, "-m", "pip", "list", "--format=json", "--disable-pip-version-check",
+	).Output()
+	if err != nil {
+		return nil, fmt.Errorf("pip list failed: %w", err)
+	}
+
+	var list []struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(out, &list); err != nil {
+		return nil, err
+	}
+	distributions := make(map[string]string, len(list))
+	for _, d := range list {
+		distributions[normalizeDistributionName(d.Name)] = d.Version
+	}
+	return distributions, nil
+}
+
+func (e pipEnvironment) Version(distribution string) (string, error) {
+	python := e.executable()
+	if python == "" {
+		return "", ErrorNoPython
+	}
+	out, err := exec.Command(
+		python,
+		"-c",
+		"import sys, importlib.metadata as m; print(m.version(sys.argv[1]))",
+		distribution,
+	).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (e pipEnvironment) Install(requirements []string, extraArgs []string) error {
+	python := e.executable()
+	if python == "" {
+		return ErrorNoPython
+	}
+	args := []string{"-m", "pip", "install", "--disable-pip-version-check"}
+	args = append(args, extraArgs...)
+	args = append(args, requirements...)
+	cmd := exec.Command(python, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// MissingPythonRequirements gives the requirements that no installed
+// distribution in Python satisfies. Environment markers are not evaluated, a
+// requirement with a marker is treated as applicable.
+func MissingPythonRequirements(requirements []string) (missing []string, err error) {
+	if len(requirements) == 0 {
+		return nil, nil
+	}
+	distributions, err := Python.Distributions()
+	if err != nil {
+		return nil, err
+	}
+	for _, requirement := range requirements {
+		name, specifiers := parsePythonRequirement(requirement)
+		version, installed := distributions[normalizeDistributionName(name)]
+		if !installed || !satisfiesPythonSpecifiers(version, specifiers) {
+			missing = append(missing, requirement)
+		}
+	}
+	return missing, nil
+}
+
+// ReadPythonRequirements parses the lines of a requirements.txt. Comments,
+// blank lines and pip options (such as "-r other.txt") are left out.
+func ReadPythonRequirements(data string) (requirements []string) {
+	for _, line := range strings.Split(data, "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "-") {
+			continue
+		}
+		requirements = append(requirements, line)
+	}
+	return requirements
+}
+
+var distributionNameSeparators = regexp.MustCompile(`[-_.]+`)
+
+// normalizeDistributionName follows PEP 503, so "Ruamel.YAML" and
+// "ruamel_yaml" are the same distribution.
+func normalizeDistributionName(name string) string {
+	return distributionNameSeparators.ReplaceAllString(strings.ToLower(name), "-")
+}
+
+// parsePythonRequirement splits a requirement such as
+// "requests[socks]>=2.0,<3; python_version>'3.8'" into its name and its version
+// specifiers. The extras and the marker are dropped.
+func parsePythonRequirement(requirement string) (name string, specifiers []string) {
+	requirement, _, _ = strings.Cut(requirement, ";")
+	requirement = strings.TrimSpace(requirement)
+	end := strings.IndexAny(requirement, "[<>=!~( ")
+	if end == -1 {
+		return requirement, nil
+	}
+	name = requirement[:end]
+	rest := requirement[end:]
+	if _, afterExtras, found := strings.Cut(rest, "]"); found && strings.HasPrefix(rest, "[") {
+		rest = afterExtras
+	}
+	rest = strings.Trim(strings.TrimSpace(rest), "()")
+	for _, specifier := range strings.Split(rest, ",") {
+		if specifier = strings.ReplaceAll(specifier, " ", ""); specifier != "" {
+			specifiers = append(specifiers, specifier)
+		}
+	}
+	return name, specifiers
+}
+
+// pythonOperators are ordered so that longer operators are matched first.
+var pythonOperators = []string{"===", "~=", "==", "!=", "<=", ">=", "<", ">"}
+
+// satisfiesPythonSpecifiers supports the PEP 440 operators, including the
+// ".*" suffix of "==" and "!=". Versions are compared by their release numbers
+// first, see comparePythonVersions.
+func satisfiesPythonSpecifiers(version string, specifiers []string) bool {
+	for _, specifier := range specifiers {
+		operator := ""
+		for _, o := range pythonOperators {
+			if strings.HasPrefix(specifier, o) {
+				operator = o
+				break
+			}
+		}
+		target := specifier[len(operator):]
+
+		var ok bool
+		switch operator {
+		case "===":
+			ok = version == target
+		case "==", "!=":
+			if prefix, wildcard := strings.CutSuffix(target, ".*"); wildcard {
+				ok = hasPythonReleasePrefix(version, prefix)
+			} else {
+				ok = comparePythonVersions(version, target) == 0
+			}
+			if operator == "!=" {
+				ok = !ok
+			}
+		case "~=":
+			release, _ := splitPythonVersion(target)
+			prefix := release
+			if len(release) > 1 {
+				prefix = release[:len(release)-1]
+			}
+			ok = comparePythonVersions(version, target) >= 0 &&
+				hasPythonReleasePrefix(version, joinPythonRelease(prefix))
+		case "<=":
+			ok = comparePythonVersions(version, target) <= 0
+		case ">=":
+			ok = comparePythonVersions(version, target) >= 0
+		case "<":
+			ok = comparePythonVersions(version, target) < 0
+		case ">":
+			ok = comparePythonVersions(version, target) > 0
+		default:
+			// Not a specifier that can be understood, so it is not checked
+			ok = true
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+var pythonReleasePattern = regexp.MustCompile(`^v?(\d+(?:\.\d+)*)(.*)$`)
+
+// splitPythonVersion gives the release numbers and whatever comes after them.
+// The local version after "+" is dropped.
+func splitPythonVersion(version string) (release []int, suffix string) {
+	version, _, _ = strings.Cut(strings.TrimSpace(strings.ToLower(version)), "+")
+	match := pythonReleasePattern.FindStringSubmatch(version)
+	if match == nil {
+		return nil, version
+	}
+	for _, part := range strings.Split(match[1], ".") {
+		n, _ := strconv.Atoi(part)
+		release = append(release, n)
+	}
+	return release, match[2]
+}
+
+func joinPythonRelease(release []int) string {
+	parts := make([]string, 0, len(release))
+	for _, n := range release {
+		parts = append(parts, strconv.Itoa(n))
+	}
+	return strings.Join(parts, ".")
+}
+
+// comparePythonVersions treats missing release numbers as zeros, so "1.0" and
+// "1.0.0" are the same.
+func comparePythonVersions(a, b string) int {
+	releaseA, suffixA := splitPythonVersion(a)
+	releaseB, suffixB := splitPythonVersion(b)
+	for i := 0; i < max(len(releaseA), len(releaseB)); i++ {
+		var x, y int
+		if i < len(releaseA) {
+			x = releaseA[i]
+		}
+		if i < len(releaseB) {
+			y = releaseB[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	if suffixA == suffixB {
+		return 0
+	}
+	if rankA, rankB := suffixRank(suffixA), suffixRank(suffixB); rankA != rankB {
+		if rankA < rankB {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(suffixA, suffixB)
+}
+
+// suffixRank puts pre- and dev-releases before their release, and
+// post-releases after it.
+func suffixRank(suffix string) int {
+	switch {
+	case suffix == "":
+		return 1
+	case strings.Contains(suffix, "post"):
+		return 2
+	}
+	return 0
+}
+
+func hasPythonReleasePrefix(version string, prefix string) bool {
+	release, _ := splitPythonVersion(version)
+	want, _ := splitPythonVersion(prefix)
+	for i, n := range want {
+		v := 0
+		if i < len(release) {
+			v = release[i]
+		}
+		if v != n {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	"errors"
+	"os"
+	"path"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fakePython is an environment with the distributions, where installing does
+// nothing. err is given by every method.
+type fakePython struct {
+	distributions map[string]string
+	err           error
+}
+
+func (f *fakePython) Distributions() (map[string]string, error) {
+	return f.distributions, f.err
+}
+
+func (f *fakePython) Version(distribution string) (string, error) {
+	if f.err != nil {
+		return "", f.err
+	}
+	version, ok := f.distributions[distribution]
+	if !ok {
+		return "", errors.New("not installed: " + distribution)
+	}
+	return version, nil
+}
+
+func (f *fakePython) Install(requirements []string, extraArgs []string) error {
+	return f.err
+}
+
+func usePython(t *testing.T, environment PythonEnvironment) {
+	t.Helper()
+	python := Python
+	Python = environment
+	t.Cleanup(func() { Python = python })
+}
+
+func TestMissingPythonRequirements(t *testing.T) {
+	usePython(t, &fakePython{
+		distributions: map[string]string{
+			"mcdreforged": "2.13.1",
+			"ruamel-yaml": "0.18.6",
+			"requests":    "2.31.0",
+			"psutil":      "5.9.0rc1",
+		},
+	})
+
+	requirements := []string{
+		"mcdreforged>=2.12",
+		"Ruamel.YAML~=0.18.0",
+		"requests[socks]>=2.0,<3; python_version>'3.8'",
+		"requests==2.31.*",
+		"psutil>=5.9.0",
+		"colorama",
+		"mcdreforged>=3",
+		"requests!=2.31.0",
+	}
+	missing, err := MissingPythonRequirements(requirements)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"psutil>=5.9.0", "colorama", "mcdreforged>=3", "requests!=2.31.0"}
+	if !slices.Equal(missing, want) {
+		t.Errorf("missing = %v, want %v", missing, want)
+	}
+}
+
+func TestMissingPythonRequirementsFailure(t *testing.T) {
+	usePython(t, &fakePython{err: ErrorNoPython})
+
+	if _, err := MissingPythonRequirements([]string{"requests"}); !errors.Is(err, ErrorNoPython) {
+		t.Errorf("error = %v, want %v", err, ErrorNoPython)
+	}
+	// Python is not needed when nothing is required
+	if missing, err := MissingPythonRequirements(nil); err != nil || missing != nil {
+		t.Errorf("MissingPythonRequirements(nil) = %v, %v", missing, err)
+	}
+}
+
+func TestMcdrVersion(t *testing.T) {
+	usePython(t, &fakePython{distributions: map[string]string{"mcdreforged": "2.13.1"}})
+	if version := mcdrVersion(); version != "2.13.1" {
+		t.Errorf("version = %q, want 2.13.1", version)
+	}
+
+	usePython(t, &fakePython{})
+	if version := mcdrVersion(); version != "" {
+		t.Errorf("version without mcdr = %q", version)
+	}
+}
+
+// fakePipScript stands in for the Python executable. It logs its arguments to
+// $LOG, answers pip list with $LIST and exits with $STATUS, gives $VERSION for
+// a version probe, and fails to install anything named "broken".
+const fakePipScript = `#!/bin/sh
+echo "$@" >> "$LOG"
+case "$*" in
+*"pip list"*) echo "$LIST"; exit "${STATUS:-0}" ;;
+*"pip install"*broken*) echo "ERROR: No matching distribution found for broken" >&2; exit 1 ;;
+*"pip install"*) exit 0 ;;
+"-c "*)
+	if [ -n "$VERSION" ]; then echo "$VERSION"; exit 0; fi
+	echo "PackageNotFoundError: $3" >&2; exit 1 ;;
+esac
+exit 2
+`
+
+func fakePip(t *testing.T) (environment pipEnvironment, log string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake python is a shell script")
+	}
+	dir := t.TempDir()
+	script := path.Join(dir, "python3")
+	if err := os.WriteFile(script, []byte(fakePipScript), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	log = path.Join(dir, "log")
+	t.Setenv("LOG", log)
+	t.Setenv("LIST", "")
+	t.Setenv("STATUS", "")
+	t.Setenv("VERSION", "")
+	return pipEnvironment{executable: func() string { return script }}, log
+}
+
+func TestPipDistributions(t *testing.T) {
+	pip, _ := fakePip(t)
+
+	t.Setenv("LIST", `[{"name": "Ruamel.YAML", "version": "0.18.6"}, {"name": "mcdreforged", "version": "2.13.1"}]`)
+	distributions, err := pip.Distributions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if distributions["ruamel-yaml"] != "0.18.6" || distributions["mcdreforged"] != "2.13.1" {
+		t.Errorf("distributions = %v", distributions)
+	}
+
+	t.Setenv("LIST", "WARNING: pip is being invoked by an old script wrapper")
+	if _, err := pip.Distributions(); err == nil {
+		t.Error("no error for output that is not json")
+	}
+
+	t.Setenv("LIST", "[]")
+	t.Setenv("STATUS", "1")
+	if _, err := pip.Distributions(); err == nil {
+		t.Error("no error when pip fails")
+	}
+}
+
+func TestPipVersion(t *testing.T) {
+	pip, _ := fakePip(t)
+
+	t.Setenv("VERSION", "2.13.1")
+	version, err := pip.Version("mcdreforged")
+	if err != nil || version != "2.13.1" {
+		t.Errorf("Version = %q, %v, want 2.13.1", version, err)
+	}
+
+	t.Setenv("VERSION", "")
+	if _, err := pip.Version("mcdreforged"); err == nil {
+		t.Error("no error when the distribution is not installed")
+	}
+}
+
+func TestPipInstall(t *testing.T) {
+	pip, log := fakePip(t)
+
+	err := pip.Install([]string{"requests>=2", "ruamel.yaml"}, []string{"--user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "-m pip install --disable-pip-version-check --user requests>=2 ruamel.yaml"
+	if got := strings.TrimSpace(string(data)); got != want {
+		t.Errorf("pip called with %q, want %q", got, want)
+	}
+
+	if err := pip.Install([]string{"broken"}, nil); err == nil {
+		t.Error("no error when pip fails")
+	}
+}
+
+func TestPipNoPython(t *testing.T) {
+	pip := pipEnvironment{executable: func() string { return "" }}
+
+	if _, err := pip.Distributions(); !errors.Is(err, ErrorNoPython) {
+		t.Errorf("Distributions: error = %v, want %v", err, ErrorNoPython)
+	}
+	if _, err := pip.Version("mcdreforged"); !errors.Is(err, ErrorNoPython) {
+		t.Errorf("Version: error = %v, want %v", err, ErrorNoPython)
+	}
+	if err := pip.Install([]string{"requests"}, nil); !errors.Is(err, ErrorNoPython) {
+		t.Errorf("Install: error = %v, want %v", err, ErrorNoPython)
+	}
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"lucy/datatypes"
+	"lucy/logger"
+	"lucy/lucytypes"
+	"lucy/tools"
+)
+
+const quiltModIdentifierFile = "quilt.mod.json"
+
+// quiltNonModDependencies are dependency ids that refer to the environment
+// rather than to another mod.
+var quiltNonModDependencies = []string{"minecraft", "java", "quilt_loader", "fabricloader"}
+
+func analyzeQuiltMod(file *os.File, identifier *zip.File) []lucytypes.Package {
+	data, err := readZipFile(identifier)
+	if err != nil {
+		return nil
+	}
+	modInfo := &datatypes.QuiltModIdentifier{}
+	if err := json.Unmarshal(data, modInfo); err != nil {
+		return nil
+	}
+	p := lucytypes.Package{
+		Id: lucytypes.PackageId{
+			Platform: lucytypes.Quilt,
+			Name:     lucytypes.PackageName(modInfo.QuiltLoader.Id),
+			Version:  lucytypes.PackageVersion(modInfo.QuiltLoader.Version),
+		},
+		Local: &lucytypes.PackageInstallation{
+			Path: file.Name(),
+		},
+		Dependencies: quiltModDependencies(modInfo),
+	}
+	return []lucytypes.Package{p}
+}
+
+// quiltModDependencies only records the ids, like fabricModDependencies. When a
+// dependency lists several mods that any one of will do, none of them is
+// strictly required, so they are all recorded as optional.
+func quiltModDependencies(modInfo *datatypes.QuiltModIdentifier) *lucytypes.PackageDependencies {
+	dependencies := &lucytypes.PackageDependencies{
+		SupportedPlatforms: []lucytypes.Platform{lucytypes.Quilt},
+		Required:           []lucytypes.PackageId{},
+	}
+	add := func(to *[]lucytypes.PackageId, d datatypes.QuiltDependency) {
+		_, id, found := strings.Cut(d.Id, ":")
+		if !found {
+			id = d.Id
+		}
+		if id == "" || slices.Contains(quiltNonModDependencies, id) {
+			return
+		}
+		*to = append(
+			*to,
+			lucytypes.PackageId{
+				Platform: lucytypes.Quilt,
+				Name:     lucytypes.PackageName(id),
+				Version:  lucytypes.AllVersion,
+			},
+		)
+	}
+
+	for _, d := range modInfo.QuiltLoader.Depends {
+		switch {
+		case d.AnyOf != nil:
+			for _, alternative := range d.AnyOf {
+				add(&dependencies.Optional, alternative)
+			}
+		case d.Optional:
+			add(&dependencies.Optional, d)
+		default:
+			add(&dependencies.Required, d)
+		}
+	}
+	for _, d := range modInfo.QuiltLoader.Breaks {
+		if d.AnyOf != nil {
+			for _, alternative := range d.AnyOf {
+				add(&dependencies.Incompatible, alternative)
+			}
+			continue
+		}
+		add(&dependencies.Incompatible, d)
+	}
+
+	sortPackageIds(dependencies.Required)
+	sortPackageIds(dependencies.Optional)
+	sortPackageIds(dependencies.Incompatible)
+	return dependencies
+}
+
+// asQuiltPackage relabels a Fabric mod installed on a Quilt server, as it is
+// managed as a Quilt package there.
+func asQuiltPackage(p lucytypes.Package) lucytypes.Package {
+	if p.Id.Platform != lucytypes.Fabric {
+		return p
+	}
+	p.Id.Platform = lucytypes.Quilt
+	if p.Dependencies == nil {
+		return p
+	}
+	d := *p.Dependencies
+	relabel := func(ids []lucytypes.PackageId) (relabeled []lucytypes.PackageId) {
+		for _, id := range ids {
+			id.Platform = lucytypes.Quilt
+			relabeled = append(relabeled, id)
+		}
+		return relabeled
+	}
+	d.SupportedPlatforms = []lucytypes.Platform{lucytypes.Fabric, lucytypes.Quilt}
+	d.Required = relabel(d.Required)
+	d.Optional = relabel(d.Optional)
+	d.Incompatible = relabel(d.Incompatible)
+	p.Dependencies = &d
+	return p
+}
+
+const quiltLauncherMainClass = "org.quiltmc.loader.impl.launch.server.QuiltServerLauncher"
+
+// analyzeQuiltLauncher reads quilt-server-launch.jar, which is made by the
+// Quilt installer. Its manifest looks like the one of the Fabric launcher (see
+// analyzeFabricLauncher), and the game version comes from either intermediary
+// or Quilt's hashed mappings.
+func analyzeQuiltLauncher(manifest *zip.File) (exec *lucytypes.ExecutableInfo) {
+	r, err := manifest.Open()
+	if err != nil {
+		return nil
+	}
+	defer tools.CloseReader(r, logger.Warning)
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil
+	}
+	s := strings.ReplaceAll(string(data), "\r\n ", "") // Join continued lines
+	if !strings.Contains(s, quiltLauncherMainClass) {
+		return nil
+	}
+
+	exec = &lucytypes.ExecutableInfo{}
+	exec.Platform = lucytypes.Quilt
+	_, classPath, _ := strings.Cut(s, "Class-Path: ")
+	classPath, _, _ = strings.Cut(strings.ReplaceAll(classPath, "\r\n", "\n"), "\n")
+	for _, p := range strings.Fields(classPath) {
+		segments := strings.Split(p, "/")
+		if len(segments) < 5 {
+			continue
+		}
+		switch {
+		case strings.Contains(p, "libraries/org/quiltmc/quilt-loader/"):
+			exec.LoaderVersion = segments[4]
+		case strings.Contains(p, "libraries/net/fabricmc/intermediary/"),
+			strings.Contains(p, "libraries/org/quiltmc/hashed/"):
+			exec.GameVersion = segments[4]
+		}
+	}
+	return exec
+}
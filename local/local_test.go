@@ -0,0 +1,158 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	"archive/zip"
+	"os"
+	"path"
+	"testing"
+
+	"lucy/lucytypes"
+)
+
+// writeJar makes a jar of the files in a temporary directory.
+func writeJar(t *testing.T, files map[string]string) *os.File {
+	t.Helper()
+	f, err := os.Create(path.Join(t.TempDir(), "mod.jar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = f.Close() })
+	w := zip.NewWriter(f)
+	for name, content := range files {
+		entry, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := entry.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+const (
+	testFabricModJson = `{"schemaVersion": 1, "id": "sodium", "version": "0.5.8"}`
+	testQuiltModJson  = `{"schema_version": 1, "quilt_loader": {"id": "sodium", "version": "0.5.8"}}`
+	testModsToml      = "modLoader = \"javafml\"\n[[mods]]\nmodId = \"jei\"\nversion = \"15.2.0.27\"\n"
+)
+
+func TestAnalyzeModJarPrefersServerPlatform(t *testing.T) {
+	fabricAndQuilt := map[string]string{
+		fabricModIdentifierFile: testFabricModJson,
+		quiltModIdentifierFile:  testQuiltModJson,
+	}
+	forgeAndNeoforge := map[string]string{
+		forgeModIdentifierFile:    testModsToml,
+		neoforgeModIdentifierFile: testModsToml,
+	}
+	tests := []struct {
+		name     string
+		files    map[string]string
+		platform lucytypes.Platform
+		want     lucytypes.Platform
+	}{
+		{"fabric server", fabricAndQuilt, lucytypes.Fabric, lucytypes.Fabric},
+		{"quilt server", fabricAndQuilt, lucytypes.Quilt, lucytypes.Quilt},
+		{"unknown server", fabricAndQuilt, lucytypes.UnknownPlatform, lucytypes.Fabric},
+		{"quilt only", map[string]string{quiltModIdentifierFile: testQuiltModJson}, lucytypes.Fabric, lucytypes.Quilt},
+		{"forge server", forgeAndNeoforge, lucytypes.Forge, lucytypes.Forge},
+		{"neoforge server", forgeAndNeoforge, lucytypes.Neoforge, lucytypes.Neoforge},
+		{"neoforge only", map[string]string{neoforgeModIdentifierFile: testModsToml}, lucytypes.Forge, lucytypes.Neoforge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packages := analyzeModJar(writeJar(t, tt.files), tt.platform)
+			if len(packages) != 1 {
+				t.Fatalf("got %d packages, want 1", len(packages))
+			}
+			if got := packages[0].Id.Platform; got != tt.want {
+				t.Errorf("platform = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForgeDependencyVersions(t *testing.T) {
+	modsToml := `modLoader = "javafml"
+[[mods]]
+modId = "jei"
+version = "15.2.0.27"
+[[dependencies.jei]]
+modId = "forge"
+mandatory = true
+versionRange = "[47,)"
+[[dependencies.jei]]
+modId = "minecraft"
+mandatory = true
+versionRange = "[1.20.1]"
+[[dependencies.jei]]
+modId = "architectury"
+mandatory = true
+versionRange = "[9.1,10)"
+[[dependencies.jei]]
+modId = "cloth_config"
+mandatory = true
+versionRange = "[11.1.106]"
+[[dependencies.jei]]
+modId = "appleskin"
+mandatory = false
+versionRange = ""
+[[dependencies.jei]]
+modId = "emi"
+mandatory = false
+versionRange = "1.0"
+`
+	packages := analyzeModJar(writeJar(t, map[string]string{forgeModIdentifierFile: modsToml}), lucytypes.Forge)
+	if len(packages) != 1 {
+		t.Fatalf("got %d packages, want 1", len(packages))
+	}
+	dependencies := packages[0].Dependencies
+	if len(dependencies.SupportedVersions) != 1 || dependencies.SupportedVersions[0] != "1.20.1" {
+		t.Errorf("supported versions = %v", dependencies.SupportedVersions)
+	}
+
+	tests := []struct {
+		id         lucytypes.PackageId
+		version    lucytypes.PackageVersion
+		constraint string
+	}{
+		{dependencies.Required[0], lucytypes.AllVersion, "[9.1,10)"},
+		{dependencies.Required[1], "11.1.106", ""},
+		{dependencies.Optional[0], lucytypes.AllVersion, ""},
+		{dependencies.Optional[1], lucytypes.AllVersion, ""},
+	}
+	for _, tt := range tests {
+		constraint := ""
+		if tt.id.Constraint != nil {
+			constraint = tt.id.Constraint.Raw
+			if len(tt.id.Constraint.Alternatives) == 0 {
+				t.Errorf("%s: constraint has no conditions", tt.id.Name)
+			}
+		}
+		if tt.id.Version != tt.version || constraint != tt.constraint {
+			t.Errorf("%s: version %s, constraint %q, want %s, %q", tt.id.Name, tt.id.Version, constraint, tt.version, tt.constraint)
+		}
+	}
+	if len(dependencies.Required) != 2 || len(dependencies.Optional) != 2 {
+		t.Errorf("required = %v, optional = %v", dependencies.Required, dependencies.Optional)
+	}
+}
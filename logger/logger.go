@@ -59,6 +59,11 @@ var (
 			queue.Add(&logItem{Level: lDebug, Content: content})
 		}
 	}
+	// ErrorToFile is Error for an error that is already printed for the user,
+	// it is only written to the log file.
+	ErrorToFile = func(content error) {
+		queue.Add(&logItem{Level: lError, Content: content, FileOnly: true})
+	}
 )
 
 func WriteAll() {
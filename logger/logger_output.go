@@ -47,7 +47,7 @@ func (level logLevel) prefix(colored bool) string {
 }
 
 func writeItem(message *logItem) {
-	if toConsole {
+	if toConsole && !message.FileOnly {
 		_, _ = fmt.Fprintln(
 			os.Stderr,
 			message.Level.prefix(true),
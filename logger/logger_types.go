@@ -19,6 +19,9 @@ package logger
 type logItem struct {
 	Level   logLevel
 	Content any
+	// FileOnly is set for what the user is already shown another way, it is
+	// not written to the console.
+	FileOnly bool
 }
 
 type logLevel uint8
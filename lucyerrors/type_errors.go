@@ -25,3 +25,8 @@ var (
 	PackageSyntaxError      = errors.New("invalid package syntax")
 	EmptyPackageSyntaxError = errors.New("empty package string")
 )
+
+var (
+	ChecksumMismatchError = errors.New("checksum mismatch")
+	HttpStatusError       = errors.New("unexpected http status")
+)
@@ -0,0 +1,122 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lucyerrors
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// The kinds of errors that the user can act on. An error is of a kind if it
+// wraps it, which errors.Is tells. Sentinel errors of other packages are made
+// with New to be of a kind, and other errors with Of.
+//
+// PackageSyntaxError is the kind of invalid user inputs.
+var (
+	NotFoundError     = errors.New("not found")
+	IncompatibleError = errors.New("incompatible")
+	NetworkError      = errors.New("network error")
+	RateLimitedError  = errors.New("rate limited")
+)
+
+// kinds is in the order KindOf checks them, the more specific first.
+var kinds = []error{
+	RateLimitedError,
+	PackageSyntaxError,
+	IncompatibleError,
+	NotFoundError,
+	NetworkError,
+}
+
+// kindError is err being of kind, with the message of err only.
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// Of makes err an error of kind, without changing its message. A nil err stays
+// nil.
+func Of(kind error, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{kind: kind, err: err}
+}
+
+// New is errors.New for an error of kind.
+func New(kind error, text string) error {
+	return Of(kind, errors.New(text))
+}
+
+// KindOf gives the kind of err, nil if it has none. Errors of the http client
+// are NetworkError, even if they were not made so.
+func KindOf(err error) error {
+	for _, kind := range kinds {
+		if errors.Is(err, kind) {
+			return kind
+		}
+	}
+	var urlErr *url.Error
+	var netErr net.Error
+	if errors.As(err, &urlErr) || errors.As(err, &netErr) {
+		return NetworkError
+	}
+	return nil
+}
+
+// CheckResponse gives nil for a 2xx status, or an error wrapping
+// HttpStatusError otherwise. A 404 or 410 is also a NotFoundError, a 429 a
+// RateLimitedError, and a 5xx a NetworkError, as the remote is unavailable.
+func CheckResponse(res *http.Response) error {
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+	source := ""
+	if res.Request != nil && res.Request.URL != nil {
+		source = " from " + res.Request.URL.Host
+	}
+	err := fmt.Errorf("%w: %s%s", HttpStatusError, res.Status, source)
+
+	switch {
+	case res.StatusCode == http.StatusNotFound, res.StatusCode == http.StatusGone:
+		return Of(NotFoundError, err)
+	case res.StatusCode == http.StatusTooManyRequests:
+		if retry := strings.TrimSpace(res.Header.Get("Retry-After")); retry != "" {
+			if _, atoiErr := strconv.Atoi(retry); atoiErr == nil {
+				retry += "s" // it is given in seconds, if not as a date
+			}
+			err = fmt.Errorf("%w, retry after %s", err, retry)
+		}
+		return Of(RateLimitedError, err)
+	case res.StatusCode >= 500:
+		return Of(NetworkError, err)
+	}
+	return err
+}
@@ -36,6 +36,12 @@ const (
 	Fabric          Platform = "fabric"
 	Forge           Platform = "forge"
 	Neoforge        Platform = "neoforge"
+	Quilt           Platform = "quilt"
+	Spigot          Platform = "spigot"
+	Paper           Platform = "paper"
+	Purpur          Platform = "purpur"
+	Velocity        Platform = "velocity"  // Proxy, only used to look up plugins on Hangar
+	Waterfall       Platform = "waterfall" // Proxy, only used to look up plugins on Hangar
 	Mcdr            Platform = "mcdr"
 	AllPlatform     Platform = "all"
 	UnknownPlatform Platform = "unknown"
@@ -58,7 +64,18 @@ func (p Platform) IsAll() bool {
 // Valid should be edited if you added a new platform.
 func (p Platform) Valid() bool {
 	switch p {
-	case Minecraft, Fabric, Forge, Neoforge, Mcdr, AllPlatform, UnknownPlatform:
+	case Minecraft, Fabric, Forge, Neoforge, Quilt, Spigot, Paper, Purpur,
+		Velocity, Waterfall, Mcdr, AllPlatform, UnknownPlatform:
+		return true
+	}
+	return false
+}
+
+// IsBukkit tells whether the platform is a Bukkit-family server, which loads
+// plugins rather than mods.
+func (p Platform) IsBukkit() bool {
+	switch p {
+	case Spigot, Paper, Purpur:
 		return true
 	}
 	return false
@@ -95,29 +112,55 @@ func (p PackageName) String() string {
 	return string(p)
 }
 
+// Eq compares two names case-insensitively, treating underlines as hyphens.
+func (p PackageName) Eq(other PackageName) bool {
+	normalize := func(n PackageName) string {
+		return strings.ReplaceAll(strings.ToLower(string(n)), "_", "-")
+	}
+	return normalize(p) == normalize(other)
+}
+
+// GitHubPrefix marks a name that refers to a GitHub repository, as in
+// "github:owner/repo". The real name of such a package is only known once a
+// release asset is downloaded and analyzed.
+const GitHubPrefix = "github:"
+
+// GitHubRepo gives "owner/repo" if the name refers to a GitHub repository.
+func (p PackageName) GitHubRepo() (repo string, ok bool) {
+	return strings.CutPrefix(string(p), GitHubPrefix)
+}
+
 type PackageId struct {
 	Platform Platform
 	Name     PackageName
 	Version  PackageVersion
+	// Constraint is set instead of an exact Version when a range of versions is
+	// accepted, in which case Version is AllVersion. It is nil otherwise.
+	Constraint *VersionConstraint
 }
 
 func (p *PackageId) NewPackage() *Package {
 	return &Package{
 		Id: PackageId{
-			Platform: p.Platform,
-			Name:     p.Name,
-			Version:  p.Version,
+			Platform:   p.Platform,
+			Name:       p.Name,
+			Version:    p.Version,
+			Constraint: p.Constraint,
 		},
 	}
 }
 
 func (p *PackageId) String() string {
-	return tools.Ternary(
+	s := tools.Ternary(
 		p.Platform == AllPlatform,
 		"", string(p.Platform)+"/",
 	) +
 		string(p.Name) +
 		tools.Ternary(p.Version == AllVersion, "", "@"+string(p.Version))
+	if p.Constraint != nil {
+		s += "@" + p.Constraint.String()
+	}
+	return s
 }
 
 func (p *PackageId) FullString() string {
@@ -125,11 +168,15 @@ func (p *PackageId) FullString() string {
 }
 
 func (p *PackageId) StringVersion() string {
+	if p.Constraint != nil {
+		return string(p.Name) + "@" + p.Constraint.String()
+	}
 	return string(p.Name) + "@" + p.Version.String()
 }
 
-// PackageVersion is the version of a package. Here we expect mods and plugins
-// use semver (which they should). A known exception is Minecraft snapshots.
+// PackageVersion is the version of a package. Mods and plugins mostly use
+// something close to semver, often with the game version and loader in it. See
+// syntax.ComparePackageVersions for how they are ordered.
 type PackageVersion string
 
 func (p PackageVersion) String() string {
@@ -154,3 +201,33 @@ var (
 	LatestVersion           PackageVersion = "latest"
 	LatestCompatibleVersion PackageVersion = "compatible"
 )
+
+// VersionConstraint is a range of versions, such as "^0.5", ">=1.2.0 <2" or
+// "[1.0,2.0)". A version satisfies it if it meets every condition in any one of
+// the Alternatives. The constraint is parsed in package syntax, which is also
+// where versions are compared against it.
+type VersionConstraint struct {
+	// Raw is the constraint as the user wrote it.
+	Raw          string
+	Alternatives [][]VersionCondition
+}
+
+func (c *VersionConstraint) String() string {
+	return c.Raw
+}
+
+type VersionCondition struct {
+	Operator VersionOperator
+	Version  PackageVersion
+}
+
+type VersionOperator string
+
+const (
+	OperatorEq VersionOperator = "=="
+	OperatorNe VersionOperator = "!="
+	OperatorGt VersionOperator = ">"
+	OperatorGe VersionOperator = ">="
+	OperatorLt VersionOperator = "<"
+	OperatorLe VersionOperator = "<="
+)
@@ -16,6 +16,8 @@ limitations under the License.
 
 package lucytypes
 
+import "time"
+
 type PackageUrlType uint8
 
 const (
@@ -76,6 +78,9 @@ type PackageDependencies struct {
 	Required           []PackageId
 	Optional           []PackageId
 	Incompatible       []PackageId
+	// PythonRequirements are the Python packages that an MCDR plugin needs, in
+	// the requirements.txt format.
+	PythonRequirements []string
 }
 
 // PackageInformation is a struct that contains informational data about the
@@ -108,14 +113,45 @@ type PackageRemote struct {
 	Source Source
 	// Whatever the remote source uses to identify this package.
 	RemoteId string
+	// Slug is the name of the package in the source, when it is not the same as
+	// the name in its Id. This happens to installed mods identified by their
+	// files, as their mod id is often not their slug.
+	Slug PackageName
 	// The URL to download the package's specified version When package.Id.Version
 	FileUrl  string
 	Filename string
+	// Sha1, Sha256 and Sha512 are the hex digests of the file at FileUrl, empty
+	// if the source does not provide them. Size is 0 if unknown.
+	Sha1   string
+	Sha256 string
+	Sha512 string
+	Size   int64
+	// LocalCopy is the path of the file at FileUrl if Lucy already has it, like
+	// a GitHub asset that was downloaded to be inspected. It is copied instead
+	// of downloaded again.
+	LocalCopy string
+	// Changelog of this version in markdown, empty if the source does not
+	// provide one.
+	Changelog string
+	// Published is when this version was released, zero if unknown. It orders
+	// versions whose version strings cannot be compared.
+	Published time.Time
 }
 
-// PackageUpdate is a struct to represent the update status of a package. It must
-// be used with PackageRemote.
+// PackageUpdate is a struct to represent the update status of an installed
+// package. It is used in `lucy upgrade` and `lucy outdated`.
 type PackageUpdate struct {
-	// Just refer this to the PackageRemote under the same Package struct.
-	Current *PackageRemote
+	// Current is the installed package, with its Local filled in.
+	Current Package
+	// Latest is the newest version compatible with the server, with its Remote
+	// filled in. It is nil if no remote version could be found.
+	Latest *Package
+	// Pinned is true if the version is fixed in the manifest, such a package is
+	// never upgraded.
+	Pinned bool
+	// Outdated is true if Latest is newer than Current.
+	Outdated bool
+	// Newest is the newest version regardless of whether the server can run it.
+	// It is only looked up by `lucy outdated`, and is nil otherwise.
+	Newest *Package
 }
@@ -51,7 +51,31 @@ func (i SearchIndex) ToModrinth() string {
 	}
 }
 
-// func (i SearchIndex) ToCurseForge() string
+// ToCurseForge gives the sortField of CurseForge's search API. Relevance is the
+// default order there, so it has no sortField.
+func (i SearchIndex) ToCurseForge() string {
+	switch i {
+	case ByDownloads:
+		return "6" // TotalDownloads
+	case ByNewest:
+		return "11" // ReleasedDate
+	default:
+		return ""
+	}
+}
+
+// ToHangar gives the sort parameter of Hangar's project search. Hangar orders
+// by relevance when there is no sort parameter.
+func (i SearchIndex) ToHangar() string {
+	switch i {
+	case ByDownloads:
+		return "-downloads"
+	case ByNewest:
+		return "-newest"
+	default:
+		return ""
+	}
+}
 
 type SearchResults struct {
 	Source  Source
@@ -18,15 +18,20 @@ package lucytypes
 
 import (
 	"os/exec"
+	"time"
 )
 
 // ServerInfo components that do not exist, use an empty string. Note Executable
 // must exist, otherwise the program will exit; therefore, it is not a pointer.
 type ServerInfo struct {
-	WorkPath   string
-	SavePath   string
-	ModPath    string
-	Mods       []Package
+	WorkPath string
+	SavePath string
+	ModPath  string
+	Mods     []Package
+	// PluginPath and Plugins are for Bukkit-family servers, MCDR plugins are
+	// under Mcdr instead.
+	PluginPath string
+	Plugins    []Package
 	HasLucy    bool
 	Mcdr       *McdrInstallation
 	Executable *ExecutableInfo
@@ -47,6 +52,16 @@ type Activity struct {
 }
 
 type McdrInstallation struct {
+	// Version is empty if it could not be told from the Python environment.
+	Version     string
 	PluginPaths []string
 	PluginList  []Package
+	// The catalogue settings come from MCDR's config.yml. An empty url or a zero
+	// duration means MCDR's default is used.
+	CatalogueMetaUrl          string
+	CatalogueMetaCacheTtl     time.Duration
+	CatalogueMetaFetchTimeout time.Duration
+	// PipInstallExtraArgs are passed to pip when installing the Python
+	// requirements of plugins.
+	PipInstallExtraArgs []string
 }
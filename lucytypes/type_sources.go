@@ -24,6 +24,7 @@ const (
 	Modrinth
 	GitHub
 	McdrRepo
+	Hangar
 	UnknownSource
 )
 
@@ -39,6 +40,8 @@ func (s Source) String() string {
 		return "github"
 	case McdrRepo:
 		return "mcdr"
+	case Hangar:
+		return "hangar"
 	default:
 		return "unknown"
 	}
@@ -54,7 +57,20 @@ func (s Source) Title() string {
 		return "GitHub"
 	case McdrRepo:
 		return "MCDR"
+	case Hangar:
+		return "Hangar"
 	default:
 		return "Unknown"
 	}
 }
+
+// ParseSource is the inverse of Source.String. UnknownSource is given for any
+// unrecognized string.
+func ParseSource(s string) Source {
+	for source := Auto; source < UnknownSource; source++ {
+		if source.String() == s {
+			return source
+		}
+	}
+	return UnknownSource
+}
@@ -18,6 +18,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
 
 	"lucy/cmd"
@@ -25,9 +26,8 @@ import (
 )
 
 func main() {
-	defer func() {
-		logger.Debug("program finished with exit code 0")
-		logger.WriteAll()
-	}()
-	cmd.Cli.Run(context.Background(), os.Args)
+	code := cmd.HandleError(cmd.Cli.Run(context.Background(), os.Args))
+	logger.Debug(fmt.Sprintf("program finished with exit code %d", code))
+	logger.WriteAll()
+	os.Exit(code)
 }
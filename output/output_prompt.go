@@ -47,3 +47,13 @@ func PromptRememberExecutable() bool {
 	result, _ := confirmRememberExecutable.Run()
 	return result == "true"
 }
+
+// PromptConfirm gives true only if the user explicitly answers yes.
+func PromptConfirm(label string) bool {
+	confirm := promptui.Prompt{
+		Label:     label,
+		IsConfirm: true,
+	}
+	_, err := confirm.Run()
+	return err == nil
+}
@@ -14,4 +14,509 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
+// Package curseforge provides functions to interact with the CurseForge Core
+// API. An API key is required, see ApiKeyEnv.
+//
+// We here use CurseForge terms in private functions:
+//   - Mod: A mod is a project on CurseForge, identified by a numeric id and a
+//     slug.
+//   - File: A file is a single upload of a mod, which is a package in Lucy.
+//
+// CurseForge files do not have a version number field. The version of a package
+// is guessed from its file name instead, see fileVersion.
 package curseforge
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"lucy/local"
+	"lucy/logger"
+	"lucy/lucyerrors"
+	"lucy/lucytypes"
+	"lucy/syntax"
+)
+
+var (
+	ErrorInvalidAPIResponse     = errors.New("invalid data from curseforge api")
+	ErrorNotFound               = lucyerrors.New(lucyerrors.NotFoundError, "not found on curseforge")
+	ErrorVersionNotFound        = lucyerrors.New(lucyerrors.NotFoundError, "curseforge file not found")
+	ErrorDistributionDisallowed = errors.New("the author does not allow downloading this file outside of curseforge")
+)
+
+// filesPageSize is the largest page size the API accepts.
+const filesPageSize = 50
+
+// Search
+//
+// For CurseForge search API, see:
+// https://docs.curseforge.com/rest-api/#search-mods
+//
+// CurseForge does not tell server-side mods from client-side ones, so
+// options.ShowClientPackage has no effect.
+func Search(
+	packageId lucytypes.PackageId,
+	options lucytypes.SearchOptions,
+) (result *lucytypes.SearchResults, err error) {
+	query := url.Values{}
+	query.Set("searchFilter", packageId.Name.String())
+	query.Set("pageSize", strconv.Itoa(filesPageSize))
+	if loader := modLoaderType(packageId.Platform); loader != 0 {
+		query.Set("modLoaderType", strconv.Itoa(loader))
+	}
+	if sortField := options.IndexBy.ToCurseForge(); sortField != "" {
+		query.Set("sortField", sortField)
+		query.Set("sortOrder", "desc")
+	}
+
+	res := &modsResponse{}
+	if err := get(searchUrl(query), res); err != nil {
+		return nil, err
+	}
+	if res.Pagination.TotalCount > len(res.Data) {
+		logger.Info(
+			strconv.Itoa(res.Pagination.TotalCount) + " results found on curseforge, only showing first " +
+				strconv.Itoa(len(res.Data)),
+		)
+	}
+
+	result = &lucytypes.SearchResults{}
+	result.Results = make([]string, 0, len(res.Data))
+	result.Source = lucytypes.CurseForge
+	for _, m := range res.Data {
+		result.Results = append(result.Results, m.Slug)
+	}
+	return result, nil
+}
+
+func Information(slug lucytypes.PackageName) (
+	information *lucytypes.PackageInformation,
+	err error,
+) {
+	m, err := getModBySlug(slug)
+	if err != nil {
+		return nil, err
+	}
+
+	information = &lucytypes.PackageInformation{
+		Name:        m.Name,
+		Brief:       m.Summary,
+		Description: m.Summary,
+		Author:      []lucytypes.PackageMember{},
+		Urls:        []lucytypes.PackageUrl{},
+	}
+	description := &stringResponse{}
+	if err := get(modDescriptionUrl(m.Id), description); err != nil {
+		logger.Warning(err)
+	} else {
+		information.Description = htmlToPlainText(description.Data)
+	}
+
+	// Fill in URLs
+	urls := []lucytypes.PackageUrl{
+		{Name: "Homepage", Type: lucytypes.HomepageUrl, Url: m.Links.WebsiteUrl},
+		{Name: "Wiki", Type: lucytypes.WikiUrl, Url: m.Links.WikiUrl},
+		{Name: "Source Code", Type: lucytypes.SourceUrl, Url: m.Links.SourceUrl},
+		{Name: "Issues", Type: lucytypes.OthersUrl, Url: m.Links.IssuesUrl},
+	}
+	for _, u := range urls {
+		if u.Url != "" {
+			information.Urls = append(information.Urls, u)
+		}
+	}
+
+	// Fill in authors
+	for _, author := range m.Authors {
+		information.Author = append(
+			information.Author,
+			lucytypes.PackageMember{
+				Name: author.Name,
+				Role: "Author",
+				Url:  author.Url,
+			},
+		)
+	}
+
+	return information, nil
+}
+
+// ListVersions gives every file of the package that supports id.Platform, newest
+// first. The remote of each package is filled in, but FileUrl can be empty if
+// the author does not allow third-party downloads.
+func ListVersions(id lucytypes.PackageId) (packages []lucytypes.Package, err error) {
+	m, err := getModBySlug(id.Name)
+	if err != nil {
+		return nil, err
+	}
+	files, err := listFiles(m.Id, id.Platform)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		packages = append(packages, *fileToPackage(id, m, &f))
+	}
+	return packages, nil
+}
+
+// Resolve infers the version of id and gives the package with its remote and
+// dependencies filled in, which is what gets pinned in lucy.lock. A file whose
+// author does not allow third-party downloads gives ErrorDistributionDisallowed.
+func Resolve(id lucytypes.PackageId) (p *lucytypes.Package, err error) {
+	m, err := getModBySlug(id.Name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := getFile(m, id)
+	if err != nil {
+		return nil, err
+	}
+
+	p = fileToPackage(id, m, f)
+	if p.Remote.FileUrl == "" {
+		p.Remote.FileUrl, err = getDownloadUrl(m.Id, f.Id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	p.Dependencies, err = fileDependencies(id, f)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func Fetch(id lucytypes.PackageId) (
+	remote *lucytypes.PackageRemote,
+	err error,
+) {
+	p, err := Resolve(id)
+	if err != nil {
+		return nil, err
+	}
+	return p.Remote, nil
+}
+
+// Dependencies gives the dependencies that CurseForge lists for the file that id
+// resolves to. Embedded libraries and tools are left out, as they need no
+// installation.
+func Dependencies(id lucytypes.PackageId) (
+	dependencies *lucytypes.PackageDependencies,
+	err error,
+) {
+	m, err := getModBySlug(id.Name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := getFile(m, id)
+	if err != nil {
+		return nil, err
+	}
+	return fileDependencies(id, f)
+}
+
+func fileDependencies(id lucytypes.PackageId, f *file) (
+	dependencies *lucytypes.PackageDependencies,
+	err error,
+) {
+	dependencies = &lucytypes.PackageDependencies{
+		SupportedVersions:  []lucytypes.PackageVersion{},
+		SupportedPlatforms: []lucytypes.Platform{},
+		Required:           []lucytypes.PackageId{},
+	}
+	gameVersions, platforms := splitGameVersions(f.GameVersions)
+	for _, v := range gameVersions {
+		dependencies.SupportedVersions = append(
+			dependencies.SupportedVersions,
+			lucytypes.PackageVersion(v),
+		)
+	}
+	dependencies.SupportedPlatforms = append(dependencies.SupportedPlatforms, platforms...)
+
+	var modIds []int
+	for _, d := range f.Dependencies {
+		modIds = append(modIds, d.ModId)
+	}
+	slugs, err := getSlugs(modIds)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range f.Dependencies {
+		slug, ok := slugs[d.ModId]
+		if !ok {
+			logger.Warning(fmt.Errorf("curseforge mod %d not found", d.ModId))
+			continue
+		}
+		dependencyId := lucytypes.PackageId{
+			Platform: id.Platform,
+			Name:     lucytypes.PackageName(slug),
+			Version:  lucytypes.LatestCompatibleVersion,
+		}
+		switch d.RelationType {
+		case relationRequiredDependency:
+			dependencies.Required = append(dependencies.Required, dependencyId)
+		case relationOptionalDependency:
+			dependencies.Optional = append(dependencies.Optional, dependencyId)
+		case relationIncompatible:
+			dependencies.Incompatible = append(dependencies.Incompatible, dependencyId)
+		}
+	}
+
+	return dependencies, nil
+}
+
+func getModBySlug(slug lucytypes.PackageName) (m *mod, err error) {
+	query := url.Values{}
+	query.Set("slug", slug.String())
+	res := &modsResponse{}
+	if err := get(searchUrl(query), res); err != nil {
+		return nil, err
+	}
+	for _, m := range res.Data {
+		if lucytypes.PackageName(m.Slug).Eq(slug) {
+			return &m, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrorNotFound, slug)
+}
+
+// getSlugs maps mod ids to slugs in one request. Ids that are not found are
+// left out.
+func getSlugs(modIds []int) (slugs map[int]string, err error) {
+	slugs = map[int]string{}
+	if len(modIds) == 0 {
+		return slugs, nil
+	}
+	res := &modsResponse{}
+	err = post(modsUrl(), map[string]any{"modIds": modIds}, res)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range res.Data {
+		slugs[m.Id] = m.Slug
+	}
+	return slugs, nil
+}
+
+// listFiles goes through every page of the files of a mod. The API gives them
+// newest first.
+func listFiles(modId int, platform lucytypes.Platform) (files []file, err error) {
+	query := url.Values{}
+	query.Set("pageSize", strconv.Itoa(filesPageSize))
+	if loader := modLoaderType(platform); loader != 0 {
+		query.Set("modLoaderType", strconv.Itoa(loader))
+	}
+	// Quilt can also load Fabric files, which are filtered by fileSupportsPlatform
+	// instead
+	if platform == lucytypes.Quilt {
+		query.Del("modLoaderType")
+	}
+	for index := 0; ; index += filesPageSize {
+		query.Set("index", strconv.Itoa(index))
+		res := &filesResponse{}
+		if err := get(filesUrl(modId, query), res); err != nil {
+			return nil, err
+		}
+		for _, f := range res.Data {
+			if f.IsAvailable && fileSupportsPlatform(&f, platform) {
+				files = append(files, f)
+			}
+		}
+		if len(res.Data) < filesPageSize || index+filesPageSize >= res.Pagination.TotalCount {
+			break
+		}
+	}
+	return files, nil
+}
+
+// getFile is named as so because a Package in lucy is equivalent to a file in
+// CurseForge.
+func getFile(m *mod, id lucytypes.PackageId) (f *file, err error) {
+	files, err := listFiles(m.Id, id.Platform)
+	if err != nil {
+		return nil, err
+	}
+	files = slices.DeleteFunc(
+		files, func(f file) bool {
+			return !syntax.SatisfiesConstraint(id, fileVersion(&f))
+		},
+	)
+
+	switch id.Version {
+	case lucytypes.AllVersion, lucytypes.NoVersion, lucytypes.LatestCompatibleVersion:
+		f = latestCompatibleFile(files)
+	case lucytypes.LatestVersion:
+		f = latestFile(files)
+	default:
+		for i := range files {
+			if fileMatchesVersion(&files[i], id.Version) {
+				f = &files[i]
+				break
+			}
+		}
+	}
+	if f == nil {
+		return nil, fmt.Errorf("%w: %s", ErrorVersionNotFound, id.String())
+	}
+	return f, nil
+}
+
+func latestFile(files []file) (f *file) {
+	for i := range files {
+		if files[i].ReleaseType == releaseTypeRelease &&
+			(f == nil || files[i].FileDate.After(f.FileDate)) {
+			f = &files[i]
+		}
+	}
+	return f
+}
+
+func latestCompatibleFile(files []file) (f *file) {
+	serverInfo := local.GetServerInfo()
+	if serverInfo.Executable == local.UnknownExecutable {
+		logger.Info("no executable found, unable to infer a compatible version. falling back to latest version")
+		return latestFile(files)
+	}
+	var compatible []file
+	for _, candidate := range files {
+		if !fileSupportsPlatform(&candidate, serverInfo.Executable.Platform) {
+			continue
+		}
+		gameVersions, _ := splitGameVersions(candidate.GameVersions)
+		for _, v := range gameVersions {
+			if v == serverInfo.Executable.GameVersion {
+				compatible = append(compatible, candidate)
+				break
+			}
+		}
+	}
+	return latestFile(compatible)
+}
+
+func getDownloadUrl(modId int, fileId int) (u string, err error) {
+	res := &stringResponse{}
+	err = get(downloadUrlUrl(modId, fileId), res)
+	if errors.Is(err, ErrorNotFound) || (err == nil && res.Data == "") {
+		return "", ErrorDistributionDisallowed
+	}
+	if err != nil {
+		return "", err
+	}
+	return res.Data, nil
+}
+
+func fileToPackage(id lucytypes.PackageId, m *mod, f *file) *lucytypes.Package {
+	p := &lucytypes.Package{
+		Id: lucytypes.PackageId{
+			Platform: id.Platform,
+			Name:     lucytypes.PackageName(m.Slug),
+			Version:  fileVersion(f),
+		},
+		Remote: &lucytypes.PackageRemote{
+			Source:    lucytypes.CurseForge,
+			RemoteId:  strconv.Itoa(m.Id),
+			FileUrl:   f.DownloadUrl,
+			Filename:  f.FileName,
+			Size:      f.FileLength,
+			Published: f.FileDate,
+		},
+	}
+	for _, h := range f.Hashes {
+		if h.Algo == hashAlgoSha1 {
+			p.Remote.Sha1 = h.Value
+		}
+	}
+	return p
+}
+
+// fileVersion guesses the version from the file name, by taking everything after
+// the first hyphen that is followed by a digit. For example, the version of
+// "fabric-api-0.92.2+1.20.1.jar" is "0.92.2+1.20.1". The file name without its
+// extension is used if there is no such hyphen.
+func fileVersion(f *file) lucytypes.PackageVersion {
+	name := strings.TrimSuffix(f.FileName, path.Ext(f.FileName))
+	for i := 0; i+1 < len(name); i++ {
+		if name[i] == '-' && name[i+1] >= '0' && name[i+1] <= '9' {
+			return lucytypes.PackageVersion(name[i+1:])
+		}
+	}
+	return lucytypes.PackageVersion(name)
+}
+
+// fileMatchesVersion accepts the guessed version, the display name, the file
+// name and the file id, since none of them is the version number for sure. The
+// guessed version often has the game version and the loader in it, so it only
+// has to be the same as version when compared leniently.
+func fileMatchesVersion(f *file, version lucytypes.PackageVersion) bool {
+	v := version.String()
+	if strings.EqualFold(fileVersion(f).String(), v) ||
+		strings.EqualFold(f.DisplayName, v) ||
+		strings.EqualFold(f.FileName, v) ||
+		strconv.Itoa(f.Id) == v {
+		return true
+	}
+	c, err := syntax.ComparePackageVersions(
+		&lucytypes.PackageId{Version: fileVersion(f)},
+		&lucytypes.PackageId{Version: version},
+	)
+	return err == nil && c == 0
+}
+
+// splitGameVersions separates the game versions in a file's gameVersions from
+// the loaders. Other entries such as "Server" or "Java 17" are dropped.
+func splitGameVersions(entries []string) (
+	gameVersions []string,
+	platforms []lucytypes.Platform,
+) {
+	for _, entry := range entries {
+		if entry == "" {
+			continue
+		}
+		if entry[0] >= '0' && entry[0] <= '9' {
+			gameVersions = append(gameVersions, entry)
+			continue
+		}
+		platform := lucytypes.Platform(strings.ToLower(entry))
+		if platform.Valid() && platform != lucytypes.AllPlatform &&
+			platform != lucytypes.UnknownPlatform {
+			platforms = append(platforms, platform)
+		}
+	}
+	return gameVersions, platforms
+}
+
+// fileSupportsPlatform accepts Fabric files for Quilt, which can load them. A
+// file that lists no loader at all is accepted for any platform.
+func fileSupportsPlatform(f *file, platform lucytypes.Platform) bool {
+	_, platforms := splitGameVersions(f.GameVersions)
+	if len(platforms) == 0 {
+		return true
+	}
+	for _, p := range platforms {
+		if p.Eq(platform) || (platform == lucytypes.Quilt && p == lucytypes.Fabric) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	htmlLineBreaks = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</li>|</h[1-6]>`)
+	htmlTags       = regexp.MustCompile(`<[^>]*>`)
+	blankLines     = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlToPlainText is for mod descriptions, which CurseForge gives in HTML.
+func htmlToPlainText(s string) string {
+	s = htmlLineBreaks.ReplaceAllString(s, "\n")
+	s = htmlTags.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	s = blankLines.ReplaceAllString(s, "\n\n")
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package curseforge
+
+import "time"
+
+// The types here are trimmed down to what Lucy uses. For the full schemas, see:
+// https://docs.curseforge.com/rest-api/#schemas
+
+type modResponse struct {
+	Data mod `json:"data"`
+}
+
+type modsResponse struct {
+	Data       []mod      `json:"data"`
+	Pagination pagination `json:"pagination"`
+}
+
+type filesResponse struct {
+	Data       []file     `json:"data"`
+	Pagination pagination `json:"pagination"`
+}
+
+type stringResponse struct {
+	Data string `json:"data"`
+}
+
+type pagination struct {
+	Index       int `json:"index"`
+	PageSize    int `json:"pageSize"`
+	ResultCount int `json:"resultCount"`
+	TotalCount  int `json:"totalCount"`
+}
+
+// mod is a project on CurseForge. Its slug is what Lucy uses as the package
+// name.
+type mod struct {
+	Id      int    `json:"id"`
+	GameId  int    `json:"gameId"`
+	Name    string `json:"name"`
+	Slug    string `json:"slug"`
+	Summary string `json:"summary"`
+	Links   struct {
+		WebsiteUrl string `json:"websiteUrl"`
+		WikiUrl    string `json:"wikiUrl"`
+		IssuesUrl  string `json:"issuesUrl"`
+		SourceUrl  string `json:"sourceUrl"`
+	} `json:"links"`
+	ClassId       int `json:"classId"`
+	DownloadCount int `json:"downloadCount"`
+	Authors       []struct {
+		Id   int    `json:"id"`
+		Name string `json:"name"`
+		Url  string `json:"url"`
+	} `json:"authors"`
+	DateModified         time.Time `json:"dateModified"`
+	AllowModDistribution *bool     `json:"allowModDistribution"`
+}
+
+// file is a version of a mod, a CurseForge file is equivalent to a package in
+// Lucy.
+type file struct {
+	Id          int       `json:"id"`
+	ModId       int       `json:"modId"`
+	IsAvailable bool      `json:"isAvailable"`
+	DisplayName string    `json:"displayName"`
+	FileName    string    `json:"fileName"`
+	ReleaseType int       `json:"releaseType"`
+	Hashes      []hash    `json:"hashes"`
+	FileDate    time.Time `json:"fileDate"`
+	FileLength  int64     `json:"fileLength"`
+	DownloadUrl string    `json:"downloadUrl"`
+	// GameVersions mixes game versions with loader names and sides, for example
+	// ["1.20.1", "Fabric", "Server"].
+	GameVersions []string     `json:"gameVersions"`
+	Dependencies []dependency `json:"dependencies"`
+}
+
+type hash struct {
+	Value string `json:"value"`
+	Algo  int    `json:"algo"`
+}
+
+const (
+	hashAlgoSha1 = 1
+	hashAlgoMd5  = 2
+)
+
+const (
+	releaseTypeRelease = 1
+	releaseTypeBeta    = 2
+	releaseTypeAlpha   = 3
+)
+
+type dependency struct {
+	ModId        int `json:"modId"`
+	RelationType int `json:"relationType"`
+}
+
+const (
+	relationEmbeddedLibrary    = 1
+	relationOptionalDependency = 2
+	relationRequiredDependency = 3
+	relationTool               = 4
+	relationIncompatible       = 5
+	relationInclude            = 6
+)
@@ -0,0 +1,272 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package curseforge
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"lucy/lucyerrors"
+	"lucy/lucytypes"
+	"lucy/testutil"
+	"lucy/util"
+)
+
+const testApiKey = "test-key"
+
+var testJar = []byte("not really a jar")
+
+// standIn is a CurseForge API with one mod, jei, and its files. downloadUrl
+// is what the download-url endpoint gives, a 404 if it is empty.
+type standIn struct {
+	*httptest.Server
+	files       []file
+	downloadUrl string
+	queries     []string
+}
+
+func newStandIn(t *testing.T) *standIn {
+	t.Helper()
+	s := &standIn{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/mods/search", func(w http.ResponseWriter, r *http.Request) {
+		s.queries = append(s.queries, r.URL.RawQuery)
+		res := modsResponse{Data: []mod{}}
+		query := r.URL.Query()
+		if query.Get("slug") == "jei" || strings.Contains(query.Get("searchFilter"), "jei") {
+			res.Data = append(res.Data, mod{Id: 238222, Slug: "jei", Name: "Just Enough Items"})
+		}
+		if query.Get("searchFilter") != "" {
+			res.Data = append(res.Data, mod{Id: 1, Slug: "jei-addon"})
+		}
+		res.Pagination.TotalCount = len(res.Data)
+		testutil.WriteJson(w, res)
+	})
+	mux.HandleFunc("GET /v1/mods/238222/files", func(w http.ResponseWriter, r *http.Request) {
+		s.queries = append(s.queries, r.URL.RawQuery)
+		testutil.WriteJson(w, filesResponse{Data: s.files, Pagination: pagination{TotalCount: len(s.files)}})
+	})
+	mux.HandleFunc("GET /v1/mods/238222/files/{file}/download-url", func(w http.ResponseWriter, r *http.Request) {
+		if s.downloadUrl == "" {
+			http.NotFound(w, r)
+			return
+		}
+		testutil.WriteJson(w, stringResponse{Data: s.downloadUrl})
+	})
+	mux.HandleFunc("POST /v1/mods", func(w http.ResponseWriter, r *http.Request) {
+		testutil.WriteJson(w, modsResponse{Data: []mod{{Id: 419699, Slug: "architectury-api"}}})
+	})
+	mux.HandleFunc("GET /files/jei.jar", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(testJar)
+	})
+	s.Server = testutil.StandIn(t,
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasPrefix(r.URL.Path, "/files/") && r.Header.Get("x-api-key") != testApiKey {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			mux.ServeHTTP(w, r)
+		}),
+		&ApiBaseUrl,
+	)
+	t.Setenv(ApiKeyEnv, testApiKey)
+	return s
+}
+
+// jeiFile is a file of jei for Fabric, with its jar at the stand-in.
+func (s *standIn) jeiFile(id int, version string, date time.Time) file {
+	digest := sha1.Sum(testJar)
+	return file{
+		Id:           id,
+		ModId:        238222,
+		IsAvailable:  true,
+		DisplayName:  "jei-1.20.1-fabric-" + version,
+		FileName:     "jei-1.20.1-fabric-" + version + ".jar",
+		ReleaseType:  releaseTypeRelease,
+		Hashes:       []hash{{Value: hex.EncodeToString(digest[:]), Algo: hashAlgoSha1}},
+		FileDate:     date,
+		FileLength:   int64(len(testJar)),
+		DownloadUrl:  s.URL + "/files/jei.jar",
+		GameVersions: []string{"1.20.1", "Fabric", "Server"},
+		Dependencies: []dependency{
+			{ModId: 419699, RelationType: relationRequiredDependency},
+			{ModId: 238223, RelationType: relationEmbeddedLibrary},
+		},
+	}
+}
+
+func jei(version lucytypes.PackageVersion) lucytypes.PackageId {
+	return lucytypes.PackageId{Platform: lucytypes.Fabric, Name: "jei", Version: version}
+}
+
+func TestSearch(t *testing.T) {
+	s := newStandIn(t)
+
+	result, err := Search(jei(lucytypes.AllVersion), lucytypes.SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Source != lucytypes.CurseForge {
+		t.Errorf("source = %s, want curseforge", result.Source)
+	}
+	if strings.Join(result.Results, ",") != "jei,jei-addon" {
+		t.Errorf("results = %v, want [jei jei-addon]", result.Results)
+	}
+	if len(s.queries) != 1 || !strings.Contains(s.queries[0], "modLoaderType=4") {
+		t.Errorf("queries = %v, want a search for fabric mods", s.queries)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	s := newStandIn(t)
+	day := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	s.files = []file{
+		s.jeiFile(3, "15.3.0.4", day.AddDate(0, 0, 2)),
+		s.jeiFile(2, "15.2.0.27", day.AddDate(0, 0, 1)),
+		s.jeiFile(1, "15.1.0.1", day),
+	}
+
+	tests := []struct {
+		version lucytypes.PackageVersion
+		want    lucytypes.PackageVersion
+	}{
+		{lucytypes.LatestVersion, "1.20.1-fabric-15.3.0.4"},
+		{"15.2.0.27", "1.20.1-fabric-15.2.0.27"},
+		{"jei-1.20.1-fabric-15.1.0.1.jar", "1.20.1-fabric-15.1.0.1"},
+		{"2", "1.20.1-fabric-15.2.0.27"},
+	}
+	for _, tt := range tests {
+		p, err := Resolve(jei(tt.version))
+		if err != nil {
+			t.Errorf("Resolve(%s): %v", tt.version, err)
+			continue
+		}
+		if p.Id.Version != tt.want {
+			t.Errorf("Resolve(%s) = %s, want %s", tt.version, p.Id.Version, tt.want)
+		}
+		if p.Remote.Source != lucytypes.CurseForge || p.Remote.RemoteId != "238222" {
+			t.Errorf("Resolve(%s) remote = %+v", tt.version, p.Remote)
+		}
+		required := p.Dependencies.Required
+		if len(required) != 1 || required[0].Name != "architectury-api" {
+			t.Errorf("Resolve(%s) requires %v, want only architectury-api", tt.version, required)
+		}
+	}
+
+	_, err := Resolve(jei("16.0.0"))
+	if !errors.Is(err, ErrorVersionNotFound) {
+		t.Errorf("Resolve(16.0.0) error = %v, want %v", err, ErrorVersionNotFound)
+	}
+}
+
+func TestResolveNotFound(t *testing.T) {
+	newStandIn(t)
+	_, err := Resolve(lucytypes.PackageId{Platform: lucytypes.Fabric, Name: "no-such-mod", Version: lucytypes.LatestVersion})
+	if lucyerrors.KindOf(err) != lucyerrors.NotFoundError {
+		t.Errorf("error = %v, want a not found error", err)
+	}
+}
+
+func TestResolveDownloadUrl(t *testing.T) {
+	s := newStandIn(t)
+	f := s.jeiFile(2, "15.2.0.27", time.Now())
+	f.DownloadUrl = ""
+	s.files = []file{f}
+
+	// The url is asked for separately when the file does not have it
+	s.downloadUrl = s.URL + "/files/jei.jar"
+	p, err := Resolve(jei("15.2.0.27"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Remote.FileUrl != s.downloadUrl {
+		t.Errorf("url = %q, want %q", p.Remote.FileUrl, s.downloadUrl)
+	}
+
+	// Or the author does not allow downloads outside CurseForge
+	s.downloadUrl = ""
+	_, err = Resolve(jei("15.2.0.27"))
+	if !errors.Is(err, ErrorDistributionDisallowed) {
+		t.Errorf("error = %v, want %v", err, ErrorDistributionDisallowed)
+	}
+}
+
+func TestResolvedFileDownloads(t *testing.T) {
+	s := newStandIn(t)
+	s.files = []file{s.jeiFile(2, "15.2.0.27", time.Now())}
+	testutil.ServerDir(t)
+	if err := util.InstallLucy(); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := Resolve(jei("15.2.0.27"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := util.DownloadFile(p.Remote, p.Id.Name.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(testJar) {
+		t.Errorf("downloaded %q, want %q", data, testJar)
+	}
+
+	// The sha1 that CurseForge gives is checked
+	p.Remote.Sha1 = strings.Repeat("0", 40)
+	_, err = util.DownloadFile(p.Remote, p.Id.Name.String())
+	if !errors.Is(err, lucyerrors.ChecksumMismatchError) {
+		t.Errorf("error = %v, want %v", err, lucyerrors.ChecksumMismatchError)
+	}
+}
+
+func TestNoApiKey(t *testing.T) {
+	newStandIn(t)
+	testutil.ServerDir(t)
+	t.Setenv(ApiKeyEnv, "")
+	_, err := Resolve(jei(lucytypes.LatestVersion))
+	if !errors.Is(err, ErrorNoApiKey) {
+		t.Errorf("error = %v, want %v", err, ErrorNoApiKey)
+	}
+}
+
+func TestFileVersion(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     lucytypes.PackageVersion
+	}{
+		{"fabric-api-0.92.2+1.20.1.jar", "0.92.2+1.20.1"},
+		{"jei-1.20.1-fabric-15.2.0.27.jar", "1.20.1-fabric-15.2.0.27"},
+		{"Xaeros_Minimap_24.2.0_Fabric_1.20.jar", "Xaeros_Minimap_24.2.0_Fabric_1.20"},
+		{"create-1.20.1-0.5.1.f.jar", "1.20.1-0.5.1.f"},
+	}
+	for _, tt := range tests {
+		if got := fileVersion(&file{FileName: tt.filename}); got != tt.want {
+			t.Errorf("fileVersion(%s) = %s, want %s", tt.filename, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package curseforge
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+
+	"lucy/logger"
+	"lucy/lucyerrors"
+	"lucy/lucytypes"
+	"lucy/tools"
+	"lucy/util"
+)
+
+// ApiBaseUrl is the root of the CurseForge Core API. It is a variable so it can
+// be pointed at a mirror or a stand-in server.
+var ApiBaseUrl = "https://api.curseforge.com"
+
+// ApiKeyEnv is checked before the curseforge_api_key field in the config file.
+const ApiKeyEnv = "CURSEFORGE_API_KEY"
+
+var ErrorNoApiKey = errors.New(
+	"no curseforge api key, set " + ApiKeyEnv + " or curseforge_api_key in " + util.ConfigFile,
+)
+
+// minecraftGameId and modsClassId are fixed ids in CurseForge's database.
+const (
+	minecraftGameId = 432
+	modsClassId     = 6
+)
+
+// apiKey is not memoized, as the config file can be created during a run
+// (by `lucy init`).
+func apiKey() (key string, err error) {
+	if key = os.Getenv(ApiKeyEnv); key != "" {
+		return key, nil
+	}
+	config, err := util.ReadConfig()
+	if err == nil && config.CurseForgeApiKey != "" {
+		return config.CurseForgeApiKey, nil
+	}
+	return "", ErrorNoApiKey
+}
+
+func apiUrl(endpoint string, query url.Values) string {
+	u, _ := url.JoinPath(ApiBaseUrl, endpoint)
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+func searchUrl(query url.Values) string {
+	query.Set("gameId", strconv.Itoa(minecraftGameId))
+	query.Set("classId", strconv.Itoa(modsClassId))
+	return apiUrl("/v1/mods/search", query)
+}
+
+func modUrl(modId int) string {
+	return apiUrl("/v1/mods/"+strconv.Itoa(modId), nil)
+}
+
+func modDescriptionUrl(modId int) string {
+	return apiUrl("/v1/mods/"+strconv.Itoa(modId)+"/description", nil)
+}
+
+func modsUrl() string {
+	return apiUrl("/v1/mods", nil)
+}
+
+func filesUrl(modId int, query url.Values) string {
+	return apiUrl("/v1/mods/"+strconv.Itoa(modId)+"/files", query)
+}
+
+func downloadUrlUrl(modId int, fileId int) string {
+	return apiUrl(
+		"/v1/mods/"+strconv.Itoa(modId)+"/files/"+strconv.Itoa(fileId)+"/download-url",
+		nil,
+	)
+}
+
+// modLoaderType gives CurseForge's ModLoaderType for the platform, 0 (any) for
+// platforms it does not know.
+func modLoaderType(platform lucytypes.Platform) int {
+	switch platform {
+	case lucytypes.Forge:
+		return 1
+	case lucytypes.Fabric:
+		return 4
+	case lucytypes.Quilt:
+		return 5
+	case lucytypes.Neoforge:
+		return 6
+	default:
+		return 0
+	}
+}
+
+func get(u string, v any) error {
+	return request(http.MethodGet, u, nil, v)
+}
+
+func post(u string, body any, v any) error {
+	return request(http.MethodPost, u, body, v)
+}
+
+// request sends an authenticated request and decodes the JSON response into v.
+func request(method string, u string, body any, v any) error {
+	key, err := apiKey()
+	if err != nil {
+		return err
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, u, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("x-api-key", key)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	logger.Debug("requesting curseforge api: " + method + " " + u)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer tools.CloseReader(res.Body, logger.Warning)
+	if res.StatusCode == http.StatusNotFound {
+		return ErrorNotFound
+	}
+	if err := lucyerrors.CheckResponse(res); err != nil {
+		return err
+	}
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: %w", ErrorInvalidAPIResponse, err)
+	}
+	return nil
+}
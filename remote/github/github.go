@@ -0,0 +1,381 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package github resolves packages that are only published as jars on GitHub
+// Releases. Neither the name nor the version of a mod can be told from a release,
+// so the assets are downloaded and analyzed like installed mods until one fits
+// the server.
+//
+// A package from GitHub is specified by its repository, see
+// lucytypes.GitHubPrefix. Its remote id is "owner/repo@tag", which records where
+// later lookups of the package should go back to.
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	gogithub "github.com/google/go-github/v50/github"
+
+	"lucy/local"
+	"lucy/logger"
+	"lucy/lucyerrors"
+	"lucy/lucytypes"
+	"lucy/syntax"
+	"lucy/tools"
+	"lucy/util"
+)
+
+var (
+	ErrorInvalidRepository = lucyerrors.New(lucyerrors.PackageSyntaxError, "invalid github repository")
+	ErrorReleaseNotFound   = lucyerrors.New(lucyerrors.NotFoundError, "github release not found")
+	ErrorNoMatchingAsset   = lucyerrors.New(lucyerrors.IncompatibleError, "no release asset matches the server")
+)
+
+// releasesToTry limits how many releases are inspected when looking for a
+// compatible one, as every candidate asset has to be downloaded.
+const releasesToTry = 10
+
+// Resolve picks the newest release that has a jar for the server, unless a tag
+// is given. A tag can be given with or without its leading "v", so the version
+// of an installed package finds the release it came from.
+func Resolve(id lucytypes.PackageId) (p *lucytypes.Package, err error) {
+	owner, repo, err := splitRepo(id.Name)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newClient()
+	if err != nil {
+		return nil, err
+	}
+	releases, err := listReleases(client, owner, repo, id)
+	if err != nil {
+		return nil, err
+	}
+
+	serverInfo := local.GetServerInfo()
+	gameVersion := ""
+	if serverInfo.Executable != local.UnknownExecutable {
+		gameVersion = serverInfo.Executable.GameVersion
+	}
+	for _, release := range releases {
+		p, err = pickAsset(owner+"/"+repo, release, id.Platform, gameVersion)
+		if err != nil {
+			return nil, err
+		}
+		if p != nil {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrorNoMatchingAsset, id.StringVersion())
+}
+
+func Fetch(id lucytypes.PackageId) (remote *lucytypes.PackageRemote, err error) {
+	p, err := Resolve(id)
+	if err != nil {
+		return nil, err
+	}
+	return p.Remote, nil
+}
+
+func splitRepo(name lucytypes.PackageName) (owner string, repo string, err error) {
+	fullName, ok := name.GitHubRepo()
+	if !ok {
+		return "", "", fmt.Errorf("%w: %s", ErrorInvalidRepository, name)
+	}
+	owner, repo, found := strings.Cut(fullName, "/")
+	if !found || owner == "" || repo == "" {
+		return "", "", fmt.Errorf("%w: %s", ErrorInvalidRepository, name)
+	}
+	return owner, repo, nil
+}
+
+// listReleases gives the candidate releases, newest first. Drafts and
+// prereleases are only used when their tag is given. With a version
+// constraint, the tags without their leading "v" are checked against it.
+func listReleases(
+	client *gogithub.Client,
+	owner, repo string,
+	id lucytypes.PackageId,
+) (releases []*gogithub.RepositoryRelease, err error) {
+	ctx := context.Background()
+	version := id.Version
+
+	switch version {
+	case lucytypes.AllVersion, lucytypes.NoVersion, lucytypes.LatestCompatibleVersion:
+		// More releases are listed when some may be left out by the constraint
+		perPage := tools.Ternary(id.Constraint == nil, releasesToTry, 100)
+		list, _, err := client.Repositories.ListReleases(
+			ctx,
+			owner,
+			repo,
+			&gogithub.ListOptions{PerPage: perPage},
+		)
+		if err != nil {
+			return nil, apiError(err)
+		}
+		for _, release := range list {
+			tag := lucytypes.PackageVersion(strings.TrimPrefix(release.GetTagName(), "v"))
+			if !release.GetDraft() && !release.GetPrerelease() &&
+				syntax.SatisfiesConstraint(id, tag) && len(releases) < releasesToTry {
+				releases = append(releases, release)
+			}
+		}
+	case lucytypes.LatestVersion:
+		release, res, err := client.Repositories.GetLatestRelease(ctx, owner, repo)
+		if isNotFound(res) {
+			break
+		}
+		if err != nil {
+			return nil, apiError(err)
+		}
+		releases = append(releases, release)
+	default:
+		tags := []string{version.String()}
+		if !strings.HasPrefix(version.String(), "v") {
+			tags = append(tags, "v"+version.String())
+		}
+		for _, tag := range tags {
+			release, res, err := client.Repositories.GetReleaseByTag(ctx, owner, repo, tag)
+			if isNotFound(res) {
+				continue
+			}
+			if err != nil {
+				return nil, apiError(err)
+			}
+			releases = append(releases, release)
+			break
+		}
+	}
+
+	if len(releases) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrorReleaseNotFound, id.StringVersion())
+	}
+	return releases, nil
+}
+
+func isNotFound(res *gogithub.Response) bool {
+	return res != nil && res.StatusCode == http.StatusNotFound
+}
+
+// apiError gives the kind of an error from the GitHub API, in the same way as
+// lucyerrors.CheckResponse. Unauthenticated requests are limited to 60 an hour,
+// so being rate limited is not unusual.
+func apiError(err error) error {
+	var rateLimitErr *gogithub.RateLimitError
+	var abuseErr *gogithub.AbuseRateLimitError
+	var responseErr *gogithub.ErrorResponse
+	switch {
+	case errors.As(err, &rateLimitErr), errors.As(err, &abuseErr):
+		return lucyerrors.Of(lucyerrors.RateLimitedError, err)
+	case errors.As(err, &responseErr) && responseErr.Response != nil:
+		switch status := responseErr.Response.StatusCode; {
+		case status == http.StatusNotFound:
+			return lucyerrors.Of(lucyerrors.NotFoundError, err)
+		case status >= 500:
+			return lucyerrors.Of(lucyerrors.NetworkError, err)
+		}
+	}
+	return err
+}
+
+// pickAsset downloads the candidate jars of the release one by one, and gives
+// the first package in them that runs on the platform and the game version. A
+// nil package is given if none of them does. The jar of the package is kept as
+// its LocalCopy, so it is not downloaded again to be installed.
+//
+// An empty platform or game version is not checked, and neither is the game
+// version of a plugin, as plugins only declare the lowest version they support.
+func pickAsset(
+	repo string,
+	release *gogithub.RepositoryRelease,
+	platform lucytypes.Platform,
+	gameVersion string,
+) (*lucytypes.Package, error) {
+	for _, asset := range candidateAssets(release.Assets, platform, gameVersion) {
+		remote := &lucytypes.PackageRemote{
+			Source:    lucytypes.GitHub,
+			RemoteId:  repo + "@" + release.GetTagName(),
+			FileUrl:   asset.GetBrowserDownloadURL(),
+			Filename:  asset.GetName(),
+			Size:      int64(asset.GetSize()),
+			Changelog: release.GetBody(),
+			Published: release.GetPublishedAt().Time,
+		}
+		filename, err := cachedAsset(remote, asset.GetID())
+		if err != nil {
+			return nil, err
+		}
+		packages, digest, err := inspectAsset(filename, platform)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range packages {
+			// A Fabric mod is also taken on Quilt
+			if !runsOn(string(p.Id.Platform), platform) {
+				continue
+			}
+			if gameVersion != "" && !platform.IsBukkit() &&
+				p.Dependencies != nil &&
+				len(p.Dependencies.SupportedVersions) != 0 &&
+				!slices.Contains(
+					p.Dependencies.SupportedVersions,
+					lucytypes.PackageVersion(gameVersion),
+				) {
+				continue
+			}
+			remote.Sha512 = digest
+			remote.LocalCopy = filename
+			p.Local = nil
+			p.Remote = remote
+			return &p, nil
+		}
+		_ = os.Remove(filename)
+	}
+	return nil, nil
+}
+
+// cachedAsset gives the path of the asset in the cache, where it is downloaded
+// to if it is not there yet. Assets are kept by their ids, as the file of an
+// asset does not change.
+func cachedAsset(remote *lucytypes.PackageRemote, id int64) (string, error) {
+	filename := path.Join(util.CachePath, "github", strconv.FormatInt(id, 10), remote.Filename)
+	if _, err := os.Stat(filename); err == nil {
+		return filename, nil
+	}
+	logger.Info("inspecting " + remote.Filename + " from " + remote.RemoteId)
+	downloaded, err := util.DownloadFile(remote, "github")
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(path.Dir(filename), 0o755); err != nil {
+		_ = os.Remove(downloaded.Name())
+		return "", err
+	}
+	if err := os.Rename(downloaded.Name(), filename); err != nil {
+		_ = os.Remove(downloaded.Name())
+		return "", err
+	}
+	return filename, nil
+}
+
+// inspectAsset analyzes a downloaded asset. The digest is kept so the copy of
+// the file made at installation can be verified against it.
+func inspectAsset(filename string, platform lucytypes.Platform) (
+	packages []lucytypes.Package,
+	digest string,
+	err error,
+) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() { _ = file.Close() }()
+
+	digest, err = util.FileSha512(filename)
+	if err != nil {
+		return nil, "", err
+	}
+	return local.AnalyzeJar(file, platform), digest, nil
+}
+
+// candidateAssets leaves out jars that are not meant to be installed, and
+// jars whose name mentions only loaders that the platform cannot run. The rest
+// are sorted so names mentioning the game version or the platform are tried
+// first.
+func candidateAssets(
+	assets []*gogithub.ReleaseAsset,
+	platform lucytypes.Platform,
+	gameVersion string,
+) (candidates []*gogithub.ReleaseAsset) {
+	for _, asset := range assets {
+		name := strings.ToLower(asset.GetName())
+		if !strings.HasSuffix(name, ".jar") {
+			continue
+		}
+		words := assetNameWords(name)
+		if slices.Contains(words, "sources") ||
+			slices.Contains(words, "javadoc") ||
+			slices.Contains(words, "dev") {
+			continue
+		}
+		mentioned, runnable := false, false
+		for _, loader := range knownLoaders {
+			if slices.Contains(words, loader) {
+				mentioned = true
+				runnable = runnable || runsOn(loader, platform)
+			}
+		}
+		if mentioned && !runnable {
+			continue
+		}
+		candidates = append(candidates, asset)
+	}
+
+	score := func(asset *gogithub.ReleaseAsset) (s int) {
+		name := strings.ToLower(asset.GetName())
+		if gameVersion != "" && strings.Contains(name, gameVersion) {
+			s += 2
+		}
+		if slices.Contains(assetNameWords(name), string(platform)) {
+			s += 1
+		}
+		return s
+	}
+	slices.SortStableFunc(
+		candidates,
+		func(a, b *gogithub.ReleaseAsset) int { return score(b) - score(a) },
+	)
+	return candidates
+}
+
+// knownLoaders are the words in asset names that tell what a jar is made for.
+var knownLoaders = []string{
+	"fabric", "forge", "neoforge", "quilt", "bukkit", "spigot", "paper", "purpur",
+}
+
+// runsOn tells whether a jar made for the loader can be used on the platform.
+// Any loader is accepted if the platform is not known.
+func runsOn(loader string, platform lucytypes.Platform) bool {
+	switch {
+	case platform == lucytypes.AllPlatform, loader == string(platform):
+		return true
+	case platform == lucytypes.Quilt:
+		return loader == string(lucytypes.Fabric)
+	case platform.IsBukkit():
+		return loader == "bukkit" || lucytypes.Platform(loader).IsBukkit()
+	}
+	return false
+}
+
+// assetNameWords splits a file name into its alphanumeric words, so "neoforge"
+// is not mistaken for "forge".
+func assetNameWords(name string) []string {
+	return strings.FieldsFunc(
+		name,
+		func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+		},
+	)
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	gogithub "github.com/google/go-github/v50/github"
+)
+
+// ApiBaseUrl is the root of the GitHub REST API. It is a variable so it can be
+// pointed at a stand-in server, and ApiUrlEnv takes precedence over it, so it
+// can also be pointed at a GitHub Enterprise instance.
+var ApiBaseUrl = "https://api.github.com/"
+
+// ApiUrlEnv and TokenEnv are the variables that GitHub Actions sets as well.
+// A token is optional, it only raises the rate limit.
+const (
+	ApiUrlEnv = "GITHUB_API_URL"
+	TokenEnv  = "GITHUB_TOKEN"
+)
+
+func newClient() (*gogithub.Client, error) {
+	base := ApiBaseUrl
+	if env := os.Getenv(ApiUrlEnv); env != "" {
+		base = env
+	}
+	// The client resolves every endpoint relative to the base
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := &http.Client{}
+	if token := os.Getenv(TokenEnv); token != "" {
+		httpClient.Transport = &tokenTransport{token: token}
+	}
+	client := gogithub.NewClient(httpClient)
+	client.BaseURL = u
+	return client, nil
+}
+
+type tokenTransport struct {
+	token string
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	return http.DefaultTransport.RoundTrip(req)
+}
@@ -0,0 +1,377 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	gogithub "github.com/google/go-github/v50/github"
+
+	"lucy/lucyerrors"
+	"lucy/lucytypes"
+	"lucy/testutil"
+	"lucy/util"
+)
+
+// standIn is the GitHub API of one repository, lucy/mod, with its releases
+// newest first. The assets are served under /assets/, and counted in
+// downloads. Every API request is answered with status if it is set.
+type standIn struct {
+	*httptest.Server
+	releases      []*gogithub.RepositoryRelease
+	jars          map[string][]byte
+	downloads     int
+	status        int
+	authorization string
+}
+
+func newStandIn(t *testing.T) *standIn {
+	t.Helper()
+	s := &standIn{jars: map[string][]byte{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/lucy/mod/releases", func(w http.ResponseWriter, r *http.Request) {
+		testutil.WriteJson(w, s.releases)
+	})
+	mux.HandleFunc("GET /repos/lucy/mod/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		for _, release := range s.releases {
+			if !release.GetDraft() && !release.GetPrerelease() {
+				testutil.WriteJson(w, release)
+				return
+			}
+		}
+		notFound(w)
+	})
+	mux.HandleFunc("GET /repos/lucy/mod/releases/tags/{tag}", func(w http.ResponseWriter, r *http.Request) {
+		for _, release := range s.releases {
+			if release.GetTagName() == r.PathValue("tag") {
+				testutil.WriteJson(w, release)
+				return
+			}
+		}
+		notFound(w)
+	})
+	mux.HandleFunc("GET /assets/{name}", func(w http.ResponseWriter, r *http.Request) {
+		s.downloads++
+		_, _ = w.Write(s.jars[r.PathValue("name")])
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) { notFound(w) })
+	s.Server = testutil.StandIn(t,
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/assets/"+r.PathValue("name") {
+				s.authorization = r.Header.Get("Authorization")
+			}
+			switch s.status {
+			case 0:
+				mux.ServeHTTP(w, r)
+			case http.StatusForbidden:
+				// What GitHub gives once the hourly limit is used up
+				w.Header().Set("X-RateLimit-Limit", "60")
+				w.Header().Set("X-RateLimit-Remaining", "0")
+				w.Header().Set("X-RateLimit-Reset", "1893456000")
+				w.WriteHeader(http.StatusForbidden)
+				testutil.WriteJson(w, map[string]string{"message": "API rate limit exceeded"})
+			default:
+				w.WriteHeader(s.status)
+			}
+		}),
+		&ApiBaseUrl,
+	)
+	t.Setenv(ApiUrlEnv, "")
+	t.Setenv(TokenEnv, "")
+	return s
+}
+
+func notFound(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNotFound)
+	testutil.WriteJson(w, map[string]string{"message": "Not Found"})
+}
+
+// release adds a release with the jars as its assets.
+func (s *standIn) release(tag string, prerelease bool, jars map[string][]byte) {
+	release := &gogithub.RepositoryRelease{
+		TagName:     gogithub.String(tag),
+		Body:        gogithub.String("changes in " + tag),
+		Prerelease:  gogithub.Bool(prerelease),
+		PublishedAt: &gogithub.Timestamp{Time: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for name, jar := range jars {
+		s.jars[name] = jar
+		release.Assets = append(release.Assets, &gogithub.ReleaseAsset{
+			ID:                 gogithub.Int64(int64(len(s.jars))),
+			Name:               gogithub.String(name),
+			Size:               gogithub.Int(len(jar)),
+			BrowserDownloadURL: gogithub.String(s.URL + "/assets/" + name),
+		})
+	}
+	s.releases = append(s.releases, release)
+}
+
+func makeJar(t *testing.T, name, content string) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	entry, err := w.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := entry.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func fabricJar(t *testing.T, version string) []byte {
+	return makeJar(t, "fabric.mod.json", `{"schemaVersion": 1, "id": "mod", "version": "`+version+`"}`)
+}
+
+func forgeJar(t *testing.T, version string) []byte {
+	return makeJar(t, "META-INF/mods.toml", "[[mods]]\nmodId = \"mod\"\nversion = \""+version+"\"\n")
+}
+
+// newRepo is the stand-in with these releases, in a directory with lucy
+// installed, so assets can be downloaded:
+//   - v2.0.0, a prerelease
+//   - v1.1.0, with jars for Fabric and Forge, its sources and a readme
+//   - v1.0.0, with a jar for Fabric
+//   - v0.9.0, with a jar for Forge
+//   - v0.1.0, with no jar at all
+func newRepo(t *testing.T) *standIn {
+	t.Helper()
+	s := newStandIn(t)
+	testutil.ServerDir(t)
+	if err := util.InstallLucy(); err != nil {
+		t.Fatal(err)
+	}
+	s.release("v2.0.0", true, map[string][]byte{"mod-2.0.0-fabric.jar": fabricJar(t, "2.0.0")})
+	s.release("v1.1.0", false, map[string][]byte{
+		"mod-1.1.0-sources.jar": fabricJar(t, "1.1.0"),
+		"mod-1.1.0-forge.jar":   forgeJar(t, "1.1.0"),
+		"mod-1.1.0-fabric.jar":  fabricJar(t, "1.1.0"),
+		"README.md":             []byte("# mod"),
+	})
+	s.release("v1.0.0", false, map[string][]byte{"mod-1.0.0-fabric.jar": fabricJar(t, "1.0.0")})
+	s.release("v0.9.0", false, map[string][]byte{"mod-0.9.0-forge.jar": forgeJar(t, "0.9.0")})
+	s.release("v0.1.0", false, map[string][]byte{"mod-0.1.0.zip": []byte("not a jar")})
+	return s
+}
+
+func mod(platform lucytypes.Platform, version lucytypes.PackageVersion) lucytypes.PackageId {
+	return lucytypes.PackageId{Platform: platform, Name: lucytypes.GitHubPrefix + "lucy/mod", Version: version}
+}
+
+func TestResolve(t *testing.T) {
+	newRepo(t)
+
+	tests := []struct {
+		id       lucytypes.PackageId
+		want     lucytypes.PackageVersion
+		filename string
+		tag      string
+	}{
+		{mod(lucytypes.Fabric, lucytypes.AllVersion), "1.1.0", "mod-1.1.0-fabric.jar", "v1.1.0"},
+		{mod(lucytypes.Forge, lucytypes.AllVersion), "1.1.0", "mod-1.1.0-forge.jar", "v1.1.0"},
+		{mod(lucytypes.Fabric, lucytypes.LatestVersion), "1.1.0", "mod-1.1.0-fabric.jar", "v1.1.0"},
+		{mod(lucytypes.Fabric, "1.0.0"), "1.0.0", "mod-1.0.0-fabric.jar", "v1.0.0"},
+		{mod(lucytypes.Fabric, "v2.0.0"), "2.0.0", "mod-2.0.0-fabric.jar", "v2.0.0"},
+		{mod(lucytypes.Forge, "0.9.0"), "0.9.0", "mod-0.9.0-forge.jar", "v0.9.0"},
+		// Quilt runs the jar for Fabric
+		{mod(lucytypes.Quilt, lucytypes.AllVersion), "1.1.0", "mod-1.1.0-fabric.jar", "v1.1.0"},
+	}
+	for _, tt := range tests {
+		p, err := Resolve(tt.id)
+		if err != nil {
+			t.Errorf("Resolve(%s): %v", tt.id.String(), err)
+			continue
+		}
+		if p.Id.Name != "mod" || p.Id.Version != tt.want {
+			t.Errorf("Resolve(%s) = %s, want mod@%s", tt.id.String(), p.Id.StringVersion(), tt.want)
+		}
+		remote := p.Remote
+		if remote.Source != lucytypes.GitHub || remote.Filename != tt.filename ||
+			remote.RemoteId != "lucy/mod@"+tt.tag || remote.Sha512 == "" {
+			t.Errorf("Resolve(%s) remote = %+v", tt.id.String(), remote)
+		}
+	}
+}
+
+func TestResolveKeepsPickedAsset(t *testing.T) {
+	s := newRepo(t)
+
+	p, err := Resolve(mod(lucytypes.Fabric, "1.0.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.downloads != 1 || p.Remote.LocalCopy == "" {
+		t.Fatalf("%d downloads, local copy %q", s.downloads, p.Remote.LocalCopy)
+	}
+
+	// Installing copies the inspected jar, and it is not inspected again
+	f, err := util.DownloadFile(p.Remote, "mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(content, s.jars["mod-1.0.0-fabric.jar"]) {
+		t.Error("the copy is not the jar")
+	}
+	if _, err := Resolve(mod(lucytypes.Fabric, "1.0.0")); err != nil {
+		t.Fatal(err)
+	}
+	if s.downloads != 1 {
+		t.Errorf("%d downloads, want 1", s.downloads)
+	}
+}
+
+func TestResolveNoMatchingAsset(t *testing.T) {
+	newRepo(t)
+
+	tests := []lucytypes.PackageId{
+		// Only a jar for Forge
+		mod(lucytypes.Fabric, "0.9.0"),
+		// No jar at all
+		mod(lucytypes.Fabric, "0.1.0"),
+	}
+	for _, id := range tests {
+		_, err := Resolve(id)
+		if !errors.Is(err, ErrorNoMatchingAsset) {
+			t.Errorf("Resolve(%s): error = %v, want %v", id.String(), err, ErrorNoMatchingAsset)
+		}
+		if kind := lucyerrors.KindOf(err); kind != lucyerrors.IncompatibleError {
+			t.Errorf("Resolve(%s): kind = %v, want incompatible", id.String(), kind)
+		}
+	}
+}
+
+func TestResolveNotFound(t *testing.T) {
+	newRepo(t)
+
+	tests := []struct {
+		name string
+		id   lucytypes.PackageId
+	}{
+		{"unknown tag", mod(lucytypes.Fabric, "3.0.0")},
+		{"unknown repository", lucytypes.PackageId{
+			Platform: lucytypes.Fabric,
+			Name:     lucytypes.GitHubPrefix + "lucy/nothing",
+			Version:  lucytypes.AllVersion,
+		}},
+	}
+	for _, tt := range tests {
+		_, err := Resolve(tt.id)
+		if kind := lucyerrors.KindOf(err); kind != lucyerrors.NotFoundError {
+			t.Errorf("%s: error = %v, want not found", tt.name, err)
+		}
+	}
+}
+
+func TestResolveInvalidRepository(t *testing.T) {
+	for _, name := range []lucytypes.PackageName{"mod", "github:mod", "github:/mod", "github:lucy/"} {
+		_, err := Resolve(lucytypes.PackageId{Platform: lucytypes.Fabric, Name: name})
+		if !errors.Is(err, ErrorInvalidRepository) {
+			t.Errorf("Resolve(%s): error = %v, want %v", name, err, ErrorInvalidRepository)
+		}
+	}
+}
+
+func TestApiErrors(t *testing.T) {
+	s := newStandIn(t)
+
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusForbidden, lucyerrors.RateLimitedError},
+		{http.StatusBadGateway, lucyerrors.NetworkError},
+	}
+	for _, tt := range tests {
+		s.status = tt.status
+		_, err := Resolve(mod(lucytypes.Fabric, lucytypes.AllVersion))
+		if kind := lucyerrors.KindOf(err); kind != tt.want {
+			t.Errorf("status %d: error = %v, want kind %v", tt.status, err, tt.want)
+		}
+	}
+}
+
+func TestClientEnvironment(t *testing.T) {
+	s := newStandIn(t)
+	ApiBaseUrl = "http://127.0.0.1:0"
+	t.Setenv(ApiUrlEnv, s.URL)
+	t.Setenv(TokenEnv, "secret")
+
+	// The release is not needed, only the request
+	_, err := Resolve(mod(lucytypes.Fabric, "1.0.0"))
+	if !errors.Is(err, ErrorReleaseNotFound) {
+		t.Errorf("error = %v, want %v", err, ErrorReleaseNotFound)
+	}
+	if s.authorization != "Bearer secret" {
+		t.Errorf("authorization = %q", s.authorization)
+	}
+}
+
+func TestCandidateAssets(t *testing.T) {
+	assets := func(names ...string) (assets []*gogithub.ReleaseAsset) {
+		for _, name := range names {
+			assets = append(assets, &gogithub.ReleaseAsset{Name: gogithub.String(name)})
+		}
+		return assets
+	}
+	tests := []struct {
+		name        string
+		platform    lucytypes.Platform
+		gameVersion string
+		want        []string
+	}{
+		{"neoforge is not forge", lucytypes.Forge, "", []string{"mod-forge.jar", "mod.jar"}},
+		{"quilt runs fabric", lucytypes.Quilt, "", []string{"mod-fabric.jar", "mod-fabric-1.20.1.jar", "mod.jar"}},
+		{"paper runs bukkit", lucytypes.Paper, "", []string{"mod-bukkit.jar", "mod.jar"}},
+		{"game version first", lucytypes.Fabric, "1.20.1", []string{"mod-fabric-1.20.1.jar", "mod-fabric.jar", "mod.jar"}},
+		{"any platform", lucytypes.AllPlatform, "", []string{
+			"mod-fabric.jar", "mod-forge.jar", "mod-neoforge.jar", "mod-bukkit.jar", "mod-fabric-1.20.1.jar", "mod.jar",
+		}},
+	}
+	for _, tt := range tests {
+		got := candidateAssets(
+			assets(
+				"mod-fabric.jar", "mod-forge.jar", "mod-neoforge.jar", "mod-bukkit.jar",
+				"mod-fabric-1.20.1.jar", "mod.jar", "mod-sources.jar", "mod-dev.jar", "mod.zip",
+			),
+			tt.platform,
+			tt.gameVersion,
+		)
+		var names []string
+		for _, asset := range got {
+			names = append(names, asset.GetName())
+		}
+		if !slices.Equal(names, tt.want) {
+			t.Errorf("%s: candidates = %v, want %v", tt.name, names, tt.want)
+		}
+	}
+}
@@ -0,0 +1,396 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package hangar provides functions to interact with the Hangar API, the plugin
+// repository of PaperMC.
+//
+// We here use Hangar terms in private functions:
+//   - Project: A project is a plugin, identified by its slug.
+//   - Version: A version is a release of a project, which is a package in Lucy.
+//     A version can provide files for several platforms.
+package hangar
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+
+	"lucy/local"
+	"lucy/logger"
+	"lucy/lucyerrors"
+	"lucy/lucytypes"
+	"lucy/syntax"
+	"lucy/tools"
+)
+
+var (
+	ErrorInvalidAPIResponse  = errors.New("invalid data from hangar api")
+	ErrorNotFound            = lucyerrors.New(lucyerrors.NotFoundError, "not found on hangar")
+	ErrorVersionNotFound     = lucyerrors.New(lucyerrors.NotFoundError, "hangar version not found")
+	ErrorUnsupportedPlatform = lucyerrors.New(lucyerrors.IncompatibleError, "platform not supported by hangar")
+)
+
+// versionsPageSize is the largest page size the API accepts.
+const versionsPageSize = 25
+
+// Search
+//
+// For Hangar search API, see:
+// https://hangar.papermc.io/api-docs#get-/api/v1/projects
+//
+// Every plugin on Hangar runs on servers, so options.ShowClientPackage has no
+// effect.
+func Search(
+	packageId lucytypes.PackageId,
+	options lucytypes.SearchOptions,
+) (result *lucytypes.SearchResults, err error) {
+	query := url.Values{}
+	query.Set("q", packageId.Name.String())
+	query.Set("limit", strconv.Itoa(versionsPageSize))
+	if packageId.Platform != lucytypes.AllPlatform {
+		platform := hangarPlatform(packageId.Platform)
+		if platform == "" {
+			return nil, fmt.Errorf("%w: %s", ErrorUnsupportedPlatform, packageId.Platform)
+		}
+		query.Set("platform", platform)
+	}
+	if sort := options.IndexBy.ToHangar(); sort != "" {
+		query.Set("sort", sort)
+	}
+
+	res := &projectsResponse{}
+	if err := get(searchUrl(query), res); err != nil {
+		return nil, err
+	}
+	if res.Pagination.Count > len(res.Result) {
+		logger.Info(
+			strconv.Itoa(res.Pagination.Count) + " results found on hangar, only showing first " +
+				strconv.Itoa(len(res.Result)),
+		)
+	}
+
+	result = &lucytypes.SearchResults{}
+	result.Results = make([]string, 0, len(res.Result))
+	result.Source = lucytypes.Hangar
+	for _, p := range res.Result {
+		result.Results = append(result.Results, p.Namespace.Slug)
+	}
+	return result, nil
+}
+
+func Information(slug lucytypes.PackageName) (
+	information *lucytypes.PackageInformation,
+	err error,
+) {
+	p := &project{}
+	if err := get(projectUrl(slug), p); err != nil {
+		return nil, err
+	}
+
+	information = &lucytypes.PackageInformation{
+		Name:        p.Name,
+		Brief:       p.Description,
+		Description: p.Description,
+		Author:      []lucytypes.PackageMember{},
+		Urls:        []lucytypes.PackageUrl{},
+		License:     p.Settings.License.Name,
+	}
+	if page, err := getBytes(projectPageUrl(slug)); err != nil {
+		logger.Warning(err)
+	} else {
+		information.Description = tools.MarkdownToPlainText(string(page))
+	}
+
+	// Fill in URLs
+	information.Urls = append(
+		information.Urls,
+		lucytypes.PackageUrl{
+			Name: "Homepage",
+			Type: lucytypes.HomepageUrl,
+			Url:  "https://hangar.papermc.io/" + p.Namespace.Owner + "/" + p.Namespace.Slug,
+		},
+	)
+	for _, group := range p.Settings.Links {
+		for _, link := range group.Links {
+			if link.Url == "" {
+				continue
+			}
+			information.Urls = append(
+				information.Urls,
+				lucytypes.PackageUrl{
+					Name: link.Name,
+					Type: lucytypes.OthersUrl,
+					Url:  link.Url,
+				},
+			)
+		}
+	}
+
+	// Fill in authors
+	members := &membersResponse{}
+	if err := get(projectMembersUrl(slug), members); err != nil {
+		logger.Warning(err)
+	}
+	for _, m := range members.Result {
+		role := ""
+		if len(m.Roles) > 0 {
+			role = m.Roles[0].Title
+		}
+		information.Author = append(
+			information.Author,
+			lucytypes.PackageMember{
+				Name: m.User,
+				Role: role,
+				Url:  "https://hangar.papermc.io/" + m.User,
+			},
+		)
+	}
+
+	return information, nil
+}
+
+// ListVersions gives every version of the package that has a file for
+// id.Platform, newest first, with the remote of each filled in.
+func ListVersions(id lucytypes.PackageId) (packages []lucytypes.Package, err error) {
+	versions, err := listVersions(id)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range versions {
+		packages = append(packages, *versionToPackage(id, &v))
+	}
+	return packages, nil
+}
+
+// Resolve infers the version of id and gives the package with its remote and
+// dependencies filled in, which is what gets pinned in lucy.lock.
+func Resolve(id lucytypes.PackageId) (p *lucytypes.Package, err error) {
+	v, err := getVersion(id)
+	if err != nil {
+		return nil, err
+	}
+	p = versionToPackage(id, v)
+	// The version is already known, so it is not looked up again as
+	// Dependencies would
+	p.Dependencies = versionDependencies(id, v)
+	return p, nil
+}
+
+func Fetch(id lucytypes.PackageId) (
+	remote *lucytypes.PackageRemote,
+	err error,
+) {
+	p, err := Resolve(id)
+	if err != nil {
+		return nil, err
+	}
+	return p.Remote, nil
+}
+
+// Dependencies gives the plugin dependencies of the version that id resolves
+// to. A dependency that is not hosted on Hangar is still recorded by its name.
+func Dependencies(id lucytypes.PackageId) (
+	dependencies *lucytypes.PackageDependencies,
+	err error,
+) {
+	v, err := getVersion(id)
+	if err != nil {
+		return nil, err
+	}
+	return versionDependencies(id, v), nil
+}
+
+// versionDependencies gives the dependencies of a version for the platform of
+// id.
+func versionDependencies(
+	id lucytypes.PackageId,
+	v *version,
+) (dependencies *lucytypes.PackageDependencies) {
+	platform := hangarPlatform(id.Platform)
+	dependencies = &lucytypes.PackageDependencies{
+		SupportedVersions:  []lucytypes.PackageVersion{},
+		SupportedPlatforms: []lucytypes.Platform{},
+		Required:           []lucytypes.PackageId{},
+	}
+	for _, gameVersion := range v.PlatformDependencies[platform] {
+		dependencies.SupportedVersions = append(
+			dependencies.SupportedVersions,
+			lucytypes.PackageVersion(gameVersion),
+		)
+	}
+	for hangarPlatform := range v.Downloads {
+		switch hangarPlatform {
+		case "PAPER":
+			dependencies.SupportedPlatforms = append(
+				dependencies.SupportedPlatforms,
+				lucytypes.Paper,
+				lucytypes.Purpur,
+			)
+		case "VELOCITY":
+			dependencies.SupportedPlatforms = append(dependencies.SupportedPlatforms, lucytypes.Velocity)
+		case "WATERFALL":
+			dependencies.SupportedPlatforms = append(dependencies.SupportedPlatforms, lucytypes.Waterfall)
+		}
+	}
+
+	for _, d := range v.PluginDependencies[platform] {
+		dependencyId := lucytypes.PackageId{
+			Platform: id.Platform,
+			Name:     lucytypes.PackageName(d.Name),
+			Version:  lucytypes.LatestCompatibleVersion,
+		}
+		if d.Required {
+			dependencies.Required = append(dependencies.Required, dependencyId)
+		} else {
+			dependencies.Optional = append(dependencies.Optional, dependencyId)
+		}
+	}
+	return dependencies
+}
+
+// listVersions goes through every page of the versions of a project that have
+// a file for the platform. The API gives them newest first.
+func listVersions(id lucytypes.PackageId) (versions []version, err error) {
+	platform := hangarPlatform(id.Platform)
+	if platform == "" {
+		return nil, fmt.Errorf("%w: %s", ErrorUnsupportedPlatform, id.Platform)
+	}
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(versionsPageSize))
+	query.Set("platform", platform)
+	for offset := 0; ; offset += versionsPageSize {
+		query.Set("offset", strconv.Itoa(offset))
+		res := &versionsResponse{}
+		if err := get(versionsUrl(id.Name, query), res); err != nil {
+			return nil, err
+		}
+		for _, v := range res.Result {
+			if _, ok := v.Downloads[platform]; ok {
+				versions = append(versions, v)
+			}
+		}
+		if len(res.Result) < versionsPageSize || offset+versionsPageSize >= res.Pagination.Count {
+			break
+		}
+	}
+	return versions, nil
+}
+
+func getVersion(id lucytypes.PackageId) (v *version, err error) {
+	platform := hangarPlatform(id.Platform)
+	if platform == "" {
+		return nil, fmt.Errorf("%w: %s", ErrorUnsupportedPlatform, id.Platform)
+	}
+
+	switch id.Version {
+	case lucytypes.AllVersion, lucytypes.NoVersion, lucytypes.LatestCompatibleVersion,
+		lucytypes.LatestVersion:
+		versions, err := listVersions(id)
+		if err != nil {
+			return nil, err
+		}
+		versions = slices.DeleteFunc(
+			versions, func(v version) bool {
+				return !syntax.SatisfiesConstraint(id, lucytypes.PackageVersion(v.Name))
+			},
+		)
+		if id.Version == lucytypes.LatestVersion {
+			v = latestVersion(versions)
+		} else {
+			v = latestCompatibleVersion(versions, platform)
+		}
+	default:
+		v = &version{}
+		err = get(versionUrl(id.Name, id.Version.String()), v)
+		if errors.Is(err, ErrorNotFound) {
+			v = nil
+		} else if err != nil {
+			return nil, err
+		} else if _, ok := v.Downloads[platform]; !ok {
+			v = nil
+		}
+	}
+	if v == nil {
+		return nil, fmt.Errorf("%w: %s", ErrorVersionNotFound, id.String())
+	}
+	return v, nil
+}
+
+func latestVersion(versions []version) (v *version) {
+	for i := range versions {
+		if versions[i].Channel.Name == releaseChannel &&
+			(v == nil || versions[i].CreatedAt.After(v.CreatedAt)) {
+			v = &versions[i]
+		}
+	}
+	return v
+}
+
+func latestCompatibleVersion(versions []version, platform string) (v *version) {
+	serverInfo := local.GetServerInfo()
+	if serverInfo.Executable == local.UnknownExecutable {
+		logger.Info("no executable found, unable to infer a compatible version. falling back to latest version")
+		return latestVersion(versions)
+	}
+	var compatible []version
+	for _, candidate := range versions {
+		if slices.Contains(
+			candidate.PlatformDependencies[platform],
+			serverInfo.Executable.GameVersion,
+		) {
+			compatible = append(compatible, candidate)
+		}
+	}
+	return latestVersion(compatible)
+}
+
+// versionToPackage uses the external url of a file that is not hosted on
+// Hangar, which cannot be verified.
+func versionToPackage(id lucytypes.PackageId, v *version) *lucytypes.Package {
+	d := v.Downloads[hangarPlatform(id.Platform)]
+	remote := &lucytypes.PackageRemote{
+		Source:    lucytypes.Hangar,
+		RemoteId:  strconv.Itoa(v.Id),
+		FileUrl:   d.DownloadUrl,
+		Changelog: v.Description,
+		Published: v.CreatedAt,
+	}
+	if d.FileInfo != nil {
+		remote.Filename = d.FileInfo.Name
+		remote.Sha256 = d.FileInfo.Sha256Hash
+		remote.Size = d.FileInfo.SizeBytes
+	}
+	if remote.FileUrl == "" {
+		remote.FileUrl = d.ExternalUrl
+	}
+	if remote.Filename == "" && remote.FileUrl != "" {
+		if u, err := url.Parse(remote.FileUrl); err == nil {
+			remote.Filename = path.Base(u.Path)
+		}
+	}
+
+	return &lucytypes.Package{
+		Id: lucytypes.PackageId{
+			Platform: id.Platform,
+			Name:     id.Name,
+			Version:  lucytypes.PackageVersion(v.Name),
+		},
+		Remote: remote,
+	}
+}
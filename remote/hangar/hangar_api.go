@@ -0,0 +1,115 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hangar
+
+import "time"
+
+// The types here are trimmed down to what Lucy uses. For the full schemas, see:
+// https://hangar.papermc.io/api-docs
+
+type pagination struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+	Count  int `json:"count"`
+}
+
+type projectsResponse struct {
+	Pagination pagination `json:"pagination"`
+	Result     []project  `json:"result"`
+}
+
+type versionsResponse struct {
+	Pagination pagination `json:"pagination"`
+	Result     []version  `json:"result"`
+}
+
+type project struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Namespace   struct {
+		Owner string `json:"owner"`
+		Slug  string `json:"slug"`
+	} `json:"namespace"`
+	Stats struct {
+		Downloads int `json:"downloads"`
+		Stars     int `json:"stars"`
+	} `json:"stats"`
+	Category    string    `json:"category"`
+	LastUpdated time.Time `json:"lastUpdated"`
+	Settings    struct {
+		Links []struct {
+			Title string `json:"title"`
+			Links []struct {
+				Name string `json:"name"`
+				Url  string `json:"url"`
+			} `json:"links"`
+		} `json:"links"`
+		License struct {
+			Name string `json:"name"`
+			Url  string `json:"url"`
+			Type string `json:"type"`
+		} `json:"license"`
+	} `json:"settings"`
+}
+
+type member struct {
+	User  string `json:"user"`
+	Roles []struct {
+		Title string `json:"title"`
+	} `json:"roles"`
+}
+
+type membersResponse struct {
+	Result []member `json:"result"`
+}
+
+// version is a release of a project, which is a package in Lucy. Downloads and
+// dependencies are keyed by Hangar's platform names, see hangarPlatform.
+type version struct {
+	Id          int       `json:"id"`
+	CreatedAt   time.Time `json:"createdAt"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Channel     struct {
+		Name string `json:"name"`
+	} `json:"channel"`
+	Downloads            map[string]download           `json:"downloads"`
+	PluginDependencies   map[string][]pluginDependency `json:"pluginDependencies"`
+	PlatformDependencies map[string][]string           `json:"platformDependencies"`
+}
+
+type download struct {
+	FileInfo *struct {
+		Name       string `json:"name"`
+		SizeBytes  int64  `json:"sizeBytes"`
+		Sha256Hash string `json:"sha256Hash"`
+	} `json:"fileInfo"`
+	// ExternalUrl is set instead of FileInfo and DownloadUrl if the file is not
+	// hosted on Hangar.
+	ExternalUrl string `json:"externalUrl"`
+	DownloadUrl string `json:"downloadUrl"`
+}
+
+type pluginDependency struct {
+	Name        string `json:"name"`
+	Required    bool   `json:"required"`
+	ExternalUrl string `json:"externalUrl"`
+}
+
+// releaseChannel is the channel that every project on Hangar starts with.
+const releaseChannel = "Release"
@@ -0,0 +1,385 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hangar
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"lucy/lucyerrors"
+	"lucy/lucytypes"
+	"lucy/testutil"
+)
+
+// standIn is a Hangar API with one project, ViaVersion, and its versions, newest
+// first as the API gives them. Every request is answered with a 429 if
+// rateLimited is set.
+type standIn struct {
+	*httptest.Server
+	versions    []version
+	rateLimited bool
+	queries     []string
+}
+
+func newStandIn(t *testing.T) *standIn {
+	t.Helper()
+	s := &standIn{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /projects", func(w http.ResponseWriter, r *http.Request) {
+		s.queries = append(s.queries, r.URL.RawQuery)
+		res := projectsResponse{}
+		if r.URL.Query().Get("q") == "ViaVersion" {
+			res.Result = append(res.Result, viaVersion(), viaBackwards())
+		}
+		res.Pagination.Count = len(res.Result)
+		testutil.WriteJson(w, res)
+	})
+	mux.HandleFunc("GET /projects/ViaVersion", func(w http.ResponseWriter, r *http.Request) {
+		testutil.WriteJson(w, viaVersion())
+	})
+	mux.HandleFunc("GET /pages/main/ViaVersion", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("# ViaVersion\n\nAllows newer clients to **join** older servers."))
+	})
+	mux.HandleFunc("GET /projects/ViaVersion/members", func(w http.ResponseWriter, r *http.Request) {
+		res := membersResponse{Result: []member{{User: "kennytv"}}}
+		res.Result[0].Roles = append(res.Result[0].Roles, struct {
+			Title string `json:"title"`
+		}{Title: "Owner"})
+		testutil.WriteJson(w, res)
+	})
+	mux.HandleFunc("GET /projects/ViaVersion/versions", func(w http.ResponseWriter, r *http.Request) {
+		s.queries = append(s.queries, r.URL.RawQuery)
+		query := r.URL.Query()
+		offset, _ := strconv.Atoi(query.Get("offset"))
+		limit, _ := strconv.Atoi(query.Get("limit"))
+		res := versionsResponse{Result: []version{}}
+		for i := offset; i < offset+limit && i < len(s.versions); i++ {
+			res.Result = append(res.Result, s.versions[i])
+		}
+		res.Pagination = pagination{Limit: limit, Offset: offset, Count: len(s.versions)}
+		testutil.WriteJson(w, res)
+	})
+	mux.HandleFunc("GET /projects/ViaVersion/versions/{name}", func(w http.ResponseWriter, r *http.Request) {
+		for _, v := range s.versions {
+			if v.Name == r.PathValue("name") {
+				testutil.WriteJson(w, v)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	})
+	s.Server = testutil.StandIn(t,
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if s.rateLimited {
+				w.Header().Set("Retry-After", "60")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			mux.ServeHTTP(w, r)
+		}),
+		&ApiBaseUrl,
+	)
+	return s
+}
+
+func viaVersion() project {
+	p := project{Id: 1, Name: "ViaVersion", Description: "Allows newer clients to join older servers"}
+	p.Namespace.Owner = "ViaVersion"
+	p.Namespace.Slug = "ViaVersion"
+	p.Settings.License.Name = "GPL"
+	p.Settings.Links = append(p.Settings.Links, struct {
+		Title string `json:"title"`
+		Links []struct {
+			Name string `json:"name"`
+			Url  string `json:"url"`
+		} `json:"links"`
+	}{Title: "Top"})
+	p.Settings.Links[0].Links = append(p.Settings.Links[0].Links, struct {
+		Name string `json:"name"`
+		Url  string `json:"url"`
+	}{Name: "Source", Url: "https://github.com/ViaVersion/ViaVersion"}, struct {
+		Name string `json:"name"`
+		Url  string `json:"url"`
+	}{Name: "Empty"})
+	return p
+}
+
+func viaBackwards() project {
+	p := project{Id: 2, Name: "ViaBackwards"}
+	p.Namespace.Owner = "ViaVersion"
+	p.Namespace.Slug = "ViaBackwards"
+	return p
+}
+
+// paperVersion is a version of ViaVersion with a file hosted on Hangar for
+// Paper, supporting gameVersions.
+func paperVersion(name, channel string, created time.Time, gameVersions ...string) version {
+	v := version{
+		Id:        len(name),
+		Name:      name,
+		CreatedAt: created,
+		Downloads: map[string]download{
+			"PAPER": {
+				DownloadUrl: "https://hangarcdn.papermc.io/plugins/ViaVersion/ViaVersion/versions/" +
+					name + "/PAPER/ViaVersion-" + name + ".jar",
+			},
+		},
+		PlatformDependencies: map[string][]string{"PAPER": gameVersions},
+		PluginDependencies: map[string][]pluginDependency{
+			"PAPER": {
+				{Name: "ProtocolLib", Required: true},
+				{Name: "ViaBackwards", Required: false},
+			},
+		},
+	}
+	v.Channel.Name = channel
+	d := v.Downloads["PAPER"]
+	d.FileInfo = &struct {
+		Name       string `json:"name"`
+		SizeBytes  int64  `json:"sizeBytes"`
+		Sha256Hash string `json:"sha256Hash"`
+	}{Name: "ViaVersion-" + name + ".jar", SizeBytes: 5 << 20, Sha256Hash: "abc"}
+	v.Downloads["PAPER"] = d
+	return v
+}
+
+func via(platform lucytypes.Platform, version lucytypes.PackageVersion) lucytypes.PackageId {
+	return lucytypes.PackageId{Platform: platform, Name: "ViaVersion", Version: version}
+}
+
+var day = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func TestSearch(t *testing.T) {
+	s := newStandIn(t)
+
+	result, err := Search(via(lucytypes.Paper, lucytypes.AllVersion), lucytypes.SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Source != lucytypes.Hangar {
+		t.Errorf("source = %s, want hangar", result.Source)
+	}
+	if len(result.Results) != 2 || result.Results[0] != "ViaVersion" || result.Results[1] != "ViaBackwards" {
+		t.Errorf("results = %v", result.Results)
+	}
+	if query := s.queries[0]; query != "limit=25&platform=PAPER&q=ViaVersion" {
+		t.Errorf("query = %s", query)
+	}
+}
+
+func TestSearchUnsupportedPlatform(t *testing.T) {
+	newStandIn(t)
+
+	_, err := Search(via(lucytypes.Fabric, lucytypes.AllVersion), lucytypes.SearchOptions{})
+	if !errors.Is(err, ErrorUnsupportedPlatform) {
+		t.Errorf("error = %v, want %v", err, ErrorUnsupportedPlatform)
+	}
+	if kind := lucyerrors.KindOf(err); kind != lucyerrors.IncompatibleError {
+		t.Errorf("kind = %v, want incompatible", kind)
+	}
+}
+
+func TestInformation(t *testing.T) {
+	newStandIn(t)
+
+	information, err := Information("ViaVersion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if information.Name != "ViaVersion" || information.License != "GPL" {
+		t.Errorf("information = %+v", information)
+	}
+	if information.Description == information.Brief {
+		t.Error("description is not taken from the main page")
+	}
+	if len(information.Author) != 1 || information.Author[0].Name != "kennytv" ||
+		information.Author[0].Role != "Owner" {
+		t.Errorf("authors = %+v", information.Author)
+	}
+	// The homepage and the source, not the link without an url
+	if len(information.Urls) != 2 {
+		t.Errorf("urls = %+v", information.Urls)
+	}
+}
+
+func TestListVersionsPages(t *testing.T) {
+	s := newStandIn(t)
+	for i := 30; i > 0; i-- {
+		s.versions = append(s.versions, paperVersion(fmt.Sprintf("5.0.%d", i), releaseChannel, day.AddDate(0, 0, i), "1.21"))
+	}
+	velocityOnly := paperVersion("5.0.0", releaseChannel, day, "1.21")
+	velocityOnly.Downloads = map[string]download{"VELOCITY": velocityOnly.Downloads["PAPER"]}
+	s.versions = append(s.versions, velocityOnly)
+
+	packages, err := ListVersions(via(lucytypes.Paper, lucytypes.AllVersion))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packages) != 30 {
+		t.Fatalf("got %d versions, want 30", len(packages))
+	}
+	if packages[0].Id.Version != "5.0.30" || packages[29].Id.Version != "5.0.1" {
+		t.Errorf("versions from %s to %s", packages[0].Id.Version, packages[29].Id.Version)
+	}
+	if len(s.queries) != 2 {
+		t.Errorf("%d pages requested, want 2", len(s.queries))
+	}
+}
+
+func TestResolve(t *testing.T) {
+	s := newStandIn(t)
+	s.versions = []version{
+		paperVersion("5.1.0-SNAPSHOT", "Snapshot", day.AddDate(0, 0, 3), "1.21"),
+		paperVersion("5.0.3", releaseChannel, day.AddDate(0, 0, 2), "1.21"),
+		paperVersion("5.0.2", releaseChannel, day.AddDate(0, 0, 1), "1.20.6"),
+	}
+
+	tests := []struct {
+		version lucytypes.PackageVersion
+		want    lucytypes.PackageVersion
+	}{
+		{lucytypes.LatestVersion, "5.0.3"},
+		{"5.0.2", "5.0.2"},
+		{"5.1.0-SNAPSHOT", "5.1.0-SNAPSHOT"},
+	}
+	for _, tt := range tests {
+		p, err := Resolve(via(lucytypes.Paper, tt.version))
+		if err != nil {
+			t.Errorf("Resolve(%s): %v", tt.version, err)
+			continue
+		}
+		if p.Id.Version != tt.want {
+			t.Errorf("Resolve(%s) = %s, want %s", tt.version, p.Id.Version, tt.want)
+		}
+		remote := p.Remote
+		if remote.Source != lucytypes.Hangar || remote.Filename != "ViaVersion-"+tt.want.String()+".jar" ||
+			remote.Sha256 != "abc" || remote.FileUrl == "" {
+			t.Errorf("Resolve(%s) remote = %+v", tt.version, remote)
+		}
+	}
+}
+
+func TestResolveDependencies(t *testing.T) {
+	s := newStandIn(t)
+	s.versions = []version{paperVersion("5.0.3", releaseChannel, day, "1.21")}
+
+	p, err := Resolve(via(lucytypes.Paper, lucytypes.LatestVersion))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := p.Dependencies
+	if d == nil || len(d.Required) != 1 || d.Required[0].Name != "ProtocolLib" ||
+		len(d.Optional) != 1 || len(d.SupportedVersions) != 1 {
+		t.Errorf("dependencies = %+v", d)
+	}
+	// The dependencies are taken from the version that was already fetched
+	if len(s.queries) != 1 {
+		t.Errorf("%d pages of versions requested, want 1", len(s.queries))
+	}
+}
+
+func TestResolveLatestCompatible(t *testing.T) {
+	s := newStandIn(t)
+	testutil.ServerDir(t)
+	s.versions = []version{paperVersion("5.0.3", releaseChannel, day, "1.21")}
+
+	// Without a server, the latest version is taken
+	p, err := Resolve(via(lucytypes.Paper, lucytypes.LatestCompatibleVersion))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Id.Version != "5.0.3" {
+		t.Errorf("version = %s, want 5.0.3", p.Id.Version)
+	}
+}
+
+func TestResolveExternalUrl(t *testing.T) {
+	s := newStandIn(t)
+	v := paperVersion("5.0.3", releaseChannel, day, "1.21")
+	v.Downloads["PAPER"] = download{ExternalUrl: "https://example.com/files/ViaVersion-5.0.3.jar?dl=1"}
+	s.versions = []version{v}
+
+	p, err := Resolve(via(lucytypes.Paper, "5.0.3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Remote.FileUrl != "https://example.com/files/ViaVersion-5.0.3.jar?dl=1" ||
+		p.Remote.Filename != "ViaVersion-5.0.3.jar" || p.Remote.Sha256 != "" {
+		t.Errorf("remote = %+v", p.Remote)
+	}
+}
+
+func TestResolveNotFound(t *testing.T) {
+	s := newStandIn(t)
+	s.versions = []version{paperVersion("5.0.3", releaseChannel, day, "1.21")}
+
+	tests := []struct {
+		name string
+		id   lucytypes.PackageId
+		want error
+	}{
+		{"unknown project", lucytypes.PackageId{Platform: lucytypes.Paper, Name: "NoSuchPlugin", Version: lucytypes.LatestVersion}, ErrorNotFound},
+		{"unknown version", via(lucytypes.Paper, "4.0.0"), ErrorVersionNotFound},
+		{"no file for platform", via(lucytypes.Velocity, "5.0.3"), ErrorVersionNotFound},
+	}
+	for _, tt := range tests {
+		_, err := Resolve(tt.id)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.want)
+		}
+		if kind := lucyerrors.KindOf(err); kind != lucyerrors.NotFoundError {
+			t.Errorf("%s: kind = %v, want not found", tt.name, kind)
+		}
+	}
+}
+
+func TestDependencies(t *testing.T) {
+	s := newStandIn(t)
+	s.versions = []version{paperVersion("5.0.3", releaseChannel, day, "1.20.6", "1.21")}
+
+	dependencies, err := Dependencies(via(lucytypes.Paper, "5.0.3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dependencies.SupportedVersions) != 2 || dependencies.SupportedVersions[1] != "1.21" {
+		t.Errorf("supported versions = %v", dependencies.SupportedVersions)
+	}
+	if len(dependencies.SupportedPlatforms) != 2 {
+		t.Errorf("supported platforms = %v", dependencies.SupportedPlatforms)
+	}
+	if len(dependencies.Required) != 1 || dependencies.Required[0].Name != "ProtocolLib" {
+		t.Errorf("required = %v", dependencies.Required)
+	}
+	if len(dependencies.Optional) != 1 || dependencies.Optional[0].Name != "ViaBackwards" {
+		t.Errorf("optional = %v", dependencies.Optional)
+	}
+}
+
+func TestRateLimited(t *testing.T) {
+	s := newStandIn(t)
+	s.rateLimited = true
+
+	_, err := Resolve(via(lucytypes.Paper, lucytypes.LatestVersion))
+	if kind := lucyerrors.KindOf(err); kind != lucyerrors.RateLimitedError {
+		t.Errorf("error = %v, want rate limited", err)
+	}
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hangar
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"lucy/logger"
+	"lucy/lucyerrors"
+	"lucy/lucytypes"
+	"lucy/tools"
+)
+
+// ApiBaseUrl is the root of the Hangar API. It is a variable so it can be
+// pointed at a stand-in server.
+var ApiBaseUrl = "https://hangar.papermc.io/api/v1"
+
+func apiUrl(query url.Values, segments ...string) string {
+	u, _ := url.JoinPath(ApiBaseUrl, segments...)
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+func searchUrl(query url.Values) string {
+	return apiUrl(query, "projects")
+}
+
+func projectUrl(slug lucytypes.PackageName) string {
+	return apiUrl(nil, "projects", slug.String())
+}
+
+func projectMembersUrl(slug lucytypes.PackageName) string {
+	return apiUrl(nil, "projects", slug.String(), "members")
+}
+
+func projectPageUrl(slug lucytypes.PackageName) string {
+	return apiUrl(nil, "pages", "main", slug.String())
+}
+
+func versionsUrl(slug lucytypes.PackageName, query url.Values) string {
+	return apiUrl(query, "projects", slug.String(), "versions")
+}
+
+func versionUrl(slug lucytypes.PackageName, name string) string {
+	return apiUrl(nil, "projects", slug.String(), "versions", name)
+}
+
+// hangarPlatform gives Hangar's name of the platform. Every Bukkit-family
+// server runs plugins made for Paper.
+func hangarPlatform(platform lucytypes.Platform) string {
+	switch {
+	case platform.IsBukkit(), platform == lucytypes.AllPlatform:
+		// Most plugins on Hangar are Paper plugins, so it is assumed when the
+		// platform is not given
+		return "PAPER"
+	case platform == lucytypes.Velocity:
+		return "VELOCITY"
+	case platform == lucytypes.Waterfall:
+		return "WATERFALL"
+	default:
+		return ""
+	}
+}
+
+func get(u string, v any) error {
+	data, err := getBytes(u)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: %w", ErrorInvalidAPIResponse, err)
+	}
+	return nil
+}
+
+func getBytes(u string) ([]byte, error) {
+	logger.Debug("requesting hangar api: " + u)
+	res, err := http.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer tools.CloseReader(res.Body, logger.Warning)
+	if res.StatusCode == http.StatusNotFound {
+		return nil, ErrorNotFound
+	}
+	if err := lucyerrors.CheckResponse(res); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(res.Body)
+}
@@ -14,79 +14,411 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
+// Package mcdr provides MCDR plugins from the MCDR plugin catalogue. The
+// catalogue is read from its aggregated meta, which is the same file MCDR uses
+// for its own plugin manager, see getCatalogue.
 package mcdr
 
 import (
-	"context"
-	"encoding/json"
 	"fmt"
-	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 
-	"github.com/google/go-github/v50/github"
-	"lucy/datatypes"
+	"lucy/local"
+	"lucy/logger"
+	"lucy/lucyerrors"
 	"lucy/lucytypes"
 	"lucy/syntax"
+	"lucy/tools"
 )
 
-func mcdrPluginInfoToPackageInfo(s *datatypes.McdrPluginInfo) *lucytypes.Package {
-	name := lucytypes.PackageName(s.Id)
+var (
+	ErrorNotFound         = lucyerrors.New(lucyerrors.NotFoundError, "plugin not found in mcdr catalogue")
+	ErrorVersionNotFound  = lucyerrors.New(lucyerrors.NotFoundError, "mcdr plugin release not found")
+	ErrorIncompatibleMcdr = lucyerrors.New(lucyerrors.IncompatibleError, "incompatible with the installed mcdr")
+)
 
-	info := &lucytypes.Package{
-		Id: lucytypes.PackageId{
-			Platform: lucytypes.Mcdr,
-			Name:     name,
-			Version:  lucytypes.LatestVersion,
-		},
+// mcdrDependency is the key for MCDR itself in the dependencies of a plugin.
+const mcdrDependency = "mcdreforged"
+
+// Search matches the query against the ids, names, labels and descriptions
+// of the plugins. By relevance, a match in the id ranks above a match in the
+// name, which ranks above the others.
+func Search(
+	packageId lucytypes.PackageId,
+	options lucytypes.SearchOptions,
+) (result *lucytypes.SearchResults, err error) {
+	catalogue, err := getCatalogue()
+	if err != nil {
+		return nil, err
+	}
+	query := normalize(packageId.Name.String())
+
+	type match struct {
+		id        string
+		relevance int
+		downloads int
+		updated   string
+	}
+	var matches []match
+	for id, plugin := range catalogue.Plugins {
+		m := match{id: id}
+		switch {
+		case normalize(id) == query:
+			m.relevance = 4
+		case strings.Contains(normalize(id), query):
+			m.relevance = 3
+		case plugin.Meta != nil && strings.Contains(normalize(plugin.Meta.Name), query):
+			m.relevance = 2
+		case slices.Contains(plugin.Plugin.Labels, query),
+			plugin.Meta != nil &&
+				strings.Contains(strings.ToLower(plugin.Meta.Description[language]), query):
+			m.relevance = 1
+		default:
+			continue
+		}
+		if plugin.Release != nil {
+			for _, r := range plugin.Release.Releases {
+				m.downloads += r.Asset.DownloadCount
+			}
+			if len(plugin.Release.Releases) > 0 {
+				m.updated = plugin.Release.Releases[0].CreatedAt
+			}
+		}
+		matches = append(matches, m)
 	}
 
-	return info
+	sort.Slice(
+		matches,
+		func(i, j int) bool {
+			a, b := matches[i], matches[j]
+			switch options.IndexBy {
+			case lucytypes.ByDownloads:
+				if a.downloads != b.downloads {
+					return a.downloads > b.downloads
+				}
+			case lucytypes.ByNewest:
+				// The timestamps are in ISO 8601
+				if a.updated != b.updated {
+					return a.updated > b.updated
+				}
+			default:
+				if a.relevance != b.relevance {
+					return a.relevance > b.relevance
+				}
+			}
+			return a.id < b.id
+		},
+	)
+
+	result = &lucytypes.SearchResults{
+		Source:  lucytypes.McdrRepo,
+		Results: make([]string, 0, len(matches)),
+	}
+	for _, m := range matches {
+		result.Results = append(result.Results, m.id)
+	}
+	return result, nil
 }
 
-func SearchMcdrPluginCatalogue(search lucytypes.PackageName) (
-	pluginInfo *datatypes.McdrPluginInfo,
+func Information(name lucytypes.PackageName) (
+	information *lucytypes.PackageInformation,
 	err error,
 ) {
-	plugins := getMcdrPluginCatalogue()
+	catalogue, err := getCatalogue()
+	if err != nil {
+		return nil, err
+	}
+	id, plugin, err := findPlugin(catalogue, name)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, plugin := range plugins {
-		p := syntax.Parse(*plugin.Name)
-		if p.Name == search {
-			return getMcdrPluginInfo(*plugin.Path), nil
+	information = &lucytypes.PackageInformation{
+		Name:   id,
+		Author: []lucytypes.PackageMember{},
+		Urls:   []lucytypes.PackageUrl{},
+	}
+	if plugin.Meta != nil {
+		information.Name = plugin.Meta.Name
+		information.Brief = plugin.Meta.Description[language]
+	}
+	information.Description = information.Brief
+	if introduction := plugin.Plugin.Introduction[language]; introduction != "" {
+		information.Description = tools.MarkdownToPlainText(introduction)
+	}
+
+	for _, author := range plugin.Plugin.Authors {
+		member := lucytypes.PackageMember{Name: author}
+		if info, ok := catalogue.Authors.Authors[author]; ok {
+			member.Url = info.Link
 		}
+		information.Author = append(information.Author, member)
+	}
+
+	if plugin.Plugin.Repository != "" {
+		information.Urls = append(
+			information.Urls,
+			lucytypes.PackageUrl{
+				Name: "Source",
+				Type: lucytypes.SourceUrl,
+				Url:  plugin.Plugin.Repository,
+			},
+		)
+	}
+	if plugin.Meta != nil && plugin.Meta.Link != "" &&
+		plugin.Meta.Link != plugin.Plugin.Repository {
+		information.Urls = append(
+			information.Urls,
+			lucytypes.PackageUrl{
+				Name: "Homepage",
+				Type: lucytypes.HomepageUrl,
+				Url:  plugin.Meta.Link,
+			},
+		)
 	}
 
-	return nil, fmt.Errorf("plugin not found")
+	return information, nil
 }
 
-func getMcdrPluginCatalogue() []*github.RepositoryContent {
-	ctx := context.Background()
-	client := github.NewClient(nil)
+// ListVersions gives every release of the plugin, newest first, with the
+// remote of each filled in.
+func ListVersions(id lucytypes.PackageId) (packages []lucytypes.Package, err error) {
+	catalogue, err := getCatalogue()
+	if err != nil {
+		return nil, err
+	}
+	pluginId, plugin, err := findPlugin(catalogue, id.Name)
+	if err != nil {
+		return nil, err
+	}
+	if plugin.Release == nil {
+		return nil, nil
+	}
+	for _, r := range plugin.Release.Releases {
+		packages = append(packages, *releaseToPackage(pluginId, &r))
+	}
+	return packages, nil
+}
 
-	_, directoryContent, _, _ := client.Repositories.GetContents(
-		ctx,
-		"MCDReforged",
-		"PluginCatalogue",
-		"plugins",
-		nil,
-	)
+// Resolve gives the release that id asks for. Without a specific version, the
+// newest release that supports the installed MCDR is used, or the latest one
+// if the version of MCDR is not known.
+func Resolve(id lucytypes.PackageId) (p *lucytypes.Package, err error) {
+	pluginId, release, err := getRelease(id)
+	if err != nil {
+		return nil, err
+	}
+	return releaseToPackage(pluginId, release), nil
+}
 
-	return directoryContent
+func Fetch(id lucytypes.PackageId) (
+	remote *lucytypes.PackageRemote,
+	err error,
+) {
+	p, err := Resolve(id)
+	if err != nil {
+		return nil, err
+	}
+	return p.Remote, nil
 }
 
-func getMcdrPluginInfo(pluginPath string) (pluginInfo *datatypes.McdrPluginInfo) {
-	ctx := context.Background()
-	client := github.NewClient(nil)
+// Dependencies gives the plugins and the Python packages that the release
+// requires. The requirement on MCDR itself is checked by CheckCompatibility.
+func Dependencies(id lucytypes.PackageId) (
+	dependencies *lucytypes.PackageDependencies,
+	err error,
+) {
+	_, release, err := getRelease(id)
+	if err != nil {
+		return nil, err
+	}
 
-	fileContent, _, _, _ := client.Repositories.GetContents(
-		ctx,
-		"MCDReforged",
-		"PluginCatalogue",
-		path.Join(pluginPath, "plugin_info.json"),
-		nil,
+	dependencies = &lucytypes.PackageDependencies{
+		SupportedPlatforms: []lucytypes.Platform{lucytypes.Mcdr},
+		Required:           []lucytypes.PackageId{},
+		PythonRequirements: release.Meta.Requirements,
+	}
+	for dependency := range release.Meta.Dependencies {
+		if dependency == mcdrDependency {
+			continue
+		}
+		dependencies.Required = append(
+			dependencies.Required,
+			lucytypes.PackageId{
+				Platform: lucytypes.Mcdr,
+				Name:     lucytypes.PackageName(dependency),
+				Version:  lucytypes.AllVersion,
+			},
+		)
+	}
+	sort.Slice(
+		dependencies.Required,
+		func(i, j int) bool {
+			return dependencies.Required[i].Name < dependencies.Required[j].Name
+		},
 	)
-	pluginInfo = &datatypes.McdrPluginInfo{}
-	content, _ := fileContent.GetContent()
-	_ = json.Unmarshal([]byte(content), pluginInfo)
+	return dependencies, nil
+}
 
-	return
+// CheckCompatibility tells whether the release of id can run on the version of
+// MCDR. Nothing is checked if the version of MCDR is not known.
+func CheckCompatibility(id lucytypes.PackageId, mcdrVersion string) error {
+	if mcdrVersion == "" {
+		return nil
+	}
+	_, release, err := getRelease(id)
+	if err != nil {
+		return err
+	}
+	requirement := release.Meta.Dependencies[mcdrDependency]
+	if requirement == "" {
+		return nil
+	}
+	ok, err := SatisfiesRequirement(mcdrVersion, requirement)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf(
+			"%w: %s requires mcdreforged %s, but %s is installed",
+			ErrorIncompatibleMcdr,
+			id.StringVersion(),
+			requirement,
+			mcdrVersion,
+		)
+	}
+	return nil
+}
+
+// normalize makes plugin ids comparable with package names, which use hyphens
+// rather than the underlines in MCDR plugin ids.
+func normalize(s string) string {
+	return strings.ReplaceAll(strings.ToLower(s), "_", "-")
+}
+
+func findPlugin(catalogue *everything, name lucytypes.PackageName) (
+	id string,
+	plugin *allOfAPlugin,
+	err error,
+) {
+	for id, plugin := range catalogue.Plugins {
+		if name.Eq(lucytypes.PackageName(id)) {
+			return id, &plugin, nil
+		}
+	}
+	return "", nil, fmt.Errorf("%w: %s", ErrorNotFound, name)
+}
+
+func getRelease(id lucytypes.PackageId) (pluginId string, release *releaseInfo, err error) {
+	catalogue, err := getCatalogue()
+	if err != nil {
+		return "", nil, err
+	}
+	pluginId, plugin, err := findPlugin(catalogue, id.Name)
+	if err != nil {
+		return "", nil, err
+	}
+	if plugin.Release != nil {
+		switch id.Version {
+		case lucytypes.AllVersion, lucytypes.NoVersion, lucytypes.LatestCompatibleVersion:
+			release = compatibleRelease(withinConstraint(plugin.Release, id))
+		default:
+			release = findRelease(plugin.Release, id.Version)
+		}
+	}
+	if release == nil {
+		return "", nil, fmt.Errorf("%w: %s", ErrorVersionNotFound, id.StringVersion())
+	}
+	return pluginId, release, nil
+}
+
+// findRelease accepts the tag of a release as its version as well, with or
+// without its leading "v".
+func findRelease(summary *releaseSummary, version lucytypes.PackageVersion) *releaseInfo {
+	switch version {
+	case lucytypes.AllVersion, lucytypes.NoVersion, lucytypes.LatestVersion,
+		lucytypes.LatestCompatibleVersion:
+		if summary.LatestVersion == "" {
+			return nil
+		}
+		version = lucytypes.PackageVersion(summary.LatestVersion)
+	}
+	for i, r := range summary.Releases {
+		if r.Meta.Version == version.String() ||
+			strings.TrimPrefix(r.TagName, "v") == strings.TrimPrefix(version.String(), "v") {
+			return &summary.Releases[i]
+		}
+	}
+	return nil
+}
+
+// withinConstraint gives the summary with only the releases within the
+// constraint of id. The latest version is the newest of them that is not a
+// prerelease.
+func withinConstraint(summary *releaseSummary, id lucytypes.PackageId) *releaseSummary {
+	if id.Constraint == nil {
+		return summary
+	}
+	within := &releaseSummary{}
+	for _, r := range summary.Releases {
+		if !syntax.SatisfiesConstraint(id, lucytypes.PackageVersion(r.Meta.Version)) {
+			continue
+		}
+		within.Releases = append(within.Releases, r)
+		if within.LatestVersion == "" && !r.Prerelease {
+			within.LatestVersion = r.Meta.Version
+		}
+	}
+	return within
+}
+
+// compatibleRelease skips prereleases, unless they are all there is.
+func compatibleRelease(summary *releaseSummary) *releaseInfo {
+	mcdr := local.GetServerInfo().Mcdr
+	if mcdr == nil || mcdr.Version == "" {
+		logger.Info("unknown mcdr version, unable to infer a compatible release. falling back to latest release")
+		return findRelease(summary, lucytypes.LatestVersion)
+	}
+	for i, r := range summary.Releases {
+		if r.Prerelease && summary.LatestVersion != "" {
+			continue
+		}
+		requirement := r.Meta.Dependencies[mcdrDependency]
+		if ok, err := SatisfiesRequirement(mcdr.Version, requirement); err == nil && ok {
+			return &summary.Releases[i]
+		}
+	}
+	return nil
+}
+
+func releaseToPackage(pluginId string, release *releaseInfo) *lucytypes.Package {
+	return &lucytypes.Package{
+		Id: lucytypes.PackageId{
+			Platform: lucytypes.Mcdr,
+			Name:     lucytypes.PackageName(pluginId),
+			Version:  lucytypes.PackageVersion(release.Meta.Version),
+		},
+		Remote: &lucytypes.PackageRemote{
+			Source:    lucytypes.McdrRepo,
+			RemoteId:  pluginId,
+			FileUrl:   release.Asset.BrowserDownloadUrl,
+			Filename:  release.Asset.Name,
+			Sha256:    release.Asset.HashSha256,
+			Size:      release.Asset.Size,
+			Changelog: release.Description,
+			Published: releaseTime(release),
+		},
+	}
+}
+
+// releaseTime parses the creation time of a release, zero if it is malformed.
+func releaseTime(release *releaseInfo) time.Time {
+	t, err := time.Parse(time.RFC3339, release.CreatedAt)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
 }
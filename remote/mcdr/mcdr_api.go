@@ -0,0 +1,104 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mcdr
+
+// The types here follow the catalogue meta that MCDR itself downloads, which
+// aggregates everything in the MCDReforged/PluginCatalogue repository. For the
+// schemas, see the meta branch of that repository.
+
+type everything struct {
+	Timestamp int64 `json:"timestamp"`
+	Authors   struct {
+		Amount  int                   `json:"amount"`
+		Authors map[string]authorInfo `json:"authors"`
+	} `json:"authors"`
+	Plugins map[string]allOfAPlugin `json:"plugins"`
+}
+
+type authorInfo struct {
+	Name string `json:"name"`
+	Link string `json:"link"`
+}
+
+// allOfAPlugin is keyed by the plugin id. Meta and Release are nil for a plugin
+// that has not been released yet.
+type allOfAPlugin struct {
+	Meta    *metaInfo       `json:"meta"`
+	Plugin  pluginInfo      `json:"plugin"`
+	Release *releaseSummary `json:"release"`
+}
+
+// metaInfo is the mcdreforged.plugin.json of the plugin, see
+// datatypes.McdrPluginIdentifierFile.
+type metaInfo struct {
+	Id      string   `json:"id"`
+	Name    string   `json:"name"`
+	Version string   `json:"version"`
+	Link    string   `json:"link"`
+	Authors []string `json:"authors"`
+	// Dependencies maps plugin ids to version requirements, "mcdreforged" is
+	// MCDR itself.
+	Dependencies map[string]string `json:"dependencies"`
+	// Requirements are Python packages in the requirements.txt format.
+	Requirements []string          `json:"requirements"`
+	Description  map[string]string `json:"description"`
+}
+
+// pluginInfo is the plugin_info.json in the catalogue repository.
+type pluginInfo struct {
+	Id           string            `json:"id"`
+	Authors      []string          `json:"authors"`
+	Repository   string            `json:"repository"`
+	Branch       string            `json:"branch"`
+	RelatedPath  string            `json:"related_path"`
+	Labels       []string          `json:"labels"`
+	Introduction map[string]string `json:"introduction"`
+}
+
+// releaseSummary lists the releases newest first. LatestVersion is the newest
+// one that is not a prerelease.
+type releaseSummary struct {
+	LatestVersion      string        `json:"latest_version"`
+	LatestVersionIndex *int          `json:"latest_version_index"`
+	Releases           []releaseInfo `json:"releases"`
+}
+
+type releaseInfo struct {
+	Url       string `json:"url"`
+	Name      string `json:"name"`
+	TagName   string `json:"tag_name"`
+	CreatedAt string `json:"created_at"`
+	// Description is the body of the GitHub release.
+	Description string    `json:"description"`
+	Prerelease  bool      `json:"prerelease"`
+	Asset       assetInfo `json:"asset"`
+	Meta        metaInfo  `json:"meta"`
+}
+
+type assetInfo struct {
+	Id                 int64  `json:"id"`
+	Name               string `json:"name"`
+	Size               int64  `json:"size"`
+	DownloadCount      int    `json:"download_count"`
+	CreatedAt          string `json:"created_at"`
+	BrowserDownloadUrl string `json:"browser_download_url"`
+	HashMd5            string `json:"hash_md5"`
+	HashSha256         string `json:"hash_sha256"`
+}
+
+// Descriptions are keyed by language, English is used for output.
+const language = "en_us"
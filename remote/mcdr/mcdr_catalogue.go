@@ -0,0 +1,171 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mcdr
+
+import (
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"lucy/local"
+	"lucy/logger"
+	"lucy/lucyerrors"
+	"lucy/tools"
+	"lucy/util"
+)
+
+// DefaultCatalogueMetaUrl is the catalogue meta that MCDR uses when
+// catalogue_meta_url is not set in its config. MCDR itself prefers the xz
+// variant, which Lucy cannot decompress, so the gzip variant next to it is used
+// instead, for any url.
+var DefaultCatalogueMetaUrl = "https://api.mcdreforged.com/catalogue/everything_slim.json.gz"
+
+// The defaults are the same as MCDR's.
+const (
+	defaultCatalogueMetaCacheTtl     = 1200 * time.Second
+	defaultCatalogueMetaFetchTimeout = 15 * time.Second
+)
+
+var ErrorCatalogueUnavailable = lucyerrors.New(lucyerrors.NetworkError, "mcdr plugin catalogue unavailable")
+
+// getCatalogue is only loaded once per run, since the catalogue is several
+// megabytes.
+var getCatalogue = sync.OnceValues(loadCatalogue)
+
+// loadCatalogue uses the cached catalogue within its ttl. After that, it is
+// fetched again, and if that fails, the outdated cache is used anyway.
+func loadCatalogue() (*everything, error) {
+	url, ttl, timeout := catalogueSettings()
+	cacheFile := path.Join(util.CachePath, catalogueCacheName(url))
+
+	if stat, err := os.Stat(cacheFile); err == nil && time.Since(stat.ModTime()) < ttl {
+		if catalogue, err := readCatalogueCache(cacheFile); err == nil {
+			return catalogue, nil
+		}
+	}
+
+	data, fetchErr := fetchCatalogue(url, timeout)
+	if fetchErr == nil {
+		catalogue := &everything{}
+		if fetchErr = json.Unmarshal(data, catalogue); fetchErr == nil {
+			writeCatalogueCache(cacheFile, data)
+			return catalogue, nil
+		}
+	}
+
+	if catalogue, err := readCatalogueCache(cacheFile); err == nil {
+		logger.Warning(
+			fmt.Errorf("%w, using an outdated cache: %w", ErrorCatalogueUnavailable, fetchErr),
+		)
+		return catalogue, nil
+	}
+	return nil, fmt.Errorf("%w: %w", ErrorCatalogueUnavailable, fetchErr)
+}
+
+// catalogueSettings follows the MCDR config of the server, if there is one.
+func catalogueSettings() (url string, ttl time.Duration, timeout time.Duration) {
+	url = DefaultCatalogueMetaUrl
+	ttl = defaultCatalogueMetaCacheTtl
+	timeout = defaultCatalogueMetaFetchTimeout
+
+	mcdr := local.GetServerInfo().Mcdr
+	if mcdr == nil {
+		return url, ttl, timeout
+	}
+	if mcdr.CatalogueMetaUrl != "" {
+		url = mcdr.CatalogueMetaUrl
+	}
+	if mcdr.CatalogueMetaCacheTtl > 0 {
+		ttl = mcdr.CatalogueMetaCacheTtl
+	}
+	if mcdr.CatalogueMetaFetchTimeout > 0 {
+		timeout = mcdr.CatalogueMetaFetchTimeout
+	}
+	return url, ttl, timeout
+}
+
+// catalogueCacheName is different for each url, so changing the url in the
+// config does not pick up the cache of another one.
+func catalogueCacheName(url string) string {
+	digest := sha1.Sum([]byte(url))
+	return "mcdr_catalogue_" + hex.EncodeToString(digest[:4]) + ".json"
+}
+
+// fetchCatalogue gives the decompressed catalogue. The compression is told by
+// the extension of the url, as MCDR does.
+func fetchCatalogue(url string, timeout time.Duration) (data []byte, err error) {
+	if strings.HasSuffix(url, ".xz") {
+		url = strings.TrimSuffix(url, ".xz") + ".gz"
+	}
+
+	client := &http.Client{Timeout: timeout}
+	res, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer tools.CloseReader(res.Body, logger.Warning)
+	if err := lucyerrors.CheckResponse(res); err != nil {
+		return nil, err
+	}
+
+	var r io.Reader = res.Body
+	if strings.HasSuffix(url, ".gz") {
+		gz, err := gzip.NewReader(res.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer tools.CloseReader(gz, logger.Warning)
+		r = gz
+	}
+	return io.ReadAll(r)
+}
+
+func readCatalogueCache(cacheFile string) (*everything, error) {
+	data, err := os.ReadFile(cacheFile)
+	if err != nil {
+		return nil, err
+	}
+	catalogue := &everything{}
+	if err := json.Unmarshal(data, catalogue); err != nil {
+		return nil, err
+	}
+	return catalogue, nil
+}
+
+// writeCatalogueCache does not create the program directory, a server without
+// Lucy installed simply fetches the catalogue every time.
+func writeCatalogueCache(cacheFile string, data []byte) {
+	if _, err := os.Stat(util.ProgramPath); err != nil {
+		return
+	}
+	if err := os.MkdirAll(path.Dir(cacheFile), 0o755); err != nil {
+		logger.Warning(err)
+		return
+	}
+	if err := os.WriteFile(cacheFile, data, 0o644); err != nil {
+		logger.Warning(err)
+	}
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mcdr
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrorInvalidRequirement = errors.New("invalid mcdr version requirement")
+
+// SatisfiesRequirement checks a version against a requirement in the format of
+// MCDR plugin dependencies, which is a space separated list of criteria such as
+// ">=2.1.0", "^2.0", "~2.1.0" or "2.x". Every criterion must be met.
+//
+//   - "^" requires the same major version, and "~" the same minor version,
+//     while not being older than the one given.
+//   - A criterion without an operator is an exact match, in which "*", "x" or
+//     "X" matches any component.
+func SatisfiesRequirement(version string, requirement string) (bool, error) {
+	v, err := parseVersion(version)
+	if err != nil {
+		return false, err
+	}
+	for _, criterion := range strings.Fields(requirement) {
+		ok, err := satisfiesCriterion(v, criterion)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Longer operators must come first so ">=" is not taken as ">".
+var operators = []string{">=", "<=", "==", ">", "<", "=", "^", "~"}
+
+func satisfiesCriterion(v *mcdrVersion, criterion string) (bool, error) {
+	operator := "="
+	for _, o := range operators {
+		if strings.HasPrefix(criterion, o) {
+			operator = o
+			criterion = criterion[len(o):]
+			break
+		}
+	}
+	target, err := parseVersion(criterion)
+	if err != nil {
+		return false, fmt.Errorf("%w: %s", ErrorInvalidRequirement, criterion)
+	}
+
+	c := v.compare(target)
+	switch operator {
+	case ">=":
+		return c >= 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	case "<":
+		return c < 0, nil
+	case "^":
+		return c >= 0 && v.component(0) == target.component(0), nil
+	case "~":
+		return c >= 0 &&
+			v.component(0) == target.component(0) &&
+			v.component(1) == target.component(1), nil
+	default:
+		return c == 0, nil
+	}
+}
+
+// wildcard in mcdrVersion.components matches any value.
+const wildcard = -1
+
+type mcdrVersion struct {
+	components []int
+	prerelease string
+}
+
+// parseVersion drops the build metadata after "+", which does not take part in
+// comparisons.
+func parseVersion(s string) (*mcdrVersion, error) {
+	s, _, _ = strings.Cut(strings.TrimPrefix(s, "v"), "+")
+	core, prerelease, _ := strings.Cut(s, "-")
+	v := &mcdrVersion{prerelease: prerelease}
+	for _, part := range strings.Split(core, ".") {
+		if part == "*" || part == "x" || part == "X" {
+			v.components = append(v.components, wildcard)
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%w: %s", ErrorInvalidRequirement, s)
+		}
+		v.components = append(v.components, n)
+	}
+	return v, nil
+}
+
+// component gives 0 for a component that is not written, so "2.1" is "2.1.0".
+func (v *mcdrVersion) component(i int) int {
+	if i < len(v.components) {
+		return v.components[i]
+	}
+	return 0
+}
+
+// compare treats a wildcard in either version as equal from there on. A
+// prerelease is older than its release.
+func (v *mcdrVersion) compare(other *mcdrVersion) int {
+	for i := 0; i < max(len(v.components), len(other.components)); i++ {
+		a, b := v.component(i), other.component(i)
+		if a == wildcard || b == wildcard {
+			return 0
+		}
+		if a != b {
+			if a < b {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case v.prerelease == other.prerelease:
+		return 0
+	case v.prerelease == "":
+		return 1
+	case other.prerelease == "":
+		return -1
+	}
+	return strings.Compare(v.prerelease, other.prerelease)
+}
@@ -28,10 +28,8 @@ limitations under the License.
 package modrinth
 
 import (
-	"encoding/json"
 	"errors"
-	"io"
-	"net/http"
+	"fmt"
 	"strconv"
 
 	"lucy/datatypes"
@@ -58,6 +56,8 @@ func Search(
 		facets = append(facets, facetForge)
 	case lucytypes.Fabric:
 		facets = append(facets, facetFabric)
+	case lucytypes.Quilt:
+		facets = append(facets, facetQuilt)
 	default:
 		facets = append(facets, facetForge, facetAllLoaders)
 
@@ -80,14 +80,8 @@ func Search(
 
 	// Make the call to Modrinth API
 	logger.Debug("searching via modrinth api: " + searchUrl)
-	resp, err := http.Get(searchUrl)
-	if err != nil {
-		return nil, ErrorInvalidAPIResponse
-	}
-	data, err := io.ReadAll(resp.Body)
-	defer tools.CloseReader(resp.Body, logger.Warning)
 	var searchResults datatypes.ModrinthSearchResults
-	err = json.Unmarshal(data, &searchResults)
+	err = getJson(searchUrl, &searchResults)
 	if err != nil {
 		return nil, err
 	}
@@ -111,29 +105,89 @@ func Fetch(id lucytypes.PackageId) (
 	remote *lucytypes.PackageRemote,
 	err error,
 ) {
-	id = inferVersion(id)
-	project := getProjectByName(id.Name)
+	id, err = inferVersion(id)
+	if err != nil {
+		return nil, err
+	}
+	project, err := getProjectByName(id.Name)
+	if err != nil {
+		return nil, err
+	}
 	version, err := getVersion(id)
 	if err != nil {
-		logger.Fatal(err)
+		return nil, err
 	}
-	fileUrl, filename := getFile(version)
+	file := primaryFile(version.Files)
 
 	remote = &lucytypes.PackageRemote{
 		Source:   lucytypes.Modrinth,
 		RemoteId: project.Id,
-		FileUrl:  fileUrl,
-		Filename: filename,
+		FileUrl:  file.Url,
+		Filename: file.Filename,
+		Sha1:     file.Hashes.Sha1,
+		Sha512:   file.Hashes.Sha512,
+		Size:     int64(file.Size),
 	}
 
 	return remote, nil
 }
 
+// Resolve infers the version of id and gives the package with its remote and
+// dependencies filled in, which is what gets pinned in lucy.lock.
+func Resolve(id lucytypes.PackageId) (p *lucytypes.Package, err error) {
+	var version *datatypes.ModrinthVersion
+	switch id.Version {
+	case lucytypes.AllVersion, lucytypes.NoVersion, lucytypes.LatestCompatibleVersion:
+		if id.Constraint != nil {
+			version, err = latestSatisfyingVersion(id)
+			break
+		}
+		version, err = LatestCompatibleVersion(id.Name)
+	case lucytypes.LatestVersion:
+		version, err = latestVersion(id)
+	default:
+		version, err = getVersion(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if version == nil || len(version.Files) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrorVersionNotFound, id.String())
+	}
+
+	file := primaryFile(version.Files)
+	p = &lucytypes.Package{
+		Id: lucytypes.PackageId{
+			Platform: id.Platform,
+			Name:     id.Name,
+			Version:  version.VersionNumber,
+		},
+		Remote: &lucytypes.PackageRemote{
+			Source:    lucytypes.Modrinth,
+			RemoteId:  version.ProjectId,
+			FileUrl:   file.Url,
+			Filename:  file.Filename,
+			Sha1:      file.Hashes.Sha1,
+			Sha512:    file.Hashes.Sha512,
+			Size:      int64(file.Size),
+			Changelog: version.Changelog,
+			Published: version.DatePublished,
+		},
+	}
+	// The version is already known, so it is not looked up again as
+	// Dependencies would
+	p.Dependencies = versionDependencies(p.Id, version, version.GameVersions, version.Loaders)
+	return p, nil
+}
+
 func Information(slug lucytypes.PackageName) (
 	information *lucytypes.PackageInformation,
 	err error,
 ) {
-	project := getProjectByName(slug)
+	project, err := getProjectByName(slug)
+	if err != nil {
+		return nil, err
+	}
 	information = &lucytypes.PackageInformation{
 		Name:        project.Title,
 		Brief:       project.Description,
@@ -180,7 +234,10 @@ func Information(slug lucytypes.PackageName) (
 	}
 
 	// Fill in authors
-	members := getProjectMembers(project.Id)
+	members, err := getProjectMembers(project.Id)
+	if err != nil {
+		return nil, err
+	}
 	for _, member := range members {
 		information.Author = append(
 			information.Author,
@@ -198,30 +255,53 @@ func Information(slug lucytypes.PackageName) (
 
 // Dependencies from Modrinth API is extremely unreliable. A local check (if any
 // files were downloaded) is recommended.
-func Dependencies(id lucytypes.PackageId) (dependencies *lucytypes.PackageDependencies) {
-	id = inferVersion(id)
-	project := getProjectByName(id.Name)
-	version, _ := getVersion(id)
+func Dependencies(id lucytypes.PackageId) (
+	dependencies *lucytypes.PackageDependencies,
+	err error,
+) {
+	id, err = inferVersion(id)
+	if err != nil {
+		return nil, err
+	}
+	project, err := getProjectByName(id.Name)
+	if err != nil {
+		return nil, err
+	}
+	version, err := getVersion(id)
+	if err != nil {
+		return nil, err
+	}
+	return versionDependencies(id, version, project.GameVersions, project.Loaders), nil
+}
+
+// versionDependencies gives the dependencies of a version, supporting the given
+// game versions and loaders. A dependency that cannot be looked up is left out
+// with a warning.
+func versionDependencies(
+	id lucytypes.PackageId,
+	version *datatypes.ModrinthVersion,
+	gameVersions []string,
+	loaders []string,
+) (dependencies *lucytypes.PackageDependencies) {
 	dependencies = &lucytypes.PackageDependencies{
 		SupportedVersions:  []lucytypes.PackageVersion{},
 		SupportedPlatforms: []lucytypes.Platform{},
 		Required:           []lucytypes.PackageId{},
 	}
 
-	for _, version := range project.GameVersions {
+	for _, version := range gameVersions {
 		dependencies.SupportedVersions = append(
 			dependencies.SupportedVersions,
 			lucytypes.PackageVersion(version),
 		)
 	}
 
-	for _, platform := range project.Loaders {
+	for _, platform := range loaders {
 		dependencies.SupportedPlatforms = append(
 			dependencies.SupportedPlatforms,
 			lucytypes.Platform(platform),
 		)
 	}
-
 	for _, dependency := range version.Dependencies {
 		switch dependency.DependencyType {
 		case datatypes.ModrinthVersionDependencyTypeIncompatible:
@@ -264,33 +344,32 @@ func GetProjectByName(packageName lucytypes.PackageName) (
 	project *datatypes.ModrinthProject,
 	err error,
 ) {
-	res, err := http.Get(projectUrl(string(packageName)))
-	if err != nil {
-		return
-	}
-	data, err := io.ReadAll(res.Body)
-	if err != nil {
-		return
-	}
-	project = &datatypes.ModrinthProject{}
-	err = json.Unmarshal(data, project)
-	return
+	return getProjectByName(packageName)
 }
 
-func inferVersion(p lucytypes.PackageId) (infer lucytypes.PackageId) {
+func inferVersion(p lucytypes.PackageId) (
+	infer lucytypes.PackageId,
+	err error,
+) {
 	infer.Platform = p.Platform
 	infer.Name = p.Name
 
+	var version *datatypes.ModrinthVersion
 	switch p.Version {
 	case lucytypes.AllVersion, lucytypes.NoVersion, lucytypes.LatestCompatibleVersion:
-		version := LatestCompatibleVersion(p.Name)
-		infer.Version = version.VersionNumber
+		version, err = LatestCompatibleVersion(p.Name)
 	case lucytypes.LatestVersion:
-		version := latestVersion(p.Name)
-		infer.Version = version.VersionNumber
+		version, err = latestVersion(p)
 	default:
-		return p
+		return p, nil
+	}
+	if err != nil {
+		return p, err
+	}
+	if version == nil {
+		return p, fmt.Errorf("%w: %s", ErrorVersionNotFound, p.String())
 	}
+	infer.Version = version.VersionNumber
 
-	return infer
+	return infer, nil
 }
@@ -0,0 +1,247 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modrinth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"time"
+
+	"lucy/datatypes"
+	"lucy/logger"
+	"lucy/lucyerrors"
+	"lucy/lucytypes"
+	"lucy/tools"
+	"lucy/util"
+)
+
+const (
+	versionFilesUrl = "https://api.modrinth.com/v2/version_files"
+	projectsUrl     = "https://api.modrinth.com/v2/projects"
+)
+
+const hashCacheFile = util.CachePath + "/modrinth_version_files.json"
+
+// hashMissTtl is how long a file that Modrinth does not know is remembered as
+// such. A found file never expires, as a hash always refers to the same file.
+const hashMissTtl = 24 * time.Hour
+
+// hashCacheEntry is what is known about a file on Modrinth, keyed by its sha1.
+type hashCacheEntry struct {
+	Found     bool      `json:"found"`
+	CheckedAt time.Time `json:"checked_at"`
+	ProjectId string    `json:"project_id,omitempty"`
+	Slug      string    `json:"slug,omitempty"`
+	Url       string    `json:"url,omitempty"`
+	Filename  string    `json:"filename,omitempty"`
+	Sha512    string    `json:"sha512,omitempty"`
+	Size      int64     `json:"size,omitempty"`
+	Published time.Time `json:"published,omitempty"`
+}
+
+// IdentifyFiles fills in the Remote of the installed packages whose files are
+// on Modrinth. The sha1 of every file is sent in a single request, and the
+// results are cached by hash. Packages analyzed from the same file share its
+// remote. Packages whose file is not found are left as is.
+func IdentifyFiles(packages []lucytypes.Package) error {
+	hashes := map[string]string{} // path -> sha1
+	for _, p := range packages {
+		if p.Local == nil || hashes[p.Local.Path] != "" {
+			continue
+		}
+		digest, err := util.FileSha1(p.Local.Path)
+		if err != nil {
+			logger.Warning(err)
+			continue
+		}
+		hashes[p.Local.Path] = digest
+	}
+
+	cache := readHashCache()
+	var unknown []string
+	for _, digest := range hashes {
+		entry, cached := cache[digest]
+		if cached && (entry.Found || time.Since(entry.CheckedAt) < hashMissTtl) {
+			continue
+		}
+		unknown = append(unknown, digest)
+	}
+	if len(unknown) != 0 {
+		found, err := lookupHashes(unknown)
+		if err != nil {
+			return err
+		}
+		for _, digest := range unknown {
+			entry, ok := found[digest]
+			if !ok {
+				entry = hashCacheEntry{Found: false}
+			}
+			entry.CheckedAt = time.Now()
+			cache[digest] = entry
+		}
+		writeHashCache(cache)
+	}
+
+	for i, p := range packages {
+		if p.Local == nil {
+			continue
+		}
+		entry := cache[hashes[p.Local.Path]]
+		if !entry.Found {
+			continue
+		}
+		packages[i].Remote = &lucytypes.PackageRemote{
+			Source:    lucytypes.Modrinth,
+			RemoteId:  entry.ProjectId,
+			Slug:      lucytypes.PackageName(entry.Slug),
+			FileUrl:   entry.Url,
+			Filename:  entry.Filename,
+			Sha1:      hashes[p.Local.Path],
+			Sha512:    entry.Sha512,
+			Size:      entry.Size,
+			Published: entry.Published,
+		}
+	}
+	return nil
+}
+
+// lookupHashes asks Modrinth for the versions that the files belong to, then
+// for the slugs of their projects. Hashes that are not found are left out.
+func lookupHashes(hashes []string) (found map[string]hashCacheEntry, err error) {
+	body, _ := json.Marshal(
+		map[string]any{
+			"hashes":    hashes,
+			"algorithm": "sha1",
+		},
+	)
+	var versions map[string]datatypes.ModrinthVersion
+	if err := postJson(versionFilesUrl, body, &versions); err != nil {
+		return nil, err
+	}
+
+	found = map[string]hashCacheEntry{}
+	projectIds := map[string]bool{}
+	for digest, version := range versions {
+		entry := hashCacheEntry{
+			Found:     true,
+			ProjectId: version.ProjectId,
+			Published: version.DatePublished,
+		}
+		for _, file := range version.Files {
+			if file.Hashes.Sha1 == digest {
+				entry.Url = file.Url
+				entry.Filename = file.Filename
+				entry.Sha512 = file.Hashes.Sha512
+				entry.Size = int64(file.Size)
+			}
+		}
+		found[digest] = entry
+		projectIds[version.ProjectId] = true
+	}
+	if len(projectIds) == 0 {
+		return found, nil
+	}
+
+	ids := make([]string, 0, len(projectIds))
+	for id := range projectIds {
+		ids = append(ids, id)
+	}
+	idsJson, _ := json.Marshal(ids)
+	var projects []datatypes.ModrinthProject
+	if err := getJson(projectsUrl+"?ids="+url.QueryEscape(string(idsJson)), &projects); err != nil {
+		return nil, err
+	}
+	slugs := map[string]string{}
+	for _, project := range projects {
+		slugs[project.Id] = project.Slug
+	}
+	for digest, entry := range found {
+		entry.Slug = slugs[entry.ProjectId]
+		found[digest] = entry
+	}
+	return found, nil
+}
+
+func postJson(u string, body []byte, v any) error {
+	res, err := http.Post(u, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	return decodeResponse(res, v)
+}
+
+func getJson(u string, v any) error {
+	res, err := http.Get(u)
+	if err != nil {
+		return err
+	}
+	return decodeResponse(res, v)
+}
+
+func decodeResponse(res *http.Response, v any) error {
+	defer tools.CloseReader(res.Body, logger.Warning)
+	if err := lucyerrors.CheckResponse(res); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: %w", ErrorInvalidAPIResponse, err)
+	}
+	return nil
+}
+
+// readHashCache gives an empty cache if there is none or it cannot be read.
+func readHashCache() map[string]hashCacheEntry {
+	cache := map[string]hashCacheEntry{}
+	data, err := os.ReadFile(hashCacheFile)
+	if err != nil {
+		return cache
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		logger.Debug("ignoring a broken cache " + hashCacheFile + ": " + err.Error())
+		return map[string]hashCacheEntry{}
+	}
+	return cache
+}
+
+// writeHashCache does not create the program directory, like the other caches.
+func writeHashCache(cache map[string]hashCacheEntry) {
+	if _, err := os.Stat(util.ProgramPath); err != nil {
+		return
+	}
+	data, err := json.Marshal(cache)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	if err := os.MkdirAll(path.Dir(hashCacheFile), 0o755); err != nil {
+		logger.Warning(err)
+		return
+	}
+	if err := os.WriteFile(hashCacheFile, data, 0o644); err != nil {
+		logger.Warning(err)
+	}
+}
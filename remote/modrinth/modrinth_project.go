@@ -17,45 +17,33 @@ limitations under the License.
 package modrinth
 
 import (
-	"encoding/json"
 	"errors"
-	"io"
-	"net/http"
 
 	"lucy/datatypes"
 	"lucy/lucytypes"
 )
 
-func getProjectId(slug lucytypes.PackageName) (id string) {
-	res, _ := http.Get(projectUrl(string(slug)))
-	modrinthProject := datatypes.ModrinthProject{}
-	data, _ := io.ReadAll(res.Body)
-	json.Unmarshal(data, &modrinthProject)
-	id = modrinthProject.Id
-	return
-}
-
-func getProjectById(id string) (project *datatypes.ModrinthProject) {
-	res, _ := http.Get(projectUrl(id))
-	data, _ := io.ReadAll(res.Body)
+func getProjectById(id string) (project *datatypes.ModrinthProject, err error) {
 	project = &datatypes.ModrinthProject{}
-	json.Unmarshal(data, project)
-	return
+	if err = getJson(projectUrl(id), project); err != nil {
+		return nil, err
+	}
+	return project, nil
 }
 
-func getProjectByName(slug lucytypes.PackageName) (project *datatypes.ModrinthProject) {
-	res, _ := http.Get(projectUrl(string(slug)))
-	data, _ := io.ReadAll(res.Body)
-	project = &datatypes.ModrinthProject{}
-	json.Unmarshal(data, project)
-	return
+func getProjectByName(slug lucytypes.PackageName) (
+	project *datatypes.ModrinthProject,
+	err error,
+) {
+	return getProjectById(string(slug))
 }
 
-func getProjectMembers(id string) (members []*datatypes.ModrinthMember) {
-	res, _ := http.Get(projectMemberUrl(id))
-	data, _ := io.ReadAll(res.Body)
-	json.Unmarshal(data, &members)
-	return
+func getProjectMembers(id string) (
+	members []*datatypes.ModrinthMember,
+	err error,
+) {
+	err = getJson(projectMemberUrl(id), &members)
+	return members, err
 }
 
 var ErrorInvalidDependency = errors.New("invalid dependency")
@@ -76,16 +64,28 @@ func DependencyToPackage(
 	p.Platform = depedent.Platform
 
 	if dependency.VersionId != "" && dependency.ProjectId != "" {
-		version = getVersionById(dependency.VersionId)
-		project = getProjectById(dependency.ProjectId)
+		if version, err = getVersionById(dependency.VersionId); err != nil {
+			return p, err
+		}
+		if project, err = getProjectById(dependency.ProjectId); err != nil {
+			return p, err
+		}
 	} else if dependency.VersionId != "" {
-		version = getVersionById(dependency.VersionId)
-		project = getProjectById(version.ProjectId)
+		if version, err = getVersionById(dependency.VersionId); err != nil {
+			return p, err
+		}
+		if project, err = getProjectById(version.ProjectId); err != nil {
+			return p, err
+		}
 	} else if dependency.ProjectId != "" {
-		project = getProjectById(dependency.ProjectId)
-		// This is not safe, TODO: use better inference method
-		version = latestVersion(lucytypes.PackageName(project.Slug))
-		p.Version = lucytypes.LatestVersion
+		// Only the project is specified, so any version that is compatible with
+		// the server will do. The version is left for the caller to infer.
+		if project, err = getProjectById(dependency.ProjectId); err != nil {
+			return p, err
+		}
+		p.Name = lucytypes.PackageName(project.Slug)
+		p.Version = lucytypes.LatestCompatibleVersion
+		return p, nil
 	} else {
 		return p, ErrorInvalidDependency
 	}
@@ -175,6 +175,20 @@ var facetFabric = facetItems{
 	},
 }
 
+// facetQuilt includes Fabric mods, since Quilt can load them.
+var facetQuilt = facetItems{
+	{
+		Type:      "categories",
+		Operation: operationEq,
+		Value:     "quilt",
+	},
+	{
+		Type:      "categories",
+		Operation: operationEq,
+		Value:     "fabric",
+	},
+}
+
 var facetServerSupported = facetItems{
 	{
 		Type:      "server_side",
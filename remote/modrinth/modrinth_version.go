@@ -17,30 +17,30 @@ limitations under the License.
 package modrinth
 
 import (
-	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
-	"net/http"
+	"slices"
 
 	"lucy/logger"
+	"lucy/lucyerrors"
 
 	"lucy/datatypes"
 	"lucy/local"
 	"lucy/lucytypes"
+	"lucy/syntax"
 )
 
 // TODO: Refactor to separate all API functions to accept an url. While the urls
 // are generated by other functions. This will make the code more modular and
 // easier to test.
 
-var ErrorVersionNotFound = errors.New("modrinth version not found")
+var ErrorVersionNotFound = lucyerrors.New(lucyerrors.NotFoundError, "modrinth version not found")
 
-func listVersions(slug lucytypes.PackageName) (versions []*datatypes.ModrinthVersion) {
-	res, _ := http.Get(versionsUrl(slug))
-	data, _ := io.ReadAll(res.Body)
-	json.Unmarshal(data, &versions)
-	return
+func listVersions(slug lucytypes.PackageName) (
+	versions []*datatypes.ModrinthVersion,
+	err error,
+) {
+	err = getJson(versionsUrl(slug), &versions)
+	return versions, err
 }
 
 // getVersion is named as so because a Package in lucy is equivalent to a version
@@ -49,9 +49,12 @@ func getVersion(id lucytypes.PackageId) (
 	v *datatypes.ModrinthVersion,
 	err error,
 ) {
-	versions := listVersions(id.Name)
 	if id.Version == lucytypes.LatestVersion {
-		return latestVersion(id.Name), nil
+		return latestVersion(id)
+	}
+	versions, err := listVersions(id.Name)
+	if err != nil {
+		return nil, err
 	}
 	for _, version := range versions {
 		if version.VersionNumber == id.Version &&
@@ -62,14 +65,16 @@ func getVersion(id lucytypes.PackageId) (
 	return nil, fmt.Errorf("%w: %s", ErrorVersionNotFound, id.String())
 }
 
-func getVersionById(id string) (v *datatypes.ModrinthVersion) {
-	res, _ := http.Get(versionUrl(id))
-	data, _ := io.ReadAll(res.Body)
+func getVersionById(id string) (v *datatypes.ModrinthVersion, err error) {
 	v = &datatypes.ModrinthVersion{}
-	json.Unmarshal(data, v)
-	return
+	if err = getJson(versionUrl(id), v); err != nil {
+		return nil, err
+	}
+	return v, nil
 }
 
+// versionSupportsLoader also accepts Fabric versions for Quilt, which can load
+// Fabric mods, unless the version declares itself incompatible with Quilt.
 func versionSupportsLoader(
 	version *datatypes.ModrinthVersion,
 	loader lucytypes.Platform,
@@ -79,13 +84,64 @@ func versionSupportsLoader(
 			return true
 		}
 	}
+	if loader == lucytypes.Quilt &&
+		slices.Contains(version.Loaders, string(lucytypes.Fabric)) {
+		return !versionBreaksQuilt(version)
+	}
+	return false
+}
+
+// quiltProjects are the Modrinth slugs that a Fabric mod can be marked as
+// incompatible with to say that it does not work on Quilt.
+var quiltProjects = []lucytypes.PackageName{"qsl", "quilted-fabric-api"}
+
+func versionBreaksQuilt(version *datatypes.ModrinthVersion) bool {
+	for _, dependency := range version.Dependencies {
+		if dependency.DependencyType != datatypes.ModrinthVersionDependencyTypeIncompatible ||
+			dependency.ProjectId == "" {
+			continue
+		}
+		project, err := getProjectById(dependency.ProjectId)
+		if err != nil {
+			logger.Debug("cannot check quilt compatibility of " + version.VersionNumber.String() + ": " + err.Error())
+			continue
+		}
+		if slices.ContainsFunc(quiltProjects, lucytypes.PackageName(project.Slug).Eq) {
+			return true
+		}
+	}
 	return false
 }
 
-func latestVersion(slug lucytypes.PackageName) (v *datatypes.ModrinthVersion) {
-	versions := listVersions(slug)
+// latestVersion is the newest release of the project for the platform of id,
+// or for the server's if id leaves it open.
+func latestVersion(id lucytypes.PackageId) (
+	v *datatypes.ModrinthVersion,
+	err error,
+) {
+	versions, err := listVersions(id.Name)
+	if err != nil {
+		return nil, err
+	}
+	loader := id.Platform
+	if loader == lucytypes.AllPlatform {
+		if serverInfo := local.GetServerInfo(); serverInfo.Executable != local.UnknownExecutable {
+			loader = serverInfo.Executable.Platform
+		}
+	}
+	return latestVersionOf(id.Name, loader, versions), nil
+}
+
+// latestVersionOf picks the newest release that supports the loader, by the
+// date it was published. AllPlatform takes any loader.
+func latestVersionOf(
+	slug lucytypes.PackageName,
+	loader lucytypes.Platform,
+	versions []*datatypes.ModrinthVersion,
+) (v *datatypes.ModrinthVersion) {
 	for _, version := range versions {
 		if version.VersionType == "release" &&
+			versionSupportsLoader(version, loader) &&
 			(v == nil || version.DatePublished.After(v.DatePublished)) {
 			v = version
 		}
@@ -98,14 +154,49 @@ func latestVersion(slug lucytypes.PackageName) (v *datatypes.ModrinthVersion) {
 	return v
 }
 
-func LatestCompatibleVersion(slug lucytypes.PackageName) (v *datatypes.ModrinthVersion) {
-	versions := listVersions(slug)
+func LatestCompatibleVersion(slug lucytypes.PackageName) (
+	v *datatypes.ModrinthVersion,
+	err error,
+) {
+	versions, err := listVersions(slug)
+	if err != nil {
+		return nil, err
+	}
+	return latestCompatibleVersionOf(slug, versions), nil
+}
+
+// latestSatisfyingVersion is LatestCompatibleVersion among the versions within
+// the constraint of id.
+func latestSatisfyingVersion(id lucytypes.PackageId) (
+	*datatypes.ModrinthVersion,
+	error,
+) {
+	all, err := listVersions(id.Name)
+	if err != nil {
+		return nil, err
+	}
+	var versions []*datatypes.ModrinthVersion
+	for _, version := range all {
+		if syntax.SatisfiesConstraint(id, version.VersionNumber) {
+			versions = append(versions, version)
+		}
+	}
+	return latestCompatibleVersionOf(id.Name, versions), nil
+}
+
+func latestCompatibleVersionOf(
+	slug lucytypes.PackageName,
+	versions []*datatypes.ModrinthVersion,
+) (v *datatypes.ModrinthVersion) {
 	serverInfo := local.GetServerInfo()
 	if serverInfo.Executable == local.UnknownExecutable {
 		logger.Info("no executable found, unable to infer a compatible version. falling back to latest version")
-		return latestVersion(slug)
+		return latestVersionOf(slug, lucytypes.AllPlatform, versions)
 	}
 	for _, version := range versions {
+		if !versionSupportsLoader(version, serverInfo.Executable.Platform) {
+			continue
+		}
 		for _, gameVersion := range version.GameVersions {
 			if gameVersion == serverInfo.Executable.GameVersion &&
 				version.VersionType == "release" &&
@@ -0,0 +1,61 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modrinth
+
+import (
+	"testing"
+	"time"
+
+	"lucy/datatypes"
+	"lucy/lucytypes"
+)
+
+func TestLatestVersionOfSupportsLoader(t *testing.T) {
+	day := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	version := func(number string, loader string, published time.Time) *datatypes.ModrinthVersion {
+		return &datatypes.ModrinthVersion{
+			VersionNumber: lucytypes.PackageVersion(number),
+			Loaders:       []string{loader},
+			VersionType:   "release",
+			DatePublished: published,
+		}
+	}
+	// The NeoForge build of a version is often published last
+	versions := []*datatypes.ModrinthVersion{
+		version("mc1.21-0.5.9-fabric", "fabric", day),
+		version("mc1.21-0.5.9-neoforge", "neoforge", day.Add(time.Hour)),
+		version("mc1.21-0.5.8-forge", "forge", day.Add(-time.Hour)),
+	}
+
+	tests := []struct {
+		loader lucytypes.Platform
+		want   lucytypes.PackageVersion
+	}{
+		{lucytypes.Fabric, "mc1.21-0.5.9-fabric"},
+		{lucytypes.Forge, "mc1.21-0.5.8-forge"},
+		{lucytypes.AllPlatform, "mc1.21-0.5.9-neoforge"},
+	}
+	for _, tt := range tests {
+		v := latestVersionOf("sodium", tt.loader, versions)
+		if v == nil || v.VersionNumber != tt.want {
+			t.Errorf("latestVersionOf(%s) = %v, want %s", tt.loader, v, tt.want)
+		}
+	}
+	if v := latestVersionOf("sodium", lucytypes.Paper, versions); v != nil {
+		t.Errorf("latestVersionOf(paper) = %s, want none", v.VersionNumber)
+	}
+}
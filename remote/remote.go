@@ -24,46 +24,221 @@ package remote
 
 import (
 	"fmt"
+	"strings"
 
 	"lucy/logger"
 	"lucy/lucytypes"
+	"lucy/remote/curseforge"
+	"lucy/remote/github"
+	"lucy/remote/hangar"
+	"lucy/remote/mcdr"
+	"lucy/remote/modrinth"
 )
 
+// FetchSource gives the remote of id on source. With lucytypes.Auto, the
+// fastest source of the platform is used, see SelectSource.
 func FetchSource(
 	source lucytypes.Source,
 	id lucytypes.PackageId,
-) (remote *lucytypes.PackageRemote) {
+) (remote *lucytypes.PackageRemote, err error) {
 	if source == lucytypes.Auto {
-		source = SelectSource(id.Platform)
+		source, err = SelectSource(id.Platform)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	switch source {
 	case lucytypes.Modrinth:
-		// return modrinth.Fetch(id)
-	default:
-		logger.Fatal(fmt.Errorf("source fetch not supported yet:" + source.String()))
+		return modrinth.Fetch(id)
+	case lucytypes.CurseForge:
+		return curseforge.Fetch(id)
+	case lucytypes.Hangar:
+		return hangar.Fetch(id)
+	case lucytypes.GitHub:
+		return github.Fetch(id)
+	case lucytypes.McdrRepo:
+		return mcdr.Fetch(id)
 	}
-
-	return nil // unreachable
+	return nil, fmt.Errorf("%w: fetch from %s", ErrorUnsupportedInput, source.Title())
 }
 
 func GetDependencies(
 	source lucytypes.Source,
 	id lucytypes.PackageId,
-) *lucytypes.PackageDependencies {
-	return nil
+) (*lucytypes.PackageDependencies, error) {
+	switch source {
+	case lucytypes.Modrinth:
+		return modrinth.Dependencies(id)
+	case lucytypes.CurseForge:
+		return curseforge.Dependencies(id)
+	case lucytypes.Hangar:
+		return hangar.Dependencies(id)
+	case lucytypes.McdrRepo:
+		return mcdr.Dependencies(id)
+	}
+	return nil, nil
 }
 
 func GetInformation(
 	source lucytypes.Source,
 	id lucytypes.PackageId,
-) *lucytypes.PackageInformation {
-	return nil
+) (*lucytypes.PackageInformation, error) {
+	switch source {
+	case lucytypes.Modrinth:
+		return modrinth.Information(id.Name)
+	case lucytypes.CurseForge:
+		return curseforge.Information(id.Name)
+	case lucytypes.Hangar:
+		return hangar.Information(id.Name)
+	case lucytypes.McdrRepo:
+		return mcdr.Information(id.Name)
+	}
+	return nil, nil
 }
 
 func SearchForProject(
 	source lucytypes.Source,
 	query string,
 ) []lucytypes.PackageName {
-	return nil
+	id := lucytypes.PackageId{
+		Platform: lucytypes.AllPlatform,
+		Name:     lucytypes.PackageName(query),
+		Version:  lucytypes.AllVersion,
+	}
+	options := lucytypes.SearchOptions{IndexBy: lucytypes.ByRelevance}
+
+	var res *lucytypes.SearchResults
+	var err error
+	switch source {
+	case lucytypes.Modrinth:
+		res, err = modrinth.Search(id, options)
+	case lucytypes.CurseForge:
+		res, err = curseforge.Search(id, options)
+	case lucytypes.Hangar:
+		res, err = hangar.Search(id, options)
+	case lucytypes.McdrRepo:
+		res, err = mcdr.Search(id, options)
+	}
+	if err != nil {
+		logger.Warning(err)
+	}
+	if res == nil {
+		return nil
+	}
+
+	names := make([]lucytypes.PackageName, 0, len(res.Results))
+	for _, name := range res.Results {
+		names = append(names, lucytypes.PackageName(name))
+	}
+	return names
+}
+
+// Search searches source for id with options. Unlike SearchForProject, the
+// error is returned to the caller.
+func Search(
+	source lucytypes.Source,
+	id lucytypes.PackageId,
+	options lucytypes.SearchOptions,
+) (*lucytypes.SearchResults, error) {
+	switch source {
+	case lucytypes.Modrinth:
+		return modrinth.Search(id, options)
+	case lucytypes.CurseForge:
+		return curseforge.Search(id, options)
+	case lucytypes.Hangar:
+		return hangar.Search(id, options)
+	case lucytypes.McdrRepo:
+		return mcdr.Search(id, options)
+	}
+	return nil, fmt.Errorf("%w: search on %s", ErrorUnsupportedInput, source.Title())
+}
+
+// Resolve pins id to a version with its remote filled in, together with its
+// dependencies. A GitHub repository is resolved from its releases, MCDR plugins
+// come from the MCDR catalogue, plugins of Bukkit servers and proxies from
+// Hangar, and everything else from Modrinth.
+func Resolve(id lucytypes.PackageId) (p *lucytypes.Package, err error) {
+	return ResolveFrom(lucytypes.Modrinth, id)
+}
+
+// ResolveFrom is Resolve with mods looked up on source instead of Modrinth.
+// With lucytypes.Auto, the fastest source of the platform is used, see
+// SelectSource. Packages that only one source has, like MCDR plugins, come from
+// that source whatever source is.
+func ResolveFrom(
+	source lucytypes.Source,
+	id lucytypes.PackageId,
+) (p *lucytypes.Package, err error) {
+	_, fromGitHub := id.Name.GitHubRepo()
+	switch {
+	case fromGitHub:
+		// The dependencies come from the analyzed jar
+		return github.Resolve(id)
+	case id.Platform.IsBukkit(),
+		id.Platform == lucytypes.Velocity,
+		id.Platform == lucytypes.Waterfall:
+		// The dependencies come from the resolved version
+		return hangar.Resolve(id)
+	case id.Platform == lucytypes.Mcdr:
+		p, err = mcdr.Resolve(id)
+		if err != nil {
+			return nil, err
+		}
+		p.Dependencies, err = mcdr.Dependencies(p.Id)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return resolveMod(source, id)
+	}
+	return p, nil
+}
+
+func resolveMod(
+	source lucytypes.Source,
+	id lucytypes.PackageId,
+) (p *lucytypes.Package, err error) {
+	if source == lucytypes.Auto {
+		source, err = SelectSource(id.Platform)
+		if err != nil {
+			return nil, err
+		}
+	}
+	switch source {
+	case lucytypes.Modrinth:
+		return modrinth.Resolve(id)
+	case lucytypes.CurseForge:
+		return curseforge.Resolve(id)
+	}
+	return nil, fmt.Errorf("%w: resolve from %s", ErrorUnsupportedInput, source.Title())
+}
+
+// ResolveLocked is Resolve for a package that was locked from locked. A package
+// from GitHub can only be found through the repository it was installed from,
+// so it is looked up there again. A package identified by its file is looked up
+// by its slug. A mod from CurseForge is looked up on CurseForge again.
+func ResolveLocked(
+	id lucytypes.PackageId,
+	locked *lucytypes.PackageRemote,
+) (p *lucytypes.Package, err error) {
+	if locked != nil && locked.Source == lucytypes.GitHub {
+		repo, _, _ := strings.Cut(locked.RemoteId, "@")
+		id.Name = lucytypes.PackageName(lucytypes.GitHubPrefix + repo)
+	} else if locked != nil && locked.Slug != "" {
+		id.Name = locked.Slug
+	}
+	if locked != nil && locked.Source == lucytypes.CurseForge {
+		return ResolveFrom(lucytypes.CurseForge, id)
+	}
+	return Resolve(id)
+}
+
+// IdentifyInstalled fills in the Remote of the installed mods that can be found
+// on Modrinth by the hashes of their files. A failed lookup is only a warning,
+// as the mods can still be looked up by their mod ids.
+func IdentifyInstalled(serverInfo *lucytypes.ServerInfo) {
+	if err := modrinth.IdentifyFiles(serverInfo.Mods); err != nil {
+		logger.Warning(fmt.Errorf("cannot identify installed mods: %w", err))
+	}
 }
@@ -0,0 +1,331 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"lucy/logger"
+	"lucy/lucyerrors"
+	"lucy/lucytypes"
+	"lucy/remote/mcdr"
+	"lucy/syntax"
+)
+
+var (
+	ErrorIncompatible     = lucyerrors.New(lucyerrors.IncompatibleError, "incompatible package")
+	ErrorUnsupportedInput = errors.New("cannot resolve package")
+)
+
+// Resolution is the result of ResolveInstall.
+type Resolution struct {
+	// Install lists the packages to download, in the order they were resolved.
+	// Each one has its Remote and Dependencies filled in.
+	Install []lucytypes.Package
+	// Satisfied lists the installed packages that already meet a requirement.
+	Satisfied []lucytypes.Package
+	// Requests has the outcome of each of the ids, in the same order.
+	Requests []Request
+}
+
+// Request is the outcome of one of the ids given to ResolveInstall.
+type Request struct {
+	Id lucytypes.PackageId
+	// Package is the package in Install or Satisfied that meets the request,
+	// nil if Err is set.
+	Package *lucytypes.Package
+	// Err is why the package or one of its dependencies cannot be installed.
+	Err error
+}
+
+// ResolveInstall walks the required dependencies of ids transitively. Versions
+// are picked to be compatible with the server's game version and platform, and
+// any requirement that an installed package already meets is skipped. A
+// dependency shared by several ids is resolved once. Installed mods are found
+// by their slugs as well, so IdentifyInstalled should be called first.
+//
+// An incompatibility with an installed or resolved package is an error, unless
+// force is set, in which case it is only logged. An error fails the id it was
+// met for, which is reported in Requests, and the others are resolved anyway.
+// Dependencies that only failed ids need are left out of Install.
+//
+// Mods are looked up on source, see ResolveFrom. The dependencies of a package
+// are looked up where the package was found, as their names are slugs of that
+// source.
+func ResolveInstall(
+	ids []lucytypes.PackageId,
+	serverInfo *lucytypes.ServerInfo,
+	source lucytypes.Source,
+	force bool,
+) (resolution *Resolution) {
+	resolution = &Resolution{Requests: make([]Request, len(ids))}
+	installed := append([]lucytypes.Package{}, serverInfo.Mods...)
+	installed = append(installed, serverInfo.Plugins...)
+	if serverInfo.Mcdr != nil {
+		installed = append(installed, serverInfo.Mcdr.PluginList...)
+	}
+
+	// Every queued id is walked for one of ids, its root. neededBy holds the
+	// roots of each package in Install, and failed the ids that cannot be
+	// resolved, so the roots sharing them fail as well.
+	type queued struct {
+		id        lucytypes.PackageId
+		root      int
+		requested bool
+		source    lucytypes.Source
+	}
+	var (
+		queue     []queued
+		neededBy  [][]int
+		failed    []lucytypes.Package
+		failedErr []error
+		met       = make([]*lucytypes.Package, len(ids))
+	)
+	for i, id := range ids {
+		resolution.Requests[i].Id = id
+		queue = append(queue, queued{id: id, root: i, requested: true, source: source})
+	}
+	fail := func(item queued, err error) {
+		if !item.requested {
+			err = fmt.Errorf("dependency %s: %w", item.id.StringVersion(), err)
+		}
+		resolution.Requests[item.root].Err = err
+		failed = append(failed, lucytypes.Package{Id: item.id})
+		failedErr = append(failedErr, err)
+	}
+
+	for len(queue) > 0 {
+		item := queue[0]
+		queue = queue[1:]
+		if resolution.Requests[item.root].Err != nil {
+			continue
+		}
+		id := item.id
+		if id.Platform == lucytypes.AllPlatform {
+			id.Platform = serverInfo.Executable.Platform
+			item.id.Platform = id.Platform
+		}
+
+		// Only one version of a package can be installed, so whichever was
+		// resolved first wins
+		anyVersion := id
+		anyVersion.Version = lucytypes.AllVersion
+		anyVersion.Constraint = nil
+		if i := indexOfPackage(failed, anyVersion); i != -1 {
+			resolution.Requests[item.root].Err = failedErr[i]
+			continue
+		}
+		if i := indexOfPackage(resolution.Install, anyVersion); i != -1 {
+			neededBy[i] = append(neededBy[i], item.root)
+			if item.requested {
+				met[item.root] = &resolution.Install[i]
+			}
+			continue
+		}
+		if p := findPackage(resolution.Satisfied, anyVersion); p != nil {
+			if item.requested {
+				met[item.root] = p
+			}
+			continue
+		}
+		if p := findPackage(installed, anyVersion); p != nil {
+			if findPackage([]lucytypes.Package{*p}, id) == nil {
+				logger.Warning(
+					fmt.Errorf(
+						"%s is required, but %s is installed",
+						id.StringVersion(),
+						p.Id.StringVersion(),
+					),
+				)
+			}
+			resolution.Satisfied = append(resolution.Satisfied, *p)
+			if item.requested {
+				met[item.root] = p
+			}
+			continue
+		}
+
+		p, err := ResolveFrom(item.source, id)
+		if err != nil {
+			fail(item, err)
+			continue
+		}
+		// The name of a package from GitHub is only known now
+		if _, fromGitHub := id.Name.GitHubRepo(); fromGitHub {
+			anyVersion = p.Id
+			anyVersion.Version = lucytypes.AllVersion
+			if installed := findPackage(installed, anyVersion); installed != nil {
+				resolution.Satisfied = append(resolution.Satisfied, *installed)
+				if item.requested {
+					met[item.root] = installed
+				}
+				continue
+			}
+		}
+
+		if err := checkIncompatibilities(p, installed, resolution.Install, serverInfo, force); err != nil {
+			fail(item, err)
+			continue
+		}
+
+		resolution.Install = append(resolution.Install, *p)
+		neededBy = append(neededBy, []int{item.root})
+		if item.requested {
+			met[item.root] = p
+		}
+		dependencySource := item.source
+		if p.Remote != nil &&
+			(p.Remote.Source == lucytypes.Modrinth || p.Remote.Source == lucytypes.CurseForge) {
+			dependencySource = p.Remote.Source
+		}
+		for _, dependency := range p.Dependencies.Required {
+			queue = append(queue, queued{id: dependency, root: item.root, source: dependencySource})
+		}
+	}
+
+	// Install only keeps what the requests that did not fail need. The packages
+	// in Requests are copies, as filtering moves the ones in Install.
+	var install []lucytypes.Package
+	for i, p := range resolution.Install {
+		if slices.ContainsFunc(neededBy[i], func(root int) bool {
+			return resolution.Requests[root].Err == nil
+		}) {
+			install = append(install, p)
+		}
+	}
+	resolution.Install = install
+	for i := range resolution.Requests {
+		if resolution.Requests[i].Err == nil && met[i] != nil {
+			p := *met[i]
+			resolution.Requests[i].Package = &p
+		}
+	}
+	return resolution
+}
+
+// checkIncompatibilities gives an error if p is incompatible with the server,
+// an installed package or a package to install. With force, the error is only
+// logged.
+func checkIncompatibilities(
+	p *lucytypes.Package,
+	installed []lucytypes.Package,
+	install []lucytypes.Package,
+	serverInfo *lucytypes.ServerInfo,
+	force bool,
+) error {
+	if p.Id.Platform == lucytypes.Mcdr && serverInfo.Mcdr != nil {
+		err := mcdr.CheckCompatibility(p.Id, serverInfo.Mcdr.Version)
+		if errors.Is(err, mcdr.ErrorIncompatibleMcdr) {
+			err = fmt.Errorf("%w: %w", ErrorIncompatible, err)
+			if !force {
+				return err
+			}
+			logger.Warning(err)
+		} else if err != nil {
+			return err
+		}
+	}
+
+	for _, incompatible := range p.Dependencies.Incompatible {
+		conflict := findPackage(installed, incompatible)
+		if conflict == nil {
+			conflict = findPackage(install, incompatible)
+		}
+		if conflict == nil {
+			continue
+		}
+		err := fmt.Errorf(
+			"%w: %s is incompatible with %s",
+			ErrorIncompatible,
+			p.Id.StringVersion(),
+			conflict.Id.StringVersion(),
+		)
+		if !force {
+			return err
+		}
+		logger.Warning(err)
+	}
+	for _, other := range installed {
+		if other.Dependencies == nil || findPackage(
+			[]lucytypes.Package{*p},
+			other.Dependencies.Incompatible...,
+		) == nil {
+			continue
+		}
+		err := fmt.Errorf(
+			"%w: %s is incompatible with %s",
+			ErrorIncompatible,
+			other.Id.StringVersion(),
+			p.Id.StringVersion(),
+		)
+		if !force {
+			return err
+		}
+		logger.Warning(err)
+	}
+	return nil
+}
+
+// indexOfPackage is findPackage, giving the index in packages or -1.
+func indexOfPackage(packages []lucytypes.Package, id lucytypes.PackageId) int {
+	for i := range packages {
+		if findPackage(packages[i:i+1], id) != nil {
+			return i
+		}
+	}
+	return -1
+}
+
+// findPackage gives the first package in packages that matches any of ids.
+// A version of AllVersion, LatestVersion or LatestCompatibleVersion matches
+// any version, unless there is a constraint, which the version must satisfy.
+// An installed package also matches by its slug, if it was identified by its
+// file, see IdentifyInstalled.
+func findPackage(
+	packages []lucytypes.Package,
+	ids ...lucytypes.PackageId,
+) *lucytypes.Package {
+	for _, id := range ids {
+		for _, p := range packages {
+			if !HasName(p, id.Name) || !p.Id.Platform.Eq(id.Platform) {
+				continue
+			}
+			if id.Constraint != nil {
+				if syntax.SatisfiesConstraint(id, p.Id.Version) {
+					return &p
+				}
+				continue
+			}
+			switch id.Version {
+			case lucytypes.AllVersion, lucytypes.LatestVersion, lucytypes.LatestCompatibleVersion:
+				return &p
+			}
+			if p.Id.Version == id.Version {
+				return &p
+			}
+		}
+	}
+	return nil
+}
+
+// hasName tells whether p is called name, by its id or by its slug.
+func HasName(p lucytypes.Package, name lucytypes.PackageName) bool {
+	return p.Id.Name.Eq(name) ||
+		p.Remote != nil && p.Remote.Slug != "" && p.Remote.Slug.Eq(name)
+}
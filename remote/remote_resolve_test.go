@@ -0,0 +1,88 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"testing"
+
+	"lucy/lucytypes"
+)
+
+func TestResolveInstallMatchesInstalledSlug(t *testing.T) {
+	fabricApi := lucytypes.Package{
+		Id: lucytypes.PackageId{Platform: lucytypes.Fabric, Name: "fabric", Version: "0.92.2"},
+		Remote: &lucytypes.PackageRemote{
+			Source: lucytypes.Modrinth,
+			Slug:   "fabric-api",
+		},
+	}
+	serverInfo := &lucytypes.ServerInfo{
+		Mods: []lucytypes.Package{fabricApi},
+		Executable: &lucytypes.ExecutableInfo{
+			Platform:    lucytypes.Fabric,
+			GameVersion: "1.20.1",
+		},
+	}
+
+	resolution := ResolveInstall(
+		[]lucytypes.PackageId{{Platform: lucytypes.AllPlatform, Name: "fabric-api", Version: lucytypes.AllVersion}},
+		serverInfo,
+		lucytypes.Modrinth,
+		false,
+	)
+
+	if len(resolution.Install) != 0 {
+		t.Errorf("installing %v again", resolution.Install)
+	}
+	request := resolution.Requests[0]
+	if request.Err != nil {
+		t.Fatal(request.Err)
+	}
+	if request.Package == nil || request.Package.Id != fabricApi.Id {
+		t.Errorf("request met by %v, want the installed %s", request.Package, fabricApi.Id.StringVersion())
+	}
+}
+
+func TestFindPackage(t *testing.T) {
+	installed := []lucytypes.Package{
+		{Id: lucytypes.PackageId{Platform: lucytypes.Fabric, Name: "lithium", Version: "0.11.2"}},
+		{
+			Id:     lucytypes.PackageId{Platform: lucytypes.Fabric, Name: "fabric", Version: "0.92.2"},
+			Remote: &lucytypes.PackageRemote{Slug: "fabric-api"},
+		},
+	}
+	tests := []struct {
+		id   lucytypes.PackageId
+		want lucytypes.PackageName
+	}{
+		{lucytypes.PackageId{Platform: lucytypes.Fabric, Name: "lithium", Version: lucytypes.AllVersion}, "lithium"},
+		{lucytypes.PackageId{Platform: lucytypes.Fabric, Name: "Lithium", Version: "0.11.2"}, "lithium"},
+		{lucytypes.PackageId{Platform: lucytypes.Fabric, Name: "lithium", Version: "0.10.0"}, ""},
+		{lucytypes.PackageId{Platform: lucytypes.Forge, Name: "lithium", Version: lucytypes.AllVersion}, ""},
+		{lucytypes.PackageId{Platform: lucytypes.Fabric, Name: "fabric-api", Version: lucytypes.AllVersion}, "fabric"},
+		{lucytypes.PackageId{Platform: lucytypes.Fabric, Name: "fabric", Version: lucytypes.AllVersion}, "fabric"},
+	}
+	for _, tt := range tests {
+		var got lucytypes.PackageName
+		if p := findPackage(installed, tt.id); p != nil {
+			got = p.Id.Name
+		}
+		if got != tt.want {
+			t.Errorf("findPackage(%s) = %q, want %q", tt.id.StringVersion(), got, tt.want)
+		}
+	}
+}
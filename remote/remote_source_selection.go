@@ -23,25 +23,37 @@ import (
 	"sync"
 	"time"
 
+	"lucy/logger"
+	"lucy/lucyerrors"
 	"lucy/lucytypes"
 )
 
 var AvailableSources = map[lucytypes.Platform][]lucytypes.Source{
-	lucytypes.Fabric: {lucytypes.CurseForge, lucytypes.Modrinth},
-	lucytypes.Forge:  {lucytypes.CurseForge, lucytypes.Modrinth},
-	lucytypes.Mcdr:   {lucytypes.McdrRepo},
+	lucytypes.Fabric:    {lucytypes.CurseForge, lucytypes.Modrinth},
+	lucytypes.Forge:     {lucytypes.CurseForge, lucytypes.Modrinth},
+	lucytypes.Quilt:     {lucytypes.CurseForge, lucytypes.Modrinth},
+	lucytypes.Neoforge:  {lucytypes.CurseForge, lucytypes.Modrinth},
+	lucytypes.Mcdr:      {lucytypes.McdrRepo},
+	lucytypes.Paper:     {lucytypes.Hangar},
+	lucytypes.Purpur:    {lucytypes.Hangar},
+	lucytypes.Spigot:    {lucytypes.Hangar},
+	lucytypes.Velocity:  {lucytypes.Hangar},
+	lucytypes.Waterfall: {lucytypes.Hangar},
 }
 
 var SpeedTestUrls = map[lucytypes.Source]string{
 	lucytypes.CurseForge: "https://mediafilez.forgecdn.net/files/4834/896/fabric-api-0.87.2%2B1.19.4.jar",
 	lucytypes.Modrinth:   "https://cdn.modrinth.com/data/P7dR8mSH/versions/nyAmoHlr/fabric-api-0.87.2%2B1.19.4.jar",
+	lucytypes.Hangar:     "https://hangarcdn.papermc.io/plugins/ViaVersion/ViaVersion/versions/5.0.3/PAPER/ViaVersion-5.0.3.jar",
+	lucytypes.McdrRepo:   "https://api.mcdreforged.com/catalogue/everything_slim.json.gz",
 }
 
+var ErrorNoAvailableSource = lucyerrors.New(lucyerrors.NetworkError, "no available source")
+
 const slow float64 = 0x7FF0000000000000 // inf
 
-// SelectSource is an alternative to MultiSourceDownload. It fetches a fixed url
-// from SpeedTestUrls and measures the download speed of each source. The source
-// with the fastest download speed is returned.
+// SelectSource fetches a fixed url from SpeedTestUrls and measures the download
+// speed of each source. The source with the fastest download speed is returned.
 //
 // Pros:
 //   - Fastest source can be stored for later use.
@@ -49,28 +61,38 @@ const slow float64 = 0x7FF0000000000000 // inf
 //
 // Cons:
 //   - Speed test might not be representative
-func SelectSource(platform lucytypes.Platform) lucytypes.Source {
+//
+// An error of lucyerrors.NetworkError is given if no source can be reached.
+func SelectSource(platform lucytypes.Platform) (lucytypes.Source, error) {
 	slowest := slow
-	fastestSource := lucytypes.UnknownPlatform
+	fastestSource := lucytypes.UnknownSource
 	wg := sync.WaitGroup{}
+	mu := sync.Mutex{}
 	for _, source := range AvailableSources[platform] {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			speed := testDownloadSpeed(SpeedTestUrls[source])
+			mu.Lock()
+			defer mu.Unlock()
 			if speed < slowest {
+				slowest = speed
 				fastestSource = source
 			}
-			fmt.Printf("Speed for %s: %f\n", source, speed)
+			logger.Debug(fmt.Sprintf("speed for %s: %f", source, speed))
 		}()
 	}
 
 	wg.Wait()
-	if fastestSource == lucytypes.UnknownPlatform {
-		panic("No available source")
+	if fastestSource == lucytypes.UnknownSource {
+		return lucytypes.UnknownSource, fmt.Errorf(
+			"%w for %s",
+			ErrorNoAvailableSource,
+			platform.Title(),
+		)
 	}
 
-	return fastestSource
+	return fastestSource, nil
 }
 
 func testDownloadSpeed(url string) (elapsedTime float64) {
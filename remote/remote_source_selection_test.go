@@ -0,0 +1,75 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"net/http"
+	"testing"
+
+	"lucy/lucytypes"
+	"lucy/testutil"
+)
+
+func TestSpeedTestUrls(t *testing.T) {
+	for platform, sources := range AvailableSources {
+		for _, source := range sources {
+			if SpeedTestUrls[source] == "" {
+				t.Errorf("no speed test url for %s, which serves %s", source, platform)
+			}
+		}
+	}
+}
+
+func TestSelectSource(t *testing.T) {
+	server := testutil.StandIn(t,
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(make([]byte, 4096))
+		}),
+		nil,
+	)
+
+	urls := SpeedTestUrls
+	SpeedTestUrls = map[lucytypes.Source]string{
+		lucytypes.Hangar:   server.URL,
+		lucytypes.McdrRepo: server.URL,
+	}
+	t.Cleanup(func() { SpeedTestUrls = urls })
+
+	tests := []struct {
+		platform lucytypes.Platform
+		want     lucytypes.Source
+	}{
+		{lucytypes.Paper, lucytypes.Hangar},
+		{lucytypes.Velocity, lucytypes.Hangar},
+		{lucytypes.Mcdr, lucytypes.McdrRepo},
+	}
+	for _, tt := range tests {
+		source, err := SelectSource(tt.platform)
+		if err != nil {
+			t.Errorf("SelectSource(%s): %v", tt.platform, err)
+			continue
+		}
+		if source != tt.want {
+			t.Errorf("SelectSource(%s) = %s, want %s", tt.platform, source, tt.want)
+		}
+	}
+
+	// The stand-in cannot be reached by any source of Fabric
+	if _, err := SelectSource(lucytypes.Fabric); err == nil {
+		t.Error("SelectSource(fabric) gave no error without a source to reach")
+	}
+}
@@ -28,13 +28,24 @@ limitations under the License.
 //   - minecraft@1.19 (recommended)
 //   - minecraft/minecraft@1.16.5 (= minecraft@1.16.5)
 //   - 1.8.9 (= minecraft@1.8.9)
+//   - fabric/sodium@^0.5
+//   - lithium@latest
+//
+// Instead of an exact version, a version constraint can be given, see
+// ParseVersionConstraint for the accepted forms. "latest" and "compatible" ask
+// for the latest version, and the latest version compatible with the server.
+//
+// A package that is only published on GitHub Releases is specified by its
+// repository instead, in the format of "github:owner/repo@tag". The tag can be
+// omitted to use the latest release.
 package syntax
 
 import (
-	"errors"
+	"fmt"
 	"strings"
 
 	"lucy/logger"
+	"lucy/lucyerrors"
 	"lucy/lucytypes"
 )
 
@@ -60,27 +71,30 @@ func sanitize(s string) (clean string) {
 }
 
 var (
-	ESyntax   = errors.New("invalid syntax")
-	EPlatform = errors.New("invalid platform")
+	ESyntax   = lucyerrors.New(lucyerrors.PackageSyntaxError, "invalid syntax")
+	EPlatform = lucyerrors.New(lucyerrors.PackageSyntaxError, "invalid platform")
 )
 
 // Parse is exported to parse a string into a PackageId struct. This function
-// should only be used on user inputs. Therefore, It does NOT return an
-// error but instead invokes a fatal if the input is invalid.
-func Parse(s string) (p lucytypes.PackageId) {
-	s = sanitize(s)
-	p = lucytypes.PackageId{}
-	var err error
-	p.Platform, p.Name, p.Version, err = parseOperatorAt(s)
+// should only be used on user inputs. An invalid input gives an error wrapping
+// ESyntax or EPlatform, which are of lucyerrors.PackageSyntaxError.
+func Parse(s string) (p lucytypes.PackageId, err error) {
+	input := s
+	if strings.HasPrefix(strings.ToLower(s), lucytypes.GitHubPrefix) {
+		p.Platform, p.Name, p.Version, err = parseGitHub(s)
+	} else {
+		s = sanitize(s)
+		p.Platform, p.Name, p.Version, err = parseOperatorAt(s)
+	}
+	if err == nil && isVersionConstraint(string(p.Version)) {
+		p.Constraint, err = ParseVersionConstraint(string(p.Version))
+		p.Version = lucytypes.AllVersion
+	}
 	if err != nil {
-		if errors.Is(err, ESyntax) {
-			panic(err)
-		} else {
-			logger.Fatal(err)
-		}
+		return lucytypes.PackageId{}, fmt.Errorf("%w: %s", err, input)
 	}
 	logger.Debug("parsed input as package: " + p.FullString())
-	return
+	return p, nil
 }
 
 // parseOperatorAt is called first since '@' operator always occur after '/' (equivalent
@@ -95,7 +109,7 @@ func parseOperatorAt(s string) (
 
 	pl, n, err = parseOperatorSlash(split[0])
 	if err != nil {
-		return "", "", "", ESyntax
+		return "", "", "", err
 	}
 
 	if len(split) == 1 {
@@ -141,3 +155,27 @@ func parseOperatorSlash(s string) (
 
 	return
 }
+
+// parseGitHub does not sanitize the repository or the tag, since GitHub allows
+// underlines in repository names and tags are matched exactly.
+func parseGitHub(s string) (
+	pl lucytypes.Platform,
+	n lucytypes.PackageName,
+	v lucytypes.PackageVersion,
+	err error,
+) {
+	repo, tag, hasTag := strings.Cut(s[len(lucytypes.GitHubPrefix):], "@")
+	owner, name, found := strings.Cut(repo, "/")
+	if !found || owner == "" || name == "" || strings.Contains(name, "/") {
+		return "", "", "", ESyntax
+	}
+
+	v = lucytypes.AllVersion
+	if hasTag {
+		if tag == "" {
+			return "", "", "", ESyntax
+		}
+		v = lucytypes.PackageVersion(tag)
+	}
+	return lucytypes.AllPlatform, lucytypes.PackageName(lucytypes.GitHubPrefix + repo), v, nil
+}
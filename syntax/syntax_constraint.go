@@ -0,0 +1,306 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syntax
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"lucy/lucytypes"
+)
+
+// isVersionConstraint tells a constraint apart from an exact version.
+func isVersionConstraint(v string) bool {
+	if v == "" {
+		return false
+	}
+	if strings.ContainsAny(v[:1], "^~<>=![(") || strings.ContainsAny(v, " ,*") ||
+		strings.Contains(v, "||") {
+		return true
+	}
+	return v == "x" || strings.HasSuffix(v, ".x")
+}
+
+var operatorSpacing = regexp.MustCompile(`(>=|<=|!=|==|>|<|=|\^|~>|~)\s+`)
+
+// ParseVersionConstraint parses what is given in place of an exact version,
+// after the '@' operator, and the version ranges that mods declare for their
+// dependencies. The following forms are accepted:
+//
+//   - ^0.5       compatible with 0.5, the same as ">=0.5 <0.6"
+//   - ~1.4       patches of 1.4, the same as ">=1.4 <1.5"
+//   - 1.2.x      also 1.2.*, the same as ">=1.2 <1.3"
+//   - >=1.2.0 <2 comparisons separated by spaces or commas must all be met
+//   - ^1 || ^2   either side of "||" may be met
+//   - [1.0,2.0)  Maven ranges as used by Forge, several of them can be given,
+//     separated by commas
+//
+// The keywords "latest" and "compatible" are not constraints, they are parsed as
+// lucytypes.LatestVersion and lucytypes.LatestCompatibleVersion.
+//
+// An error wrapping ESyntax is given if s is not a valid constraint.
+func ParseVersionConstraint(s string) (c *lucytypes.VersionConstraint, err error) {
+	c = &lucytypes.VersionConstraint{Raw: s}
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "[") || strings.HasPrefix(s, "(") {
+		c.Alternatives, err = parseMavenRanges(s)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+
+	s = operatorSpacing.ReplaceAllString(strings.ReplaceAll(s, ",", " "), "$1")
+	for _, alternative := range strings.Split(s, "||") {
+		fields := strings.Fields(alternative)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("%w: empty version constraint in %q", ESyntax, c.Raw)
+		}
+		conditions := []lucytypes.VersionCondition{}
+		for _, field := range fields {
+			parsed, err := parseVersionCondition(field)
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, parsed...)
+		}
+		c.Alternatives = append(c.Alternatives, conditions)
+	}
+	return c, nil
+}
+
+// conditionOperators are ordered so that longer operators are matched first.
+var conditionOperators = []struct {
+	prefix   string
+	operator lucytypes.VersionOperator
+}{
+	{">=", lucytypes.OperatorGe},
+	{"<=", lucytypes.OperatorLe},
+	{"!=", lucytypes.OperatorNe},
+	{"==", lucytypes.OperatorEq},
+	{">", lucytypes.OperatorGt},
+	{"<", lucytypes.OperatorLt},
+	{"=", lucytypes.OperatorEq},
+}
+
+// parseVersionCondition expands the shorthands into plain comparisons.
+func parseVersionCondition(s string) (conditions []lucytypes.VersionCondition, err error) {
+	switch {
+	case s == "*" || s == "x":
+		return nil, nil
+	case strings.HasPrefix(s, "^"):
+		return versionBounds(s, s[1:], caretUpperBound)
+	case strings.HasPrefix(s, "~>"):
+		return versionBounds(s, s[2:], tildeUpperBound)
+	case strings.HasPrefix(s, "~"):
+		return versionBounds(s, s[1:], tildeUpperBound)
+	case strings.HasSuffix(s, ".x") || strings.HasSuffix(s, ".*"):
+		return versionBounds(s, s[:len(s)-2], wildcardUpperBound)
+	}
+
+	for _, o := range conditionOperators {
+		if v, found := strings.CutPrefix(s, o.prefix); found {
+			if v == "" {
+				return nil, fmt.Errorf("%w: no version after %q", ESyntax, o.prefix)
+			}
+			return []lucytypes.VersionCondition{
+				{Operator: o.operator, Version: lucytypes.PackageVersion(v)},
+			}, nil
+		}
+	}
+	return []lucytypes.VersionCondition{
+		{Operator: lucytypes.OperatorEq, Version: lucytypes.PackageVersion(s)},
+	}, nil
+}
+
+// versionBounds gives the conditions ">=lower <upper", where upper is worked
+// out from the numbers in lower.
+func versionBounds(
+	raw string,
+	lower string,
+	upperBound func(numbers []int) []int,
+) ([]lucytypes.VersionCondition, error) {
+	numbers := leadingNumbers(lower)
+	if len(numbers) == 0 {
+		return nil, fmt.Errorf("%w: invalid version in %q", ESyntax, raw)
+	}
+	upper := upperBound(numbers)
+	parts := make([]string, 0, len(upper))
+	for _, n := range upper {
+		parts = append(parts, strconv.Itoa(n))
+	}
+	return []lucytypes.VersionCondition{
+		{Operator: lucytypes.OperatorGe, Version: lucytypes.PackageVersion(lower)},
+		{Operator: lucytypes.OperatorLt, Version: lucytypes.PackageVersion(strings.Join(parts, "."))},
+	}, nil
+}
+
+// leadingNumbers gives the dot separated numbers at the start of a version,
+// for example [1, 2] for "1.2-beta".
+func leadingNumbers(v string) (numbers []int) {
+	for _, part := range strings.Split(v, ".") {
+		end := 0
+		for end < len(part) && part[end] >= '0' && part[end] <= '9' {
+			end++
+		}
+		if end == 0 {
+			break
+		}
+		n, _ := strconv.Atoi(part[:end])
+		numbers = append(numbers, n)
+		if end != len(part) {
+			break
+		}
+	}
+	return numbers
+}
+
+// caretUpperBound increments the first number that is not zero, so "^0.5"
+// stays below 0.6 and "^1.2" stays below 2.
+func caretUpperBound(numbers []int) []int {
+	for i, n := range numbers {
+		if n != 0 {
+			return append(append([]int{}, numbers[:i]...), n+1)
+		}
+	}
+	return append(append([]int{}, numbers[:len(numbers)-1]...), numbers[len(numbers)-1]+1)
+}
+
+// tildeUpperBound increments the minor number, or the major number if there is
+// no minor one, so "~1.4.2" stays below 1.5 and "~1" stays below 2.
+func tildeUpperBound(numbers []int) []int {
+	if len(numbers) == 1 {
+		return []int{numbers[0] + 1}
+	}
+	return []int{numbers[0], numbers[1] + 1}
+}
+
+// wildcardUpperBound increments the last number, so "1.2.x" stays below 1.3.
+func wildcardUpperBound(numbers []int) []int {
+	return append(append([]int{}, numbers[:len(numbers)-1]...), numbers[len(numbers)-1]+1)
+}
+
+var mavenRange = regexp.MustCompile(`^\s*([\[(])([^\])]*)([\])])\s*(,|$)`)
+
+// parseMavenRanges parses ranges such as "[1.0,2.0)", "(,1.0]", "[1.0,)" and
+// "[1.0]", each of which is an alternative.
+func parseMavenRanges(s string) (alternatives [][]lucytypes.VersionCondition, err error) {
+	rest := s
+	for rest != "" {
+		match := mavenRange.FindStringSubmatch(rest)
+		if match == nil {
+			return nil, fmt.Errorf("%w: invalid version range %q", ESyntax, s)
+		}
+		rest = rest[len(match[0]):]
+		open, inner, closing := match[1], match[2], match[3]
+
+		lower, upper, isRange := strings.Cut(inner, ",")
+		lower, upper = strings.TrimSpace(lower), strings.TrimSpace(upper)
+		if !isRange {
+			if open != "[" || closing != "]" || lower == "" {
+				return nil, fmt.Errorf("%w: invalid version range %q", ESyntax, s)
+			}
+			alternatives = append(
+				alternatives,
+				[]lucytypes.VersionCondition{
+					{Operator: lucytypes.OperatorEq, Version: lucytypes.PackageVersion(lower)},
+				},
+			)
+			continue
+		}
+
+		conditions := []lucytypes.VersionCondition{}
+		if lower != "" {
+			conditions = append(
+				conditions, lucytypes.VersionCondition{
+					Operator: map[string]lucytypes.VersionOperator{
+						"[": lucytypes.OperatorGe,
+						"(": lucytypes.OperatorGt,
+					}[open],
+					Version: lucytypes.PackageVersion(lower),
+				},
+			)
+		}
+		if upper != "" {
+			conditions = append(
+				conditions, lucytypes.VersionCondition{
+					Operator: map[string]lucytypes.VersionOperator{
+						"]": lucytypes.OperatorLe,
+						")": lucytypes.OperatorLt,
+					}[closing],
+					Version: lucytypes.PackageVersion(upper),
+				},
+			)
+		}
+		alternatives = append(alternatives, conditions)
+	}
+	return alternatives, nil
+}
+
+// SatisfiesConstraint tells whether version is within the constraint of id.
+// Any version satisfies an id without a constraint. Versions are compared with
+// ComparePackageVersions, a version that cannot be compared does not satisfy
+// the constraint.
+func SatisfiesConstraint(id lucytypes.PackageId, version lucytypes.PackageVersion) bool {
+	if id.Constraint == nil {
+		return true
+	}
+	for _, alternative := range id.Constraint.Alternatives {
+		satisfied := true
+		for _, condition := range alternative {
+			if !satisfiesCondition(id.Platform, version, condition) {
+				satisfied = false
+				break
+			}
+		}
+		if satisfied {
+			return true
+		}
+	}
+	return false
+}
+
+func satisfiesCondition(
+	platform lucytypes.Platform,
+	version lucytypes.PackageVersion,
+	condition lucytypes.VersionCondition,
+) bool {
+	c, err := ComparePackageVersions(
+		&lucytypes.PackageId{Platform: platform, Version: version},
+		&lucytypes.PackageId{Platform: platform, Version: condition.Version},
+	)
+	if err != nil {
+		return false
+	}
+	switch condition.Operator {
+	case lucytypes.OperatorEq:
+		return c == 0
+	case lucytypes.OperatorNe:
+		return c != 0
+	case lucytypes.OperatorGt:
+		return c > 0
+	case lucytypes.OperatorGe:
+		return c >= 0
+	case lucytypes.OperatorLt:
+		return c < 0
+	case lucytypes.OperatorLe:
+		return c <= 0
+	}
+	return false
+}
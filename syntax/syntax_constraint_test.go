@@ -0,0 +1,188 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syntax
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"lucy/lucyerrors"
+	"lucy/lucytypes"
+)
+
+// formatConstraint writes the conditions of c as ">=1.0 <2.0 || ==3.0".
+func formatConstraint(c *lucytypes.VersionConstraint) string {
+	alternatives := make([]string, 0, len(c.Alternatives))
+	for _, alternative := range c.Alternatives {
+		conditions := make([]string, 0, len(alternative))
+		for _, condition := range alternative {
+			conditions = append(conditions, string(condition.Operator)+condition.Version.String())
+		}
+		alternatives = append(alternatives, strings.Join(conditions, " "))
+	}
+	return strings.Join(alternatives, " || ")
+}
+
+func TestParseVersionConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		want       string
+	}{
+		// Caret
+		{"^1.2.3", ">=1.2.3 <2"},
+		{"^0.5", ">=0.5 <0.6"},
+		{"^0.0.3", ">=0.0.3 <0.0.4"},
+		{"^0", ">=0 <1"},
+		{"^1.20.1-beta.2", ">=1.20.1-beta.2 <2"},
+		// Tilde
+		{"~1.4.2", ">=1.4.2 <1.5"},
+		{"~1", ">=1 <2"},
+		{"~>2.1", ">=2.1 <2.2"},
+		// Wildcards
+		{"1.2.x", ">=1.2 <1.3"},
+		{"1.20.*", ">=1.20 <1.21"},
+		{"*", ""},
+		// Comparisons
+		{">=1.2.0 <2", ">=1.2.0 <2"},
+		{">=1.2.0, <2", ">=1.2.0 <2"},
+		{">= 1.2.0 < 2", ">=1.2.0 <2"},
+		{"!=1.5", "!=1.5"},
+		{"==1.5", "==1.5"},
+		{"=1.5", "==1.5"},
+		{">1 <=3", ">1 <=3"},
+		// Alternatives
+		{"^1 || ^2", ">=1 <2 || >=2 <3"},
+		{"<1.0 || >=2.0 <3.0", "<1.0 || >=2.0 <3.0"},
+		// Maven ranges
+		{"[1.0,2.0)", ">=1.0 <2.0"},
+		{"(1.0,2.0]", ">1.0 <=2.0"},
+		{"[1.0,)", ">=1.0"},
+		{"(,1.0]", "<=1.0"},
+		{"[1.0]", "==1.0"},
+		{"[ 47.1 , 48 )", ">=47.1 <48"},
+		{"[1.0,1.2),[1.5,)", ">=1.0 <1.2 || >=1.5"},
+	}
+	for _, tt := range tests {
+		c, err := ParseVersionConstraint(tt.constraint)
+		if err != nil {
+			t.Errorf("ParseVersionConstraint(%q): %v", tt.constraint, err)
+			continue
+		}
+		if got := formatConstraint(c); got != tt.want {
+			t.Errorf("ParseVersionConstraint(%q) = %q, want %q", tt.constraint, got, tt.want)
+		}
+		if c.Raw != tt.constraint {
+			t.Errorf("ParseVersionConstraint(%q).Raw = %q", tt.constraint, c.Raw)
+		}
+	}
+}
+
+func TestParseVersionConstraintInvalid(t *testing.T) {
+	for _, constraint := range []string{
+		"^", "^x", "~beta", ">=", "1.0 ||", "|| 1.0", "[1.0,2.0", "(1.0)", "[]", "[1.0,2.0) junk", "x.x",
+	} {
+		_, err := ParseVersionConstraint(constraint)
+		if !errors.Is(err, ESyntax) {
+			t.Errorf("ParseVersionConstraint(%q): error = %v, want %v", constraint, err, ESyntax)
+		}
+	}
+}
+
+func TestParseConstraintInput(t *testing.T) {
+	tests := []struct {
+		input      string
+		constraint string
+	}{
+		{"fabric/sodium@^0.5", ">=0.5 <0.6"},
+		{"lithium@0.11.x", ">=0.11 <0.12"},
+		{"forge/jei@[15.2,16)", ">=15.2 <16"},
+		{"carpet@>=1.4 <1.5", ">=1.4 <1.5"},
+		{"carpet@1.4.112", ""},
+		{"carpet@latest", ""},
+	}
+	for _, tt := range tests {
+		p, err := Parse(tt.input)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.input, err)
+			continue
+		}
+		if tt.constraint == "" {
+			if p.Constraint != nil {
+				t.Errorf("Parse(%q) has constraint %s", tt.input, formatConstraint(p.Constraint))
+			}
+			continue
+		}
+		if p.Constraint == nil {
+			t.Errorf("Parse(%q) has no constraint", tt.input)
+			continue
+		}
+		if got := formatConstraint(p.Constraint); got != tt.constraint || p.Version != lucytypes.AllVersion {
+			t.Errorf("Parse(%q) = %s@%s, want constraint %s", tt.input, p.Version, got, tt.constraint)
+		}
+	}
+
+	if _, err := Parse("sodium@^"); lucyerrors.KindOf(err) != lucyerrors.PackageSyntaxError {
+		t.Errorf("Parse(sodium@^): error = %v, want a syntax error", err)
+	}
+}
+
+func TestSatisfiesConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    lucytypes.PackageVersion
+		want       bool
+	}{
+		{"^0.5", "0.5.8", true},
+		{"^0.5", "0.6.0", false},
+		{"^0.5", "0.4.9", false},
+		{"^1.2", "1.9.0", true},
+		{"^1.2", "2.0.0", false},
+		{"~1.4.2", "1.4.10", true},
+		{"~1.4.2", "1.5.0", false},
+		{"1.20.x", "1.20.4", true},
+		{"1.20.x", "1.21", false},
+		{">=1.2.0 <2", "1.10.0", true},
+		{">=1.2.0 <2", "1.1.9", false},
+		{"^1 || ^3", "3.2", true},
+		{"^1 || ^3", "2.0", false},
+		{"!=1.5", "1.5.0", false},
+		{"[47.1,48)", "47.2.0", true},
+		{"[47.1,48)", "48.0.1", false},
+		{"(,1.0]", "1.0", true},
+		{"[1.0]", "1.0.1", false},
+		{"*", "0.0.1", true},
+		// Loader and game version suffixes are not part of the version
+		{"^0.5", "mc1.20.1-0.5.8-fabric", true},
+		{">=0.92", "0.92.2+1.20.1", true},
+	}
+	for _, tt := range tests {
+		c, err := ParseVersionConstraint(tt.constraint)
+		if err != nil {
+			t.Fatalf("ParseVersionConstraint(%q): %v", tt.constraint, err)
+		}
+		id := lucytypes.PackageId{Platform: lucytypes.Fabric, Name: "sodium", Constraint: c}
+		if got := SatisfiesConstraint(id, tt.version); got != tt.want {
+			t.Errorf("%s satisfies %q = %v, want %v", tt.version, tt.constraint, got, tt.want)
+		}
+	}
+
+	// No constraint is satisfied by anything
+	if !SatisfiesConstraint(lucytypes.PackageId{Name: "sodium"}, "whatever") {
+		t.Error("a version does not satisfy an id without a constraint")
+	}
+}
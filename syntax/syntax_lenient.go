@@ -0,0 +1,295 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syntax
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Version strings of mods and plugins are only loosely semver. Besides the
+// version of the package itself, they often carry the game version they are
+// built for and the mod loader, in any order:
+//
+//   - 0.5.8+mc1.20.4
+//   - 1.20.1-0.4.3
+//   - mc1.20-2.1.0
+//   - 2024.3.1-fabric
+//   - 1.20.1-forge-47.2.0
+//
+// parseLenientVersion drops the game version tags, loader names and build
+// metadata, and keeps what orders the releases of one package.
+
+// lenientVersion is a version string reduced to what orders it.
+type lenientVersion struct {
+	// release holds the numeric components, of any count. Missing components
+	// compare as 0, so 1.2 is the same as 1.2.0.
+	release []int
+	// suffix is a letter directly after the release that is not a pre-release
+	// keyword, like the "a" in 1.2.3a. It marks a fix after the release.
+	suffix string
+	// prerelease holds the identifiers of a pre-release, nil for a release.
+	prerelease []string
+}
+
+var loaderNames = map[string]bool{
+	"fabric":     true,
+	"forge":      true,
+	"neoforge":   true,
+	"quilt":      true,
+	"bukkit":     true,
+	"spigot":     true,
+	"paper":      true,
+	"purpur":     true,
+	"folia":      true,
+	"sponge":     true,
+	"velocity":   true,
+	"bungeecord": true,
+	"waterfall":  true,
+	"mcdr":       true,
+}
+
+// prereleaseRanks orders the known pre-release keywords, identifiers that are
+// not listed here are ordered lexically after them.
+var prereleaseRanks = map[string]int{
+	"snapshot": 1,
+	"dev":      1,
+	"alpha":    2,
+	"a":        2,
+	"beta":     3,
+	"b":        3,
+	"pre":      4,
+	"preview":  4,
+	"rc":       5,
+}
+
+var (
+	// The letters after the numbers may be separated by a dot, as in 0.5.1.f
+	numericPattern = regexp.MustCompile(`^(\d+(?:\.\d+)*)(?:\.?([a-z]+))?(\d*)$`)
+	// Only game versions since 1.7 are recognized without a "mc" prefix, older
+	// ones are too easily mistaken for the version of the package.
+	gameVersionPattern = regexp.MustCompile(`^1\.([7-9]|[1-9]\d)(\.\d{1,2})?$`)
+	snapshotPattern    = regexp.MustCompile(`^\d{2}w\d{2}[a-z]$`)
+	identifierPattern  = regexp.MustCompile(`[a-z]+|\d+`)
+)
+
+type lenientToken struct {
+	text string
+	// numeric is set if the token starts with a version number.
+	numeric bool
+	// game is set if the token looks like a game version. It is only taken as
+	// one if there is another numeric token.
+	game bool
+	// tagged is set if the token is certainly a game version, because it is
+	// prefixed by "mc" or is a snapshot.
+	tagged bool
+}
+
+// parseLenientVersion gives false if there is no version number in v, or only
+// a range of game versions.
+func parseLenientVersion(v string) (parsed lenientVersion, ok bool) {
+	v = strings.ToLower(strings.TrimSpace(v))
+	v, _, _ = strings.Cut(v, "+") // build metadata does not order versions
+
+	var tokens []lenientToken
+	for _, t := range strings.FieldsFunc(v, func(r rune) bool {
+		return r == '-' || r == '_' || r == ' '
+	}) {
+		if loaderNames[t] {
+			continue
+		}
+		if strings.HasPrefix(t, "mc") && len(t) > 2 && t[2] >= '0' && t[2] <= '9' {
+			tokens = append(tokens, lenientToken{text: t[2:], game: true, tagged: true})
+			continue
+		}
+		if snapshotPattern.MatchString(t) {
+			tokens = append(tokens, lenientToken{text: t, game: true, tagged: true})
+			continue
+		}
+		if len(t) > 1 && t[0] == 'v' && t[1] >= '0' && t[1] <= '9' {
+			t = t[1:]
+		}
+		tokens = append(tokens, lenientToken{
+			text:    t,
+			numeric: numericPattern.MatchString(t),
+			game:    gameVersionPattern.MatchString(t),
+		})
+	}
+
+	// The version of the package is the first numeric token that is not a
+	// game version. If all of them look like game versions, the last one is
+	// taken, as the game version usually comes first. Game versions in rising
+	// order are a range of them, as in 1.8-1.20.1, with no version of the
+	// package at all.
+	release, candidates := -1, 0
+	for i, t := range tokens {
+		if !t.numeric || t.tagged {
+			continue
+		}
+		candidates++
+		if !t.game && release == -1 {
+			release = i
+		}
+	}
+	if release == -1 {
+		for i := len(tokens) - 1; i >= 0; i-- {
+			if tokens[i].numeric && !tokens[i].tagged {
+				release = i
+				break
+			}
+		}
+		if candidates > 1 && isGameVersionRange(tokens) {
+			return lenientVersion{}, false
+		}
+	}
+	if release == -1 {
+		return lenientVersion{}, false
+	}
+
+	m := numericPattern.FindStringSubmatch(tokens[release].text)
+	for _, c := range strings.Split(m[1], ".") {
+		n, err := strconv.Atoi(c)
+		if err != nil {
+			return lenientVersion{}, false
+		}
+		parsed.release = append(parsed.release, n)
+	}
+	if letters, digits := m[2], m[3]; letters != "" {
+		if _, keyword := prereleaseRanks[letters]; keyword && (digits != "" || len(letters) > 1) {
+			parsed.prerelease = []string{letters}
+			if digits != "" {
+				parsed.prerelease = append(parsed.prerelease, digits)
+			}
+		} else {
+			parsed.suffix = letters + digits
+		}
+	}
+
+	for _, t := range tokens[release+1:] {
+		if t.tagged || (t.game && candidates > 1) {
+			continue
+		}
+		parsed.prerelease = append(parsed.prerelease, identifierPattern.FindAllString(t.text, -1)...)
+	}
+	return parsed, true
+}
+
+// isGameVersionRange tells whether the untagged game versions in tokens are in
+// rising order.
+func isGameVersionRange(tokens []lenientToken) bool {
+	var previous []int
+	for _, t := range tokens {
+		if !t.game || t.tagged {
+			continue
+		}
+		var current []int
+		for _, c := range strings.Split(t.text, ".") {
+			n, _ := strconv.Atoi(c)
+			current = append(current, n)
+		}
+		if previous != nil && compareComponents(previous, current) > 0 {
+			return false
+		}
+		previous = current
+	}
+	return true
+}
+
+// compareLenientVersions gives false if either version has no version number.
+func compareLenientVersions(v1, v2 string) (c int, ok bool) {
+	p1, ok1 := parseLenientVersion(v1)
+	p2, ok2 := parseLenientVersion(v2)
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+
+	if c := compareComponents(p1.release, p2.release); c != 0 {
+		return c, true
+	}
+	if c := strings.Compare(p1.suffix, p2.suffix); c != 0 {
+		return c, true
+	}
+
+	// A release is newer than its pre-releases
+	switch {
+	case p1.prerelease == nil && p2.prerelease == nil:
+		return 0, true
+	case p1.prerelease == nil:
+		return 1, true
+	case p2.prerelease == nil:
+		return -1, true
+	}
+	for i := 0; i < min(len(p1.prerelease), len(p2.prerelease)); i++ {
+		if c := compareIdentifiers(p1.prerelease[i], p2.prerelease[i]); c != 0 {
+			return c, true
+		}
+	}
+	return compareInts(len(p1.prerelease), len(p2.prerelease)), true
+}
+
+func compareComponents(c1, c2 []int) int {
+	for i := 0; i < max(len(c1), len(c2)); i++ {
+		if c := compareInts(componentAt(c1, i), componentAt(c2, i)); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func componentAt(components []int, i int) int {
+	if i < len(components) {
+		return components[i]
+	}
+	return 0
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareIdentifiers orders pre-release identifiers like semver does, except
+// that the known keywords are ranked. Numbers come before words.
+func compareIdentifiers(a, b string) int {
+	n1, err1 := strconv.Atoi(a)
+	n2, err2 := strconv.Atoi(b)
+	switch {
+	case err1 == nil && err2 == nil:
+		return compareInts(n1, n2)
+	case err1 == nil:
+		return -1
+	case err2 == nil:
+		return 1
+	}
+	r1, known1 := prereleaseRanks[a]
+	r2, known2 := prereleaseRanks[b]
+	switch {
+	case known1 && known2:
+		return compareInts(r1, r2)
+	case known1:
+		return -1
+	case known2:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
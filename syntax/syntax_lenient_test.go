@@ -0,0 +1,227 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syntax
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"lucy/lucytypes"
+)
+
+// formatLenient writes a parsed version as "1.2.3a-beta.2", or an empty string
+// if there is no version number.
+func formatLenient(p lenientVersion, ok bool) string {
+	if !ok {
+		return ""
+	}
+	release := make([]string, 0, len(p.release))
+	for _, n := range p.release {
+		release = append(release, strconv.Itoa(n))
+	}
+	s := strings.Join(release, ".") + p.suffix
+	if p.prerelease != nil {
+		s += "-" + strings.Join(p.prerelease, ".")
+	}
+	return s
+}
+
+// The version strings below are taken from Modrinth, CurseForge and Hangar.
+
+func TestParseLenientVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    string
+	}{
+		// Plain versions
+		{"7.2.2", "7.2.2"},
+		{"22.4", "22.4"},
+		{"v1.2.3", "1.2.3"},
+		{"15.2.0.27", "15.2.0.27"},
+		{"2024.3.1", "2024.3.1"},
+		// Game version tagged with "mc"
+		{"mc1.20.1-0.5.8", "0.5.8"},
+		{"mc1.21-0.12.7", "0.12.7"},
+		{"mc1.21.1-0.6.0-beta.2-fabric", "0.6.0-beta.2"},
+		{"fabric-1.6.2-mc1.20", "1.6.2"},
+		{"1.3.5-mc1.20.1", "1.3.5"},
+		// Game version as build metadata
+		{"0.92.2+1.20.1", "0.92.2"},
+		{"0.5.8+mc1.20.4", "0.5.8"},
+		{"1.7.0+1.20.1", "1.7.0"},
+		{"3.5.0+1.21-fabric", "3.5.0"},
+		{"8.0.0-alpha.14+1.20.4", "8.0.0-alpha.14"},
+		{"2.21.0-dev+93-3a6fdd9", "2.21.0-dev"},
+		// Untagged game version before the version
+		{"1.20.1-0.4.3", "0.4.3"},
+		{"1.20.1-forge-47.2.0", "47.2.0"},
+		{"jei-1.20.1-fabric-15.3.0.4", "15.3.0.4"},
+		{"create-1.20.1-0.5.1.f", "0.5.1f"},
+		{"fabric-carpet-1.20-1.4.112", "1.4.112"},
+		// Untagged game version after the version
+		{"2.1.0-1.20.1", "2.1.0"},
+		{"24.2.0_Fabric_1.20", "24.2.0"},
+		{"Xaeros_Minimap_24.2.0_Fabric_1.20", "24.2.0"},
+		// Loader names
+		{"2024.3.1-fabric", "2024.3.1"},
+		{"11.1.106+fabric", "11.1.106"},
+		{"6.0.1-neoforge", "6.0.1"},
+		// Pre-releases and snapshots
+		{"11.0.0-beta.1", "11.0.0-beta.1"},
+		{"5.0.4-SNAPSHOT", "5.0.4-snapshot"},
+		{"2.11.3-SNAPSHOT-863", "2.11.3-snapshot.863"},
+		{"1.0.0-pre2", "1.0.0-pre.2"},
+		{"1.0.0-rc.1", "1.0.0-rc.1"},
+		{"21.0.0-beta", "21.0.0-beta"},
+		{"1.2.0-24w10a", "1.2.0"},
+		{"0.4.0b3", "0.4.0-b.3"},
+		// A letter after the version is a fix
+		{"1.2.3a", "1.2.3a"},
+		// A game version alone is the version
+		{"1.20.1", "1.20.1"},
+		// The game version comes first
+		{"1.20.1-1.9", "1.9"},
+		// Ranges of game versions have no version of the package
+		{"1.8-1.20.1", ""},
+		{"1.16.5-1.21", ""},
+		{"1.20-1.20.4", ""},
+		// No version at all
+		{"", ""},
+		{"latest", ""},
+		{"fabric", ""},
+		{"mc1.20.1", ""},
+	}
+	for _, tt := range tests {
+		if got := formatLenient(parseLenientVersion(tt.version)); got != tt.want {
+			t.Errorf("parseLenientVersion(%q) = %q, want %q", tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestCompareLenientVersions(t *testing.T) {
+	tests := []struct {
+		v1, v2 string
+		want   int
+	}{
+		// Sodium and Lithium on Modrinth
+		{"mc1.20.1-0.5.8", "mc1.20.1-0.5.3", 1},
+		{"mc1.20.4-0.5.8", "mc1.20.1-0.5.8", 0},
+		{"mc1.21.1-0.6.0-beta.2-fabric", "mc1.21.1-0.6.0-fabric", -1},
+		{"mc1.21.1-0.6.0-beta.2-fabric", "mc1.21.1-0.6.0-beta.10-fabric", -1},
+		{"mc1.21-0.12.7", "mc1.20.1-0.11.2", 1},
+		// Fabric API, Iris and YACL on Modrinth
+		{"0.92.2+1.20.1", "0.92.1+1.20.1", 1},
+		{"0.100.1+1.21", "0.92.2+1.20.1", 1},
+		{"0.5.8+mc1.20.4", "0.5.8+mc1.20.1", 0},
+		{"1.7.0+1.20.1", "1.6.4+1.20", 1},
+		{"3.5.0+1.21-fabric", "3.2.1+1.20.1-fabric", 1},
+		// JEI and Create on CurseForge
+		{"jei-1.20.1-forge-15.2.0.27", "jei-1.20.1-forge-15.2.0.9", 1},
+		{"jei-1.20.1-fabric-15.3.0.4", "jei-1.20.1-fabric-15.2.0.27", 1},
+		{"create-1.20.1-0.5.1.f", "create-1.20.1-0.5.1.e", 1},
+		{"create-1.20.1-0.5.1.f", "create-1.20.1-0.5.1", 1},
+		{"1.20.1-0.4.3", "1.20.4-0.4.2", 1},
+		{"mc1.20-2.1.0", "mc1.19.4-2.1.0", 0},
+		{"Xaeros_Minimap_24.2.0_Fabric_1.20", "Xaeros_Minimap_24.1.1_Fabric_1.20", 1},
+		{"2024.3.1-fabric", "2024.2.10-forge", 1},
+		{"11.1.118+forge", "11.1.106+fabric", 1},
+		// NeoForge and Forge
+		{"21.1.65", "21.0.167", 1},
+		{"21.0.0-beta", "21.0.0", -1},
+		{"1.20.1-forge-47.2.0", "1.20.1-forge-47.1.3", 1},
+		// ViaVersion, Geyser, EssentialsX and FAWE on Hangar
+		{"5.0.4-SNAPSHOT", "5.0.3", 1},
+		{"5.0.4-SNAPSHOT", "5.0.4", -1},
+		{"2.21.0-dev+93-3a6fdd9", "2.21.0-dev+101-c2b5fb4", 0},
+		{"2.21.0-dev+93-3a6fdd9", "2.20.1", 1},
+		{"2.11.3-SNAPSHOT-863", "2.11.3-SNAPSHOT-870", -1},
+		{"22.4", "21.3", 1},
+		// Pre-releases
+		{"1.0.0-alpha.1", "1.0.0-beta", -1},
+		{"1.0.0-beta", "1.0.0-pre2", -1},
+		{"1.0.0-pre2", "1.0.0-rc.1", -1},
+		{"1.0.0-rc.1", "1.0.0", -1},
+		{"1.0.0-snapshot", "1.0.0-alpha", -1},
+		{"1.0.0-beta.2", "1.0.0-beta.11", -1},
+		{"0.4.0b3", "0.4.0", -1},
+		// Components
+		{"1.2", "1.2.0", 0},
+		{"1.10", "1.9", 1},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.2.3a", "1.2.3", 1},
+		{"1.2.3b", "1.2.3a", 1},
+	}
+	for _, tt := range tests {
+		c, ok := compareLenientVersions(tt.v1, tt.v2)
+		if !ok {
+			t.Errorf("compareLenientVersions(%q, %q) is not ok", tt.v1, tt.v2)
+			continue
+		}
+		if c != tt.want {
+			t.Errorf("compareLenientVersions(%q, %q) = %d, want %d", tt.v1, tt.v2, c, tt.want)
+		}
+		if c, _ := compareLenientVersions(tt.v2, tt.v1); c != -tt.want {
+			t.Errorf("compareLenientVersions(%q, %q) = %d, want %d", tt.v2, tt.v1, c, -tt.want)
+		}
+	}
+
+	for _, pair := range [][2]string{
+		{"1.8-1.20.1", "1.8-1.21"},
+		{"latest", "1.0"},
+		{"fabric", "forge"},
+	} {
+		if _, ok := compareLenientVersions(pair[0], pair[1]); ok {
+			t.Errorf("compareLenientVersions(%q, %q) is ok", pair[0], pair[1])
+		}
+	}
+}
+
+func TestComparePackagesFallsBackToPublished(t *testing.T) {
+	day := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	plugin := func(version lucytypes.PackageVersion, published time.Time) *lucytypes.Package {
+		return &lucytypes.Package{
+			Id:     lucytypes.PackageId{Platform: lucytypes.Paper, Name: "viaversion", Version: version},
+			Remote: &lucytypes.PackageRemote{Published: published},
+		}
+	}
+
+	// A range of game versions cannot be compared
+	_, err := ComparePackageVersions(&plugin("1.8-1.20.1", day).Id, &plugin("1.8-1.21", day).Id)
+	if !errors.Is(err, EIncomparableVersions) {
+		t.Errorf("error = %v, want %v", err, EIncomparableVersions)
+	}
+
+	c, err := ComparePackages(plugin("1.8-1.21", day.AddDate(0, 0, 1)), plugin("1.8-1.20.1", day))
+	if err != nil || c != 1 {
+		t.Errorf("ComparePackages = %d, %v, want 1", c, err)
+	}
+
+	// The date is not used when the versions can be compared
+	c, err = ComparePackages(plugin("5.0.3", day.AddDate(0, 0, 1)), plugin("5.0.4", day))
+	if err != nil || c != -1 {
+		t.Errorf("ComparePackages = %d, %v, want -1", c, err)
+	}
+
+	// Without a date, the error is kept
+	_, err = ComparePackages(plugin("latest", time.Time{}), plugin("1.0", day))
+	if !errors.Is(err, EIncomparableVersions) {
+		t.Errorf("error = %v, want %v", err, EIncomparableVersions)
+	}
+}
@@ -0,0 +1,505 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syntax
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"lucy/datatypes"
+	"lucy/logger"
+	"lucy/lucyerrors"
+	"lucy/lucytypes"
+	"lucy/tools"
+	"lucy/util"
+)
+
+// VersionManifestURL is a variable so it can be pointed at a stand-in server.
+var VersionManifestURL = "https://piston-meta.mojang.com/mc/game/version_manifest_v2.json"
+
+const (
+	versionManifestCacheFile = util.CachePath + "/minecraft_version_manifest.json"
+	versionManifestTtl       = 6 * time.Hour
+	versionManifestTimeout   = 10 * time.Second
+)
+
+// embeddedVersionManifest is a trimmed copy of the version manifest with only
+// the releases in it, used when the manifest cannot be fetched and was never
+// cached. Snapshots are left out to keep the binary small, they are ordered by
+// parseMinecraftVersion and weeklySnapshotSeries instead. To refresh it:
+//
+//	curl -s https://piston-meta.mojang.com/mc/game/version_manifest_v2.json |
+//	  jq '{latest, versions: [.versions[] | select(.type == "release") | {id, type}]}'
+//
+//go:embed minecraft_versions.json
+var embeddedVersionManifest []byte
+
+// versionManifestCache keeps the validators of the response, so an outdated
+// cache can be refreshed with a conditional request.
+type versionManifestCache struct {
+	ETag         string                     `json:"etag,omitempty"`
+	LastModified string                     `json:"last_modified,omitempty"`
+	Manifest     *datatypes.VersionManifest `json:"manifest"`
+}
+
+// getVersionManifest is loaded once per run. It never fails, as the embedded
+// manifest is used as the last resort. It is a variable so tests can avoid the
+// network.
+var getVersionManifest = tools.Memoize(loadVersionManifest)
+
+// localVersionManifest is the cached manifest regardless of its age, or the
+// embedded one. It is for the lookups that should not wait for the network.
+var localVersionManifest = tools.Memoize(
+	func() *datatypes.VersionManifest {
+		if cache, _, err := readVersionManifestCache(); err == nil {
+			return cache.Manifest
+		}
+		return embeddedManifest()
+	},
+)
+
+// loadVersionManifest uses the cached manifest within its ttl. After that, it
+// is refreshed with a conditional request, and if that fails, the outdated
+// cache is used anyway.
+func loadVersionManifest() *datatypes.VersionManifest {
+	cache, modTime, cacheErr := readVersionManifestCache()
+	if cacheErr == nil && time.Since(modTime) < versionManifestTtl {
+		return cache.Manifest
+	}
+
+	manifest, fetchErr := fetchVersionManifest(cache)
+	if fetchErr == nil {
+		return manifest
+	}
+	if cacheErr == nil {
+		logger.Warning(
+			fmt.Errorf("cannot refresh the minecraft version manifest, using an outdated cache: %w", fetchErr),
+		)
+		return cache.Manifest
+	}
+	logger.Warning(
+		fmt.Errorf("cannot fetch the minecraft version manifest, using the bundled one: %w", fetchErr),
+	)
+	return embeddedManifest()
+}
+
+func embeddedManifest() *datatypes.VersionManifest {
+	manifest := &datatypes.VersionManifest{}
+	if err := json.Unmarshal(embeddedVersionManifest, manifest); err != nil {
+		logger.Warning(err)
+	}
+	return manifest
+}
+
+// fetchVersionManifest sends the validators of cache, if there is one. A cache
+// that is not modified is touched and returned as is.
+func fetchVersionManifest(cache *versionManifestCache) (
+	manifest *datatypes.VersionManifest,
+	err error,
+) {
+	req, err := http.NewRequest(http.MethodGet, VersionManifestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cache != nil {
+		if cache.ETag != "" {
+			req.Header.Set("If-None-Match", cache.ETag)
+		}
+		if cache.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cache.LastModified)
+		}
+	}
+
+	client := &http.Client{Timeout: versionManifestTimeout}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer tools.CloseReader(res.Body, logger.Warning)
+
+	if res.StatusCode == http.StatusNotModified && cache != nil {
+		now := time.Now()
+		_ = os.Chtimes(versionManifestCacheFile, now, now)
+		return cache.Manifest, nil
+	}
+	if err := lucyerrors.CheckResponse(res); err != nil {
+		return nil, err
+	}
+
+	manifest = &datatypes.VersionManifest{}
+	if err := json.NewDecoder(res.Body).Decode(manifest); err != nil {
+		return nil, err
+	}
+	writeVersionManifestCache(
+		&versionManifestCache{
+			ETag:         res.Header.Get("ETag"),
+			LastModified: res.Header.Get("Last-Modified"),
+			Manifest:     manifest,
+		},
+	)
+	return manifest, nil
+}
+
+func readVersionManifestCache() (
+	cache *versionManifestCache,
+	modTime time.Time,
+	err error,
+) {
+	stat, err := os.Stat(versionManifestCacheFile)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	data, err := os.ReadFile(versionManifestCacheFile)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	cache = &versionManifestCache{}
+	if err := json.Unmarshal(data, cache); err != nil {
+		return nil, time.Time{}, err
+	}
+	if cache.Manifest == nil {
+		return nil, time.Time{}, fmt.Errorf("no manifest in %s", versionManifestCacheFile)
+	}
+	return cache, stat.ModTime(), nil
+}
+
+// writeVersionManifestCache does not create the program directory, a server
+// without Lucy installed simply fetches the manifest every time.
+func writeVersionManifestCache(cache *versionManifestCache) {
+	if _, err := os.Stat(util.ProgramPath); err != nil {
+		return
+	}
+	data, err := json.Marshal(cache)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	if err := os.MkdirAll(path.Dir(versionManifestCacheFile), 0o755); err != nil {
+		logger.Warning(err)
+		return
+	}
+	if err := os.WriteFile(versionManifestCacheFile, data, 0o644); err != nil {
+		logger.Warning(err)
+	}
+}
+
+// MinecraftVersionType is the kind of a Minecraft version. The version manifest
+// calls everything that is not a release a snapshot, the kinds are told apart
+// here by their ids.
+type MinecraftVersionType uint8
+
+const (
+	UnknownMinecraftVersion MinecraftVersionType = iota
+	MinecraftSnapshot
+	MinecraftPreRelease
+	MinecraftReleaseCandidate
+	MinecraftRelease
+	MinecraftOldBeta
+	MinecraftOldAlpha
+)
+
+func (t MinecraftVersionType) String() string {
+	switch t {
+	case MinecraftSnapshot:
+		return "snapshot"
+	case MinecraftPreRelease:
+		return "pre-release"
+	case MinecraftReleaseCandidate:
+		return "release candidate"
+	case MinecraftRelease:
+		return "release"
+	case MinecraftOldBeta:
+		return "old beta"
+	case MinecraftOldAlpha:
+		return "old alpha"
+	}
+	return "unknown"
+}
+
+// GetMinecraftVersionType tells the kind of v by its id, or by the cached
+// version manifest for ids that do not follow any known scheme. It does not
+// fetch the manifest.
+func GetMinecraftVersionType(v lucytypes.PackageVersion) MinecraftVersionType {
+	if parsed, ok := parseMinecraftVersion(v); ok {
+		return parsed.kind
+	}
+	for _, version := range localVersionManifest().Versions {
+		if version.Id != string(v) {
+			continue
+		}
+		switch version.Type {
+		case "release":
+			return MinecraftRelease
+		case "snapshot":
+			return MinecraftSnapshot
+		case "old_beta":
+			return MinecraftOldBeta
+		case "old_alpha":
+			return MinecraftOldAlpha
+		}
+	}
+	return UnknownMinecraftVersion
+}
+
+// IsMinecraftSnapshot tells whether v is a development version, which is a
+// snapshot, a pre-release or a release candidate.
+func IsMinecraftSnapshot(v lucytypes.PackageVersion) bool {
+	switch GetMinecraftVersionType(v) {
+	case MinecraftSnapshot, MinecraftPreRelease, MinecraftReleaseCandidate:
+		return true
+	}
+	return false
+}
+
+// minecraftVersion is a Minecraft version id broken into its parts.
+type minecraftVersion struct {
+	kind MinecraftVersionType
+	// release is the release the version belongs to, like [1 21 4] or, in the
+	// year-based scheme, [26 1]. It is nil for weekly snapshots.
+	release []int
+	// n is the number of a pre-release, release candidate or year-based
+	// snapshot, or the number after the underscore of an old alpha or beta,
+	// like the 01 in b1.5_01.
+	n int
+	// year, week and letter are of a weekly snapshot, like 24w10a. An old
+	// alpha or beta can also end with a letter, like a1.2.2b.
+	year, week int
+	letter     byte
+}
+
+// firstYearBasedYear is the year Mojang switched from 1.x versions and weekly
+// snapshots to versions like 26.1 and 26.1-snapshot-1.
+const firstYearBasedYear = 26
+
+var (
+	minecraftReleasePattern = regexp.MustCompile(`^\d+\.\d+(?:\.\d+)?$`)
+	minecraftPreviewPattern = regexp.MustCompile(
+		`^(\d+\.\d+(?:\.\d+)?)\s*-?\s*(pre-release|pre|rc|snapshot)\s*-?\s*(\d+)$`,
+	)
+	minecraftWeeklyPattern = regexp.MustCompile(`^(\d{2})w(\d{2})([a-z])$`)
+	minecraftOldPattern    = regexp.MustCompile(`^([ab])(\d+\.\d+(?:\.\d+)?)(?:_(\d+))?([a-z])?$`)
+)
+
+// parseMinecraftVersion understands the following ids, and gives false for any
+// other one, like the april fools versions:
+//
+//   - 1.21.4, 26.1        releases
+//   - 24w10a              weekly snapshots
+//   - 26.1-snapshot-1     snapshots in the year-based scheme
+//   - 1.21-pre1, 26.1-pre-1, 1.14 Pre-Release 1
+//   - 1.21-rc1, 26.1-rc-1
+//   - b1.7.3, b1.5_01, a1.2.2b  old betas and alphas
+//
+// Classic and earlier ids, like c0.30_01c and rd-132211, are left to the
+// version manifest.
+func parseMinecraftVersion(v lucytypes.PackageVersion) (parsed minecraftVersion, ok bool) {
+	s := strings.ToLower(strings.TrimSpace(string(v)))
+
+	if m := minecraftWeeklyPattern.FindStringSubmatch(s); m != nil {
+		parsed.kind = MinecraftSnapshot
+		parsed.year, _ = strconv.Atoi(m[1])
+		parsed.week, _ = strconv.Atoi(m[2])
+		parsed.letter = m[3][0]
+		return parsed, true
+	}
+
+	release := s
+	parsed.kind = MinecraftRelease
+	if m := minecraftOldPattern.FindStringSubmatch(s); m != nil {
+		release = m[2]
+		parsed.kind = tools.Ternary(m[1] == "a", MinecraftOldAlpha, MinecraftOldBeta)
+		parsed.n, _ = strconv.Atoi(m[3])
+		if m[4] != "" {
+			parsed.letter = m[4][0]
+		}
+	} else if m := minecraftPreviewPattern.FindStringSubmatch(s); m != nil {
+		release = m[1]
+		parsed.n, _ = strconv.Atoi(m[3])
+		switch m[2] {
+		case "snapshot":
+			parsed.kind = MinecraftSnapshot
+		case "rc":
+			parsed.kind = MinecraftReleaseCandidate
+		default:
+			parsed.kind = MinecraftPreRelease
+		}
+	} else if !minecraftReleasePattern.MatchString(s) {
+		return minecraftVersion{}, false
+	}
+
+	for _, c := range strings.Split(release, ".") {
+		n, err := strconv.Atoi(c)
+		if err != nil {
+			return minecraftVersion{}, false
+		}
+		parsed.release = append(parsed.release, n)
+	}
+	return parsed, true
+}
+
+// compareMinecraftVersions orders the ids by their parts if it can, which does
+// not need the version manifest. Nothing in the id of a weekly snapshot relates
+// it to a 1.x release, so from 1.14 on that is looked up in
+// weeklySnapshotSeries, and before that it is left to the manifest.
+func compareMinecraftVersions(v1, v2 lucytypes.PackageVersion) (
+	c int8,
+	err error,
+) {
+	p1, ok1 := parseMinecraftVersion(v1)
+	p2, ok2 := parseMinecraftVersion(v2)
+	if ok1 && ok2 {
+		if c, ok := compareParsedMinecraftVersions(p1, p2); ok {
+			return int8(c), nil
+		}
+	}
+
+	i1, i2 := -1, -1
+	for i, v := range getVersionManifest().Versions {
+		if v1 == lucytypes.PackageVersion(v.Id) {
+			i1 = i
+		}
+		if v2 == lucytypes.PackageVersion(v.Id) {
+			i2 = i
+		}
+	}
+	if i1 == -1 || i2 == -1 {
+		return 0, fmt.Errorf("%w: %s and %s", EIncomparableVersions, v1, v2)
+	}
+	// The manifest lists the newest version first
+	return int8(compareInts(i2, i1)), nil
+}
+
+func compareParsedMinecraftVersions(p1, p2 minecraftVersion) (c int, ok bool) {
+	switch {
+	case p1.release == nil && p2.release == nil:
+		if c := compareInts(p1.year, p2.year); c != 0 {
+			return c, true
+		}
+		if c := compareInts(p1.week, p2.week); c != 0 {
+			return c, true
+		}
+		return compareInts(int(p1.letter), int(p2.letter)), true
+	case p1.release == nil:
+		c, ok := compareWeeklySnapshot(p2, p1)
+		return -c, ok
+	case p2.release == nil:
+		return compareWeeklySnapshot(p1, p2)
+	}
+
+	// Alphas come before betas, which come before everything else
+	if c := compareInts(minecraftEra(p1.kind), minecraftEra(p2.kind)); c != 0 {
+		return c, true
+	}
+	if c := compareComponents(p1.release, p2.release); c != 0 {
+		return c, true
+	}
+	// Snapshots, pre-releases and release candidates come before the release
+	if c := compareInts(int(p1.kind), int(p2.kind)); c != 0 {
+		return c, true
+	}
+	if c := compareInts(p1.n, p2.n); c != 0 {
+		return c, true
+	}
+	return compareInts(int(p1.letter), int(p2.letter)), true
+}
+
+// compareWeeklySnapshot compares p against the weekly snapshot. Weekly
+// snapshots came after the old alphas and betas, and before the year-based
+// scheme. They come before the pre-releases of the release they lead to.
+func compareWeeklySnapshot(p, snapshot minecraftVersion) (c int, ok bool) {
+	switch {
+	case p.release[0] >= firstYearBasedYear:
+		return 1, true
+	case minecraftEra(p.kind) < minecraftEra(MinecraftRelease):
+		return -1, true
+	}
+	release := weeklySnapshotRelease(snapshot)
+	if release == nil {
+		// The snapshot is older than the series of 1.14
+		if compareComponents(p.release, weeklySnapshotSeries[0].release) >= 0 {
+			return 1, true
+		}
+		return 0, false
+	}
+	if c := compareComponents(p.release, release); c != 0 {
+		return c, true
+	}
+	return 1, true
+}
+
+// weeklySnapshotSeries has the first weekly snapshot of each series from 1.14
+// on, with the release the series led to. Releases without weekly snapshots,
+// like 1.21.10, are left out. Weekly snapshots ended with 1.21.11, so this list
+// is complete.
+var weeklySnapshotSeries = []struct {
+	year, week int
+	release    []int
+}{
+	{18, 43, []int{1, 14}},
+	{19, 34, []int{1, 15}},
+	{20, 6, []int{1, 16}},
+	{20, 27, []int{1, 16, 2}},
+	{20, 45, []int{1, 17}},
+	{21, 37, []int{1, 18}},
+	{22, 3, []int{1, 18, 2}},
+	{22, 11, []int{1, 19}},
+	{22, 24, []int{1, 19, 1}},
+	{22, 42, []int{1, 19, 3}},
+	{23, 3, []int{1, 19, 4}},
+	{23, 12, []int{1, 20}},
+	{23, 31, []int{1, 20, 2}},
+	{23, 40, []int{1, 20, 3}},
+	{23, 51, []int{1, 20, 5}},
+	{24, 18, []int{1, 21}},
+	{24, 33, []int{1, 21, 2}},
+	{24, 44, []int{1, 21, 4}},
+	{25, 2, []int{1, 21, 5}},
+	{25, 15, []int{1, 21, 6}},
+	{25, 31, []int{1, 21, 9}},
+	{25, 41, []int{1, 21, 11}},
+}
+
+// weeklySnapshotRelease gives the release the weekly snapshot led to, or nil if
+// it is older than weeklySnapshotSeries.
+func weeklySnapshotRelease(snapshot minecraftVersion) (release []int) {
+	for _, series := range weeklySnapshotSeries {
+		if snapshot.year < series.year ||
+			snapshot.year == series.year && snapshot.week < series.week {
+			break
+		}
+		release = series.release
+	}
+	return release
+}
+
+func minecraftEra(kind MinecraftVersionType) int {
+	switch kind {
+	case MinecraftOldAlpha:
+		return 0
+	case MinecraftOldBeta:
+		return 1
+	}
+	return 2
+}
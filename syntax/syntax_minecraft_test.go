@@ -0,0 +1,305 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syntax
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"lucy/datatypes"
+	"lucy/lucytypes"
+	"lucy/testutil"
+	"lucy/util"
+)
+
+// manifestStandIn serves a version manifest with the ETag "v1", and answers a
+// request that sends it back with 304. Every request fails with status if it is
+// set.
+type manifestStandIn struct {
+	*httptest.Server
+	requests    int
+	conditional int
+	status      int
+}
+
+// testManifest has snapshots, unlike the embedded manifest.
+const testManifest = `{
+	"latest": {"release": "1.20.4", "snapshot": "24w10a"},
+	"versions": [
+		{"id": "24w10a", "type": "snapshot"},
+		{"id": "1.20.4", "type": "release"},
+		{"id": "1.20.3-rc1", "type": "snapshot"},
+		{"id": "23w45a", "type": "snapshot"},
+		{"id": "1.20.2", "type": "release"},
+		{"id": "b1.7.3", "type": "old_beta"},
+		{"id": "c0.30_01c", "type": "old_alpha"},
+		{"id": "rd-132211", "type": "old_alpha"}
+	]
+}`
+
+func newManifestStandIn(t *testing.T) *manifestStandIn {
+	t.Helper()
+	s := &manifestStandIn{}
+	s.Server = testutil.StandIn(t,
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s.requests++
+			if s.status != 0 {
+				w.WriteHeader(s.status)
+				return
+			}
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				s.conditional++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			_, _ = w.Write([]byte(testManifest))
+		}),
+		&VersionManifestURL,
+	)
+	return s
+}
+
+// useManifest makes the comparisons use manifest instead of fetching one.
+func useManifest(t *testing.T, manifest func() *datatypes.VersionManifest) {
+	t.Helper()
+	get := getVersionManifest
+	getVersionManifest = manifest
+	t.Cleanup(func() { getVersionManifest = get })
+}
+
+func parseTestManifest(t *testing.T) *datatypes.VersionManifest {
+	t.Helper()
+	manifest := &datatypes.VersionManifest{}
+	if err := json.Unmarshal([]byte(testManifest), manifest); err != nil {
+		t.Fatal(err)
+	}
+	return manifest
+}
+
+func TestLoadVersionManifest(t *testing.T) {
+	s := newManifestStandIn(t)
+	testutil.ServerDir(t)
+	if err := util.InstallLucy(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing is cached
+	if manifest := loadVersionManifest(); manifest.Latest.Snapshot != "24w10a" {
+		t.Fatalf("latest snapshot = %q, want 24w10a", manifest.Latest.Snapshot)
+	}
+	cache, _, err := readVersionManifestCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cache.ETag != `"v1"` || len(cache.Manifest.Versions) != 8 {
+		t.Errorf("cache = %+v", cache)
+	}
+
+	// Within the ttl, the cache is used without asking
+	loadVersionManifest()
+	if s.requests != 1 {
+		t.Errorf("%d requests within the ttl, want 1", s.requests)
+	}
+
+	// After the ttl, the cache is validated and touched
+	outdated := time.Now().Add(-versionManifestTtl - time.Minute)
+	if err := os.Chtimes(versionManifestCacheFile, outdated, outdated); err != nil {
+		t.Fatal(err)
+	}
+	if manifest := loadVersionManifest(); manifest.Latest.Snapshot != "24w10a" {
+		t.Errorf("latest snapshot = %q after 304, want 24w10a", manifest.Latest.Snapshot)
+	}
+	if s.requests != 2 || s.conditional != 1 {
+		t.Errorf("%d requests, %d conditional, want 2 and 1", s.requests, s.conditional)
+	}
+	if _, modTime, _ := readVersionManifestCache(); time.Since(modTime) > versionManifestTtl {
+		t.Error("cache is not touched after 304")
+	}
+
+	// An outdated cache is used when the manifest cannot be fetched
+	if err := os.Chtimes(versionManifestCacheFile, outdated, outdated); err != nil {
+		t.Fatal(err)
+	}
+	s.status = http.StatusInternalServerError
+	if manifest := loadVersionManifest(); manifest.Latest.Snapshot != "24w10a" {
+		t.Errorf("latest snapshot = %q from outdated cache, want 24w10a", manifest.Latest.Snapshot)
+	}
+
+	// Without a cache, the embedded manifest is the last resort
+	if err := os.Remove(versionManifestCacheFile); err != nil {
+		t.Fatal(err)
+	}
+	manifest := loadVersionManifest()
+	if len(manifest.Versions) == 0 || manifest.Latest.Release != manifest.Versions[0].Id {
+		t.Errorf("embedded manifest: latest %q, %d versions", manifest.Latest.Release, len(manifest.Versions))
+	}
+	for _, v := range manifest.Versions {
+		if v.Type != "release" {
+			t.Errorf("embedded manifest has %s %s", v.Type, v.Id)
+		}
+	}
+}
+
+func TestLoadVersionManifestWithoutLucy(t *testing.T) {
+	s := newManifestStandIn(t)
+	testutil.ServerDir(t)
+
+	loadVersionManifest()
+	loadVersionManifest()
+	if s.requests != 2 {
+		t.Errorf("%d requests, want 2", s.requests)
+	}
+	if _, err := os.Stat(versionManifestCacheFile); !os.IsNotExist(err) {
+		t.Errorf("cache written without lucy installed: %v", err)
+	}
+}
+
+func TestCompareMinecraftVersionsOffline(t *testing.T) {
+	useManifest(t, embeddedManifest)
+
+	tests := []struct {
+		v1, v2 lucytypes.PackageVersion
+		want   int8
+	}{
+		// Releases
+		{"1.21.4", "1.21", 1},
+		{"1.21", "1.21.0", 0},
+		{"1.20.10", "1.20.9", 1},
+		{"1.8.9", "1.21", -1},
+		// Pre-releases and release candidates
+		{"1.21-pre1", "1.21-rc1", -1},
+		{"1.21-pre1", "1.21-pre2", -1},
+		{"1.21-rc1", "1.21", -1},
+		{"1.20.6", "1.21-pre1", -1},
+		{"1.14 Pre-Release 1", "1.14-pre2", -1},
+		// Weekly snapshots
+		{"24w10a", "24w10b", -1},
+		{"24w10b", "24w11a", -1},
+		{"23w51b", "24w03a", -1},
+		// Weekly snapshots against the releases from 1.14 on
+		{"24w10a", "1.20.4", 1},
+		{"23w51a", "1.20.4", 1},
+		{"24w10a", "1.20.5-pre1", -1},
+		{"24w14a", "1.20.5", -1},
+		{"23w45a", "1.20.2", 1},
+		{"23w45a", "1.20.3", -1},
+		{"25w45a", "1.21.10", 1},
+		{"18w43a", "1.13.2", 1},
+		{"17w43a", "1.14", -1},
+		// The year-based scheme
+		{"26.1-snapshot-1", "26.1-snapshot-2", -1},
+		{"26.1-snapshot-2", "26.1-pre-1", -1},
+		{"26.1-pre-1", "26.1-rc-1", -1},
+		{"26.1-rc-1", "26.1", -1},
+		{"26.1", "1.21.11", 1},
+		{"25w45a", "26.1-snapshot-1", -1},
+		// Old alphas and betas
+		{"a1.2.6", "b1.0", -1},
+		{"b1.8.1", "1.0", -1},
+		{"a1.2.2a", "a1.2.2b", -1},
+		{"b1.3b", "b1.3_01", -1},
+		{"a1.2.3_02", "a1.2.3_04", -1},
+		{"b1.7.3", "b1.7.3", 0},
+		{"b1.8.1", "11w47a", -1},
+	}
+	for _, tt := range tests {
+		c, err := ComparePackageVersions(
+			&lucytypes.PackageId{Platform: lucytypes.Minecraft, Version: tt.v1},
+			&lucytypes.PackageId{Platform: lucytypes.Minecraft, Version: tt.v2},
+		)
+		if err != nil {
+			t.Errorf("compare %s and %s: %v", tt.v1, tt.v2, err)
+			continue
+		}
+		if c != tt.want {
+			t.Errorf("compare %s and %s = %d, want %d", tt.v1, tt.v2, c, tt.want)
+		}
+		if c, _ := compareMinecraftVersions(tt.v2, tt.v1); c != -tt.want {
+			t.Errorf("compare %s and %s = %d, want %d", tt.v2, tt.v1, c, -tt.want)
+		}
+	}
+
+	// Before 1.14, weekly snapshots are only related to releases by the manifest
+	for _, pair := range [][2]lucytypes.PackageVersion{
+		{"17w43a", "1.12.2"},
+		{"rd-132211", "1.0"},
+	} {
+		if _, err := compareMinecraftVersions(pair[0], pair[1]); !errors.Is(err, EIncomparableVersions) {
+			t.Errorf("compare %s and %s: error = %v, want %v", pair[0], pair[1], err, EIncomparableVersions)
+		}
+	}
+}
+
+func TestCompareMinecraftVersionsByManifest(t *testing.T) {
+	manifest := parseTestManifest(t)
+	useManifest(t, func() *datatypes.VersionManifest { return manifest })
+
+	tests := []struct {
+		v1, v2 lucytypes.PackageVersion
+		want   int8
+	}{
+		{"24w10a", "1.20.4", 1},
+		{"23w45a", "1.20.4", -1},
+		{"23w45a", "1.20.2", 1},
+		{"rd-132211", "c0.30_01c", -1},
+		{"c0.30_01c", "b1.7.3", -1},
+	}
+	for _, tt := range tests {
+		c, err := compareMinecraftVersions(tt.v1, tt.v2)
+		if err != nil {
+			t.Errorf("compare %s and %s: %v", tt.v1, tt.v2, err)
+			continue
+		}
+		if c != tt.want {
+			t.Errorf("compare %s and %s = %d, want %d", tt.v1, tt.v2, c, tt.want)
+		}
+	}
+}
+
+func TestGetMinecraftVersionType(t *testing.T) {
+	tests := []struct {
+		version  lucytypes.PackageVersion
+		want     MinecraftVersionType
+		snapshot bool
+	}{
+		{"1.21.4", MinecraftRelease, false},
+		{"26.1", MinecraftRelease, false},
+		{"24w10a", MinecraftSnapshot, true},
+		{"26.1-snapshot-1", MinecraftSnapshot, true},
+		{"1.21-pre1", MinecraftPreRelease, true},
+		{"1.14 Pre-Release 1", MinecraftPreRelease, true},
+		{"26.1-pre-1", MinecraftPreRelease, true},
+		{"1.21-rc1", MinecraftReleaseCandidate, true},
+		{"b1.7.3", MinecraftOldBeta, false},
+		{"a1.2.6", MinecraftOldAlpha, false},
+		{"3D Shareware v1.34", UnknownMinecraftVersion, false},
+	}
+	for _, tt := range tests {
+		if got := GetMinecraftVersionType(tt.version); got != tt.want {
+			t.Errorf("GetMinecraftVersionType(%q) = %s, want %s", tt.version, got, tt.want)
+		}
+		if got := IsMinecraftSnapshot(tt.version); got != tt.snapshot {
+			t.Errorf("IsMinecraftSnapshot(%q) = %v, want %v", tt.version, got, tt.snapshot)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syntax
+
+import (
+	"errors"
+	"testing"
+
+	"lucy/lucyerrors"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  error
+	}{
+		{"notaplatform/sodium", EPlatform},
+		{"notaplatform/sodium@0.5.8", EPlatform},
+		{"fabric/sodium/extra", ESyntax},
+		{"sodium@0.5@0.6", ESyntax},
+		{"sodium@none", ESyntax},
+		{"github:sodium", ESyntax},
+		{"github:owner/repo@", ESyntax},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.input)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("Parse(%q): error = %v, want %v", tt.input, err, tt.want)
+		}
+		if kind := lucyerrors.KindOf(err); kind != lucyerrors.PackageSyntaxError {
+			t.Errorf("Parse(%q): kind = %v, want a syntax error", tt.input, kind)
+		}
+	}
+}
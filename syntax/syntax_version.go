@@ -17,53 +17,26 @@ limitations under the License.
 package syntax
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"net/http"
 
-	"golang.org/x/mod/semver"
-
-	"lucy/datatypes"
+	"lucy/lucyerrors"
 	"lucy/lucytypes"
 )
 
 var (
 	EInvalidVersionComparison = errors.New("invalid version comparison")
-	EVersionNotFound          = errors.New("version do not exist")
+	EVersionNotFound          = lucyerrors.New(lucyerrors.NotFoundError, "version do not exist")
+	EIncomparableVersions     = errors.New("versions cannot be compared")
 )
 
-// TODO: Remove the err return value
-// TODO: Use tools.Memoize to cache the result
-
-const VersionManifestURL = "https://piston-meta.mojang.com/mc/game/version_manifest_v2.json"
-
-func getVersionManifest() (manifest *datatypes.VersionManifest, err error) {
-	manifest = &datatypes.VersionManifest{}
-
-	// TODO: Add cache mechanism if http call is too slow or fails
-	resp, err := http.Get(VersionManifestURL)
-	if err != nil {
-		return nil, err
-	}
-
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	err = json.Unmarshal(data, manifest)
-	if err != nil {
-		return nil, err
-	}
-
-	return manifest, nil
-}
-
 // ComparePackageVersions gives -1 when v1 is older than v2, 0 when they are
 // the same (or an error occurred), and 1 when v1 is newer than v2. 0 is returned
 // when either v1 or v2 is AllVersion
+//
+// Versions of mods and plugins are compared leniently, game version tags, loader
+// names and build metadata in them are ignored, see parseLenientVersion. An
+// error wrapping EIncomparableVersions is given if either has no version number.
 func ComparePackageVersions(p1, p2 *lucytypes.PackageId) (c int8, err error) {
 	v1, v2 := p1.Version, p2.Version
 
@@ -87,37 +60,22 @@ func ComparePackageVersions(p1, p2 *lucytypes.PackageId) (c int8, err error) {
 	if p1.Platform == lucytypes.Minecraft {
 		return compareMinecraftVersions(v1, v2)
 	}
-	return int8(semver.Compare("v"+string(v1), "v"+string(v2))), nil
-}
-
-func compareMinecraftVersions(v1, v2 lucytypes.PackageVersion) (
-	c int8,
-	err error,
-) {
-	manifest, err := getVersionManifest()
-	if err != nil {
-		return 0, err
-	}
-
-	i1, i2 := -1, -1
-	for i, v := range manifest.Versions {
-		if v1 == lucytypes.PackageVersion(v.Id) {
-			i1 = i
-		}
-		if v2 == lucytypes.PackageVersion(v.Id) {
-			i2 = i
-		}
+	lc, ok := compareLenientVersions(string(v1), string(v2))
+	if !ok {
+		return 0, fmt.Errorf("%w: %s and %s", EIncomparableVersions, v1, v2)
 	}
+	return int8(lc), nil
+}
 
-	if i1 == i2 {
-		return 0, nil
-	}
-	if i1 < i2 {
-		return 1, nil
+// ComparePackages is ComparePackageVersions, except that packages whose versions
+// cannot be compared are ordered by when they were published, if the remote
+// tells.
+func ComparePackages(p1, p2 *lucytypes.Package) (c int8, err error) {
+	c, err = ComparePackageVersions(&p1.Id, &p2.Id)
+	if !errors.Is(err, EIncomparableVersions) ||
+		p1.Remote == nil || p2.Remote == nil ||
+		p1.Remote.Published.IsZero() || p2.Remote.Published.IsZero() {
+		return c, err
 	}
-	if i1 > i2 {
-		return -1, nil
-	}
-
-	return 0, EInvalidVersionComparison
+	return int8(p1.Remote.Published.Compare(p2.Remote.Published)), nil
 }
@@ -0,0 +1,55 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package testutil is what the tests of lucy's packages share: a stand-in for
+// the APIs lucy talks to, and an empty server directory to run in.
+package testutil
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// StandIn serves handler for the rest of the test, and points the base url at
+// it until the test ends.
+func StandIn(t testing.TB, handler http.Handler, baseUrl *string) *httptest.Server {
+	t.Helper()
+	s := httptest.NewServer(handler)
+	t.Cleanup(s.Close)
+	if baseUrl != nil {
+		url := *baseUrl
+		*baseUrl = s.URL
+		t.Cleanup(func() { *baseUrl = url })
+	}
+	return s
+}
+
+// WriteJson writes v as the body of a JSON response.
+func WriteJson(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// ServerDir runs the rest of the test in an empty directory, as the paths of
+// lucy are relative to the server's directory.
+func ServerDir(t testing.TB) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Chdir(dir)
+	return dir
+}
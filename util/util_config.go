@@ -0,0 +1,53 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"encoding/json"
+	"os"
+
+	"lucy/lucyerrors"
+)
+
+// ReadConfig gives lucyerrors.NoLucyError if the config file does not exist.
+func ReadConfig() (config *LucyEnvConfig, err error) {
+	data, err := os.ReadFile(ConfigFile)
+	if os.IsNotExist(err) {
+		return nil, lucyerrors.NoLucyError
+	}
+	if err != nil {
+		return nil, err
+	}
+	config = &LucyEnvConfig{}
+	err = json.Unmarshal(data, config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func WriteConfig(config *LucyEnvConfig) error {
+	return writeJson(ConfigFile, config)
+}
+
+func writeJson(filename string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0o644)
+}
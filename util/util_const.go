@@ -21,4 +21,14 @@ const (
 	ConfigFile   = ProgramPath + "/config.json"
 	DownloadPath = ProgramPath + "/downloads"
 	CachePath    = ProgramPath + "/cache"
+	TrashPath    = ProgramPath + "/trash"
+
+	TransactionJournal = ProgramPath + "/transaction.json"
+)
+
+// ManifestFile and LockFile are placed in the server's root directory rather
+// than under ProgramPath, as they are meant to be edited and tracked in git.
+const (
+	ManifestFile = "lucy.toml"
+	LockFile     = "lucy.lock"
 )
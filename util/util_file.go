@@ -17,21 +17,79 @@ limitations under the License.
 package util
 
 import (
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
 	"io"
 	"os"
 	"path"
+	"strconv"
+	"time"
+
+	"lucy/tools"
 )
 
-func InstallLucy() {
-	os.Mkdir(ProgramPath, 0o755)
-	os.Mkdir(DownloadPath, 0o755)
-	os.Mkdir(CachePath, 0o755)
-	// 	TODO: create empty config
+// InstallLucy creates the .lucy directory and its subdirectories. The config
+// and manifest are not written here, see WriteConfig and WriteManifest.
+func InstallLucy() (err error) {
+	for _, dir := range []string{ProgramPath, DownloadPath, CachePath} {
+		err = os.MkdirAll(dir, 0o755)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MoveToTrash moves a file into .lucy/trash/{timestamp}/{filename} instead of
+// deleting it, so a removed package can be recovered by hand. If a file of the
+// same name was already trashed in that second, a number is appended to the
+// name, as in "{filename}.1", so neither is lost.
+func MoveToTrash(filePath string) (trashed string, err error) {
+	dir := path.Join(TrashPath, time.Now().Format("20060102-150405"))
+	err = os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return "", err
+	}
+	name := path.Base(filePath)
+	trashed = path.Join(dir, name)
+	for i := 1; fileExists(trashed); i++ {
+		trashed = path.Join(dir, name+"."+strconv.Itoa(i))
+	}
+	err = os.Rename(filePath, trashed)
+	if err != nil {
+		return "", err
+	}
+	return trashed, nil
+}
+
+func fileExists(filePath string) bool {
+	_, err := os.Lstat(filePath)
+	return err == nil
+}
+
+// FileSha512 gives the hex encoded sha512 digest of a file.
+func FileSha512(filePath string) (digest string, err error) {
+	return fileDigest(filePath, sha512.New())
+}
+
+// FileSha1 gives the hex encoded sha1 digest of a file. It is only meant for
+// looking files up in remote sources that index them by sha1.
+func FileSha1(filePath string) (digest string, err error) {
+	return fileDigest(filePath, sha1.New())
 }
 
-func MoveFile(src *os.File, dest string) (err error) {
-	err = os.Rename(src.Name(), dest)
-	return
+func fileDigest(filePath string, h hash.Hash) (digest string, err error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer tools.CloseReader(file, func(error) {})
+	if _, err = io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
 func CopyToCache(f *os.File) {
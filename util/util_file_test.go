@@ -0,0 +1,58 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"lucy/testutil"
+)
+
+func TestMoveToTrashKeepsFilesOfSameName(t *testing.T) {
+	testutil.ServerDir(t)
+	for _, dir := range []string{"mods", "plugins"} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path.Join(dir, "lib.jar"), []byte(dir), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first, err := MoveToTrash("mods/lib.jar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := MoveToTrash("plugins/lib.jar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Fatalf("both files trashed to %s", first)
+	}
+	for trashed, want := range map[string]string{first: "mods", second: "plugins"} {
+		data, err := os.ReadFile(trashed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("%s holds %q, want %q", trashed, data, want)
+		}
+	}
+}
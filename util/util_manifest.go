@@ -0,0 +1,256 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+
+	"lucy/lucytypes"
+)
+
+// LucyManifest is the human-edited lucy.toml. It only lists the packages that
+// are wanted, in the "platform/name@version" format, for example:
+//
+//	packages = [
+//	  "fabric/lithium",
+//	  "fabric/carpet@1.4.161",
+//	  "mcdr/prime-backup",
+//	]
+//
+// Exact files are pinned by LucyLock instead.
+type LucyManifest struct {
+	Packages []string `toml:"packages"`
+}
+
+// LucyLock is the generated lucy.lock. Entries are always sorted when written,
+// so the file stays stable across runs and is easy to diff.
+type LucyLock struct {
+	Version  int             `toml:"version"`
+	Packages []LockedPackage `toml:"package"`
+}
+
+const lockVersion = 1
+
+type LockedPackage struct {
+	Platform lucytypes.Platform       `toml:"platform"`
+	Name     lucytypes.PackageName    `toml:"name"`
+	Version  lucytypes.PackageVersion `toml:"version"`
+	Source   string                   `toml:"source"`
+	RemoteId string                   `toml:"remote_id,omitempty"`
+	Slug     lucytypes.PackageName    `toml:"slug,omitempty"`
+	Url      string                   `toml:"url,omitempty"`
+	Filename string                   `toml:"filename"`
+	Sha256   string                   `toml:"sha256,omitempty"`
+	Sha512   string                   `toml:"sha512,omitempty"`
+	// Dependency is set for a package that was only installed because another
+	// one requires it, so `remove --recursive` can remove it with the last
+	// package that does.
+	Dependency bool `toml:"dependency,omitempty"`
+}
+
+// ReadManifest gives an empty manifest if lucy.toml does not exist yet.
+func ReadManifest() (manifest *LucyManifest, err error) {
+	manifest = &LucyManifest{Packages: []string{}}
+	_, err = toml.DecodeFile(ManifestFile, manifest)
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// WriteManifest writes one package per line instead of using the toml encoder,
+// which puts the whole array on a single line. The packages array of an
+// existing lucy.toml is edited in place, and the rest of the file is kept.
+func WriteManifest(manifest *LucyManifest) error {
+	content, err := os.ReadFile(ManifestFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	edited := editManifest(string(content), manifest.Packages)
+	return os.WriteFile(ManifestFile, []byte(edited), 0o644)
+}
+
+// Add records a package in the manifest. If the same package is already listed
+// (with or without a version), the entry is replaced.
+func (m *LucyManifest) Add(id lucytypes.PackageId) {
+	entry := id.String()
+	for i, listed := range m.Packages {
+		if manifestEntryMatches(listed, id) {
+			m.Packages[i] = entry
+			return
+		}
+	}
+	m.Packages = append(m.Packages, entry)
+}
+
+// Has tells whether the package is listed, whatever version is requested.
+func (m *LucyManifest) Has(id lucytypes.PackageId) bool {
+	return slices.ContainsFunc(
+		m.Packages,
+		func(listed string) bool { return manifestEntryMatches(listed, id) },
+	)
+}
+
+func (m *LucyManifest) Remove(id lucytypes.PackageId) {
+	m.Packages = slices.DeleteFunc(
+		m.Packages,
+		func(listed string) bool { return manifestEntryMatches(listed, id) },
+	)
+}
+
+// manifestEntryMatches compares the platform and name of an entry, the version
+// is ignored. An entry without a platform matches any platform.
+func manifestEntryMatches(entry string, id lucytypes.PackageId) bool {
+	entry, _, _ = strings.Cut(entry, "@")
+	platform, name, found := strings.Cut(entry, "/")
+	if !found {
+		return lucytypes.PackageName(platform).Eq(id.Name)
+	}
+	return lucytypes.Platform(platform).Eq(id.Platform) &&
+		lucytypes.PackageName(name).Eq(id.Name)
+}
+
+// ReadLock gives an empty lock if lucy.lock does not exist yet.
+func ReadLock() (lock *LucyLock, err error) {
+	lock = &LucyLock{Version: lockVersion, Packages: []LockedPackage{}}
+	_, err = toml.DecodeFile(LockFile, lock)
+	if os.IsNotExist(err) {
+		return lock, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+func WriteLock(lock *LucyLock) error {
+	lock.Version = lockVersion
+	sort.Slice(
+		lock.Packages,
+		func(i, j int) bool {
+			a, b := lock.Packages[i], lock.Packages[j]
+			if a.Platform != b.Platform {
+				return a.Platform < b.Platform
+			}
+			return a.Name < b.Name
+		},
+	)
+	return writeToml(LockFile, lock)
+}
+
+// Set records a package in the lock, replacing any entry with the same
+// platform and name.
+func (l *LucyLock) Set(locked LockedPackage) {
+	for i, p := range l.Packages {
+		if p.Platform.Eq(locked.Platform) && p.Name.Eq(locked.Name) {
+			l.Packages[i] = locked
+			return
+		}
+	}
+	l.Packages = append(l.Packages, locked)
+}
+
+func (l *LucyLock) Remove(id lucytypes.PackageId) {
+	l.Packages = slices.DeleteFunc(
+		l.Packages,
+		func(p LockedPackage) bool {
+			return p.Platform.Eq(id.Platform) && p.Name.Eq(id.Name)
+		},
+	)
+}
+
+func (l *LucyLock) Get(id lucytypes.PackageId) *LockedPackage {
+	for _, p := range l.Packages {
+		if p.Platform.Eq(id.Platform) && p.Name.Eq(id.Name) {
+			return &p
+		}
+	}
+	return nil
+}
+
+// LockPackage pins a package. p.Remote can be nil for packages that were only
+// found locally, in which case the source is recorded as unknown and the hash
+// is calculated from the local file.
+func LockPackage(p lucytypes.Package) (locked LockedPackage, err error) {
+	locked = LockedPackage{
+		Platform: p.Id.Platform,
+		Name:     p.Id.Name,
+		Version:  p.Id.Version,
+		Source:   lucytypes.UnknownSource.String(),
+	}
+	if p.Remote != nil {
+		locked.Source = p.Remote.Source.String()
+		locked.RemoteId = p.Remote.RemoteId
+		locked.Slug = p.Remote.Slug
+		locked.Url = p.Remote.FileUrl
+		locked.Filename = p.Remote.Filename
+		locked.Sha256 = p.Remote.Sha256
+		locked.Sha512 = p.Remote.Sha512
+	}
+	if p.Local != nil {
+		if locked.Filename == "" {
+			locked.Filename = path.Base(p.Local.Path)
+		}
+		if locked.Sha512 == "" {
+			locked.Sha512, err = FileSha512(p.Local.Path)
+			if err != nil {
+				return locked, err
+			}
+		}
+	}
+	return locked, nil
+}
+
+func (l LockedPackage) Id() lucytypes.PackageId {
+	return lucytypes.PackageId{
+		Platform: l.Platform,
+		Name:     l.Name,
+		Version:  l.Version,
+	}
+}
+
+func (l LockedPackage) Remote() *lucytypes.PackageRemote {
+	return &lucytypes.PackageRemote{
+		Source:   lucytypes.ParseSource(l.Source),
+		RemoteId: l.RemoteId,
+		Slug:     l.Slug,
+		FileUrl:  l.Url,
+		Filename: l.Filename,
+		Sha256:   l.Sha256,
+		Sha512:   l.Sha512,
+	}
+}
+
+func writeToml(filename string, v any) error {
+	buf := &bytes.Buffer{}
+	encoder := toml.NewEncoder(buf)
+	encoder.Indent = ""
+	if err := encoder.Encode(v); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, buf.Bytes(), 0o644)
+}
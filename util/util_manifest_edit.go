@@ -0,0 +1,207 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// The packages array of lucy.toml is edited in place rather than regenerated,
+// so that comments and keys lucy does not know of are kept. Only lines of the
+// array that hold entries are rewritten, one entry per line. Comments on their
+// own line stay where they are, and a comment after an entry stays with it as
+// long as the entry is not removed.
+
+var packagesKeyPattern = regexp.MustCompile(`^[ \t]*packages[ \t]*=[ \t]*\[`)
+
+// editManifest gives the content of lucy.toml with its packages array set to
+// entries. An array is added before the first table if there is none.
+func editManifest(content string, entries []string) string {
+	start, end, found := findPackagesArray(content)
+	if !found {
+		array := "packages = [\n" + formatEntries(entries, nil) + "]\n"
+		at := firstTable(content)
+		if at == len(content) && content != "" && !strings.HasSuffix(content, "\n") {
+			array = "\n" + array
+		}
+		return content[:at] + array + content[at:]
+	}
+	body := content[start:end]
+	return content[:start] + "\n" + formatEntries(entries, manifestLines(body)) + content[end:]
+}
+
+// manifestLine is a line of the packages array.
+type manifestLine struct {
+	entries []string
+	comment string
+	// raw is the whole line, kept as is if it holds no entry.
+	raw string
+}
+
+// formatEntries lays entries out after the old lines of the array. An old
+// entry is replaced by the new entry of the same package, which keeps the
+// order and comments of the file, and new entries are added at the end.
+func formatEntries(entries []string, old []manifestLine) string {
+	used := make([]bool, len(entries))
+	sb := strings.Builder{}
+	for _, line := range old {
+		if len(line.entries) == 0 {
+			if strings.TrimSpace(line.raw) != "" {
+				sb.WriteString(line.raw + "\n")
+			}
+			continue
+		}
+		var kept []string
+		for _, entry := range line.entries {
+			for i, e := range entries {
+				if !used[i] && entryKey(e) == entryKey(entry) {
+					used[i] = true
+					kept = append(kept, e)
+					break
+				}
+			}
+		}
+		for i, entry := range kept {
+			sb.WriteString("  " + tomlString(entry) + ",")
+			if i == len(kept)-1 && line.comment != "" {
+				sb.WriteString(" " + line.comment)
+			}
+			sb.WriteString("\n")
+		}
+	}
+	for i, entry := range entries {
+		if !used[i] {
+			sb.WriteString("  " + tomlString(entry) + ",\n")
+		}
+	}
+	return sb.String()
+}
+
+// entryKey is the platform and name of an entry, which identify the package.
+func entryKey(entry string) string {
+	key, _, _ := strings.Cut(entry, "@")
+	return strings.ToLower(key)
+}
+
+// findPackagesArray gives the bounds of the inside of the packages array, not
+// including the brackets.
+func findPackagesArray(content string) (start, end int, found bool) {
+	offset := 0
+	for _, line := range strings.SplitAfter(content, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "[") {
+			break // keys after a table header are not top-level
+		}
+		if loc := packagesKeyPattern.FindStringIndex(line); loc != nil {
+			start = offset + loc[1]
+			end = scanArray(content, start)
+			return start, end, end >= 0
+		}
+		offset += len(line)
+	}
+	return 0, 0, false
+}
+
+// scanArray gives the index of the bracket that closes the array starting at
+// i, or -1 if it is not closed. Brackets in strings and comments are skipped.
+func scanArray(content string, i int) int {
+	for ; i < len(content); i++ {
+		switch content[i] {
+		case ']':
+			return i
+		case '"', '\'':
+			i = skipString(content, i)
+		case '#':
+			for i < len(content) && content[i] != '\n' {
+				i++
+			}
+		}
+	}
+	return -1
+}
+
+// skipString gives the index of the quote that closes the string starting at
+// i. Only basic strings have escapes.
+func skipString(content string, i int) int {
+	quote := content[i]
+	for i++; i < len(content); i++ {
+		switch {
+		case quote == '"' && content[i] == '\\':
+			i++
+		case content[i] == quote, content[i] == '\n':
+			return i
+		}
+	}
+	return i
+}
+
+// manifestLines splits the inside of the packages array into lines, and finds
+// the entries and comment of each.
+func manifestLines(body string) (lines []manifestLine) {
+	for _, raw := range strings.Split(body, "\n") {
+		line := manifestLine{raw: raw}
+		for i := 0; i < len(raw); i++ {
+			switch raw[i] {
+			case '"', '\'':
+				j := skipString(raw, i)
+				if entry, ok := decodeTomlString(raw[i:min(j+1, len(raw))]); ok {
+					line.entries = append(line.entries, entry)
+				}
+				i = j
+			case '#':
+				line.comment = raw[i:]
+				i = len(raw)
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func decodeTomlString(s string) (string, bool) {
+	var v struct{ S string }
+	if _, err := toml.Decode("S = "+s, &v); err != nil {
+		return "", false
+	}
+	return v.S, true
+}
+
+// tomlString quotes s with the toml encoder, the escapes of Go are not all
+// valid in toml.
+func tomlString(s string) string {
+	buf := &bytes.Buffer{}
+	if err := toml.NewEncoder(buf).Encode(map[string]string{"s": s}); err != nil {
+		return `""`
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(buf.String(), "s = "), "\n")
+}
+
+// firstTable gives the index of the first table header, or the end of content
+// if there is none.
+func firstTable(content string) int {
+	offset := 0
+	for _, line := range strings.SplitAfter(content, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "[") {
+			return offset
+		}
+		offset += len(line)
+	}
+	return len(content)
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"testing"
+
+	"github.com/BurntSushi/toml"
+
+	"lucy/lucytypes"
+	"lucy/testutil"
+)
+
+func TestEditManifest(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		entries []string
+		want    string
+	}{
+		{
+			name:    "new file",
+			content: "",
+			entries: []string{"fabric/lithium", "mcdr/prime-backup@^1"},
+			want:    "packages = [\n  \"fabric/lithium\",\n  \"mcdr/prime-backup@^1\",\n]\n",
+		},
+		{
+			name: "comments and other keys are kept",
+			content: "# my server\n" +
+				"name = \"survival\"\n" +
+				"packages = [\n" +
+				"  # performance\n" +
+				"  \"fabric/lithium\", # faster ticks\n" +
+				"  \"fabric/carpet@1.4.161\",\n" +
+				"]\n" +
+				"\n" +
+				"[notes]\n" +
+				"text = \"keep me\"\n",
+			entries: []string{"fabric/lithium@0.11.2", "fabric/jade"},
+			want: "# my server\n" +
+				"name = \"survival\"\n" +
+				"packages = [\n" +
+				"  # performance\n" +
+				"  \"fabric/lithium@0.11.2\", # faster ticks\n" +
+				"  \"fabric/jade\",\n" +
+				"]\n" +
+				"\n" +
+				"[notes]\n" +
+				"text = \"keep me\"\n",
+		},
+		{
+			name:    "single line array",
+			content: "packages = [\"fabric/lithium\", 'fabric/carpet']\n",
+			entries: []string{"fabric/carpet"},
+			want:    "packages = [\n  \"fabric/carpet\",\n]\n",
+		},
+		{
+			name:    "brackets in comments and strings",
+			content: "packages = [\n  \"odd]name\", # [x]\n]\n",
+			entries: []string{"odd]name"},
+			want:    "packages = [\n  \"odd]name\", # [x]\n]\n",
+		},
+		{
+			name:    "array added before tables",
+			content: "[notes]\ntext = \"hi\"\n",
+			entries: []string{"fabric/lithium"},
+			want:    "packages = [\n  \"fabric/lithium\",\n]\n[notes]\ntext = \"hi\"\n",
+		},
+		{
+			name:    "packages in a table is not the manifest",
+			content: "[other]\npackages = [\"x\"]\n",
+			entries: []string{"fabric/lithium"},
+			want:    "packages = [\n  \"fabric/lithium\",\n]\n[other]\npackages = [\"x\"]\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := editManifest(tt.content, tt.entries)
+			if got != tt.want {
+				t.Errorf("editManifest() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditManifestIsValidToml(t *testing.T) {
+	entries := []string{"fabric/\u0007bell", "fabric/quote\"d", "fabric/\U0001F600", "fabric/tab\tx"}
+	got := editManifest("", entries)
+	manifest := LucyManifest{}
+	if _, err := toml.Decode(got, &manifest); err != nil {
+		t.Fatalf("invalid toml %q: %v", got, err)
+	}
+	if len(manifest.Packages) != len(entries) {
+		t.Fatalf("decoded %v, want %v", manifest.Packages, entries)
+	}
+	for i := range entries {
+		if manifest.Packages[i] != entries[i] {
+			t.Errorf("entry %d = %q, want %q", i, manifest.Packages[i], entries[i])
+		}
+	}
+}
+
+func TestLockKeepsSlug(t *testing.T) {
+	testutil.ServerDir(t)
+	if err := InstallLucy(); err != nil {
+		t.Fatal(err)
+	}
+	id := lucytypes.PackageId{Platform: lucytypes.Fabric, Name: "fabric", Version: "0.92.2"}
+	locked, err := LockPackage(
+		lucytypes.Package{
+			Id: id,
+			Remote: &lucytypes.PackageRemote{
+				Source:   lucytypes.Modrinth,
+				RemoteId: "P7dR8mSH",
+				Slug:     "fabric-api",
+				FileUrl:  "https://cdn.modrinth.com/fabric-api.jar",
+				Filename: "fabric-api.jar",
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteLock(&LucyLock{Packages: []LockedPackage{locked}}); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := ReadLock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := lock.Get(id)
+	if got == nil {
+		t.Fatalf("%s is not in the lock", id.FullString())
+	}
+	if slug := got.Remote().Slug; slug != "fabric-api" {
+		t.Errorf("slug = %q, want %q", slug, "fabric-api")
+	}
+}
@@ -17,48 +17,70 @@ limitations under the License.
 package util
 
 import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path"
-	"strconv"
-	"sync"
+	"strings"
 
 	"github.com/schollz/progressbar/v3"
 	"golang.org/x/term"
 
+	"lucy/logger"
 	"lucy/lucyerrors"
+	"lucy/lucytypes"
 	"lucy/tools"
 )
 
 // DownloadFile
-// All downloaded files are stored in .lucy/downloads/{subdir}/{filename}
-// Current policy for path is the slug of the package
+// All downloaded files are stored in .lucy/downloads/{subdir}/ under a temporary
+// name, use Transaction.Install to move it into the server with remote.Filename.
+// Current policy for path is the slug of the package. A remote with a LocalCopy
+// is copied from it instead, see openRemoteFile.
+//
+// The file is hashed while it is written, and synced to disk before returning. If remote carries a size or digest
+// and the downloaded file does not match, the file is deleted and an error
+// wrapping lucyerrors.ChecksumMismatchError is returned.
 func DownloadFile(
-	url string,
+	remote *lucytypes.PackageRemote,
 	subdir string,
-	filename string,
 ) (out *os.File, err error) {
 	if _, err := os.Stat(ProgramPath); os.IsNotExist(err) {
 		return nil, lucyerrors.NoLucyError
 	}
 
-	out, err = os.Create(path.Join(DownloadPath, subdir, filename))
-	if os.IsNotExist(err) {
-		os.MkdirAll(path.Join(DownloadPath, subdir), os.ModePerm)
-		out, _ = os.Create(path.Join(DownloadPath, subdir, filename))
+	body, length, err := openRemoteFile(remote)
+	if err != nil {
+		return nil, err
 	}
-	defer out.Close()
+	defer tools.CloseReader(body, func(error) {})
 
-	res, err := http.Get(url)
-	defer res.Body.Close()
-
-	fmt.Println("Downloading", url)
+	err = os.MkdirAll(path.Join(DownloadPath, subdir), 0o755)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.CreateTemp(
+		path.Join(DownloadPath, subdir),
+		remote.Filename+".*.part",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+		if err != nil {
+			_ = os.Remove(file.Name())
+		}
+	}()
 
 	termWidth, _, _ := term.GetSize(int(os.Stdout.Fd()))
 	bar := progressbar.NewOptions64(
-		res.ContentLength,
+		length,
 		progressbar.OptionShowCount(),
 		progressbar.OptionShowElapsedTimeOnFinish(),
 		progressbar.OptionEnableColorCodes(true),
@@ -80,98 +102,112 @@ func DownloadFile(
 			),
 		),
 	)
-	writer := io.MultiWriter(out, bar)
-	io.Copy(writer, res.Body)
+	sha1Hash, sha256Hash, sha512Hash := sha1.New(), sha256.New(), sha512.New()
+	writer := io.MultiWriter(file, bar, sha1Hash, sha256Hash, sha512Hash)
+	size, err := io.Copy(writer, body)
 	fmt.Println()
+	if err != nil {
+		return nil, err
+	}
+	err = file.Sync()
+	if err != nil {
+		return nil, err
+	}
 
-	return out, err
+	err = verifyDownload(
+		remote,
+		size,
+		hex.EncodeToString(sha1Hash.Sum(nil)),
+		hex.EncodeToString(sha256Hash.Sum(nil)),
+		hex.EncodeToString(sha512Hash.Sum(nil)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return file, nil
 }
 
-// MultiSourceDownload expects the urls hosts the same file. However, it does
-// not verify the checksums to allow more loose file recognition policies in its
-// callers.
-//
-// Download is concurrent. Other threads will be cancelled when one thread
-// complete downloaded winThreshold of the file.
-//
-// Note that if the urls' speed are close, urls[0] will be selected since its
-// goroutine is started first.
-//
-// Pros:
-//   - Guaranteed to download the file from the fastest source.
-//
-// Cons:
-//   - Wastes bandwidth
-func MultiSourceDownload(urls []string, path string) {
-	const winThreshold = 0.2 // 20% of the file
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-	var win bool
-	var data *[]byte
-	var winUrl string
-
-	for _, url := range urls {
-		wg.Add(1)
-		go func(url string) {
-			defer wg.Done()
-			resp, err := http.Get(url)
+// openRemoteFile gives the content of the file at remote.FileUrl, and its length,
+// -1 if unknown. A LocalCopy of the file is read instead, if it still exists.
+func openRemoteFile(remote *lucytypes.PackageRemote) (
+	body io.ReadCloser,
+	length int64,
+	err error,
+) {
+	if remote.LocalCopy != "" {
+		file, err := os.Open(remote.LocalCopy)
+		if err == nil {
+			fmt.Println("Copying", remote.LocalCopy)
+			stat, err := file.Stat()
 			if err != nil {
-				return
-			}
-			defer resp.Body.Close()
-
-			// TODO: totalSize might be -1 when size it not known, handle this case
-			totalSize := resp.ContentLength
-			thresholdSize := int64(float64(totalSize) * winThreshold)
-			buffer := make([]byte, 2048)
-			var downloadedSize int64
-
-			for {
-				n, err := resp.Body.Read(buffer)
-				if err != nil && err != io.EOF {
-					return
-				}
-				if n == 0 {
-					break
-				}
-				downloadedSize += int64(n)
-				if win && winUrl != url {
-					println(
-						"canceling:",
-						url,
-						"("+strconv.FormatInt(downloadedSize, 10)+"/"+
-							strconv.FormatInt(totalSize, 10), "bytes)",
-					)
-					return
-				}
-				if downloadedSize >= thresholdSize {
-					mu.Lock()
-					if !win {
-						println(
-							"winning:",
-							url,
-							"("+strconv.FormatInt(downloadedSize, 10)+"/"+
-								strconv.FormatInt(totalSize, 10), "bytes)",
-						)
-						win = true
-						data = &buffer
-						winUrl = url
-					}
-					mu.Unlock()
-				}
+				_ = file.Close()
+				return nil, 0, err
 			}
-		}(url)
+			return file, stat.Size(), nil
+		}
+		logger.Debug("no local copy of " + remote.Filename + ", downloading it: " + err.Error())
 	}
 
-	wg.Wait()
-	println("winning url: ", winUrl)
-
-	file, _ := os.Create(path)
-	defer file.Close()
-	_, err := file.Write(*data)
+	res, err := http.Get(remote.FileUrl)
 	if err != nil {
-		panic(err)
+		return nil, 0, err
 	}
+	if err := lucyerrors.CheckResponse(res); err != nil {
+		_ = res.Body.Close()
+		return nil, 0, err
+	}
+	fmt.Println("Downloading", remote.FileUrl)
+	return res.Body, res.ContentLength, nil
+}
 
-	println("Downloaded to", path)
+// verifyDownload compares whatever remote provides. A remote with no size or
+// digest at all cannot be verified, which is only logged.
+func verifyDownload(
+	remote *lucytypes.PackageRemote,
+	size int64,
+	sha1Digest, sha256Digest, sha512Digest string,
+) error {
+	if remote.Size != 0 && remote.Size != size {
+		return fmt.Errorf(
+			"%w: %s is %d bytes, expected %d",
+			lucyerrors.ChecksumMismatchError,
+			remote.Filename,
+			size,
+			remote.Size,
+		)
+	}
+	if remote.Sha512 != "" && !strings.EqualFold(remote.Sha512, sha512Digest) {
+		return fmt.Errorf(
+			"%w: sha512 of %s is %s, expected %s",
+			lucyerrors.ChecksumMismatchError,
+			remote.Filename,
+			sha512Digest,
+			remote.Sha512,
+		)
+	}
+	if remote.Sha256 != "" && !strings.EqualFold(remote.Sha256, sha256Digest) {
+		return fmt.Errorf(
+			"%w: sha256 of %s is %s, expected %s",
+			lucyerrors.ChecksumMismatchError,
+			remote.Filename,
+			sha256Digest,
+			remote.Sha256,
+		)
+	}
+	if remote.Sha1 != "" && !strings.EqualFold(remote.Sha1, sha1Digest) {
+		return fmt.Errorf(
+			"%w: sha1 of %s is %s, expected %s",
+			lucyerrors.ChecksumMismatchError,
+			remote.Filename,
+			sha1Digest,
+			remote.Sha1,
+		)
+	}
+	if remote.Sha512 == "" && remote.Sha256 == "" && remote.Sha1 == "" {
+		logger.Warning(
+			fmt.Errorf("no checksum available for %s, it is not verified", remote.Filename),
+		)
+	}
+	return nil
 }
@@ -0,0 +1,236 @@
+/*
+Copyright 2024 4rcadia
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"sync"
+	"syscall"
+
+	"lucy/logger"
+)
+
+// Transaction groups file operations in the server's directories so they either
+// all take effect or none of them do. Files that are removed or replaced are
+// moved to the trash rather than deleted, and moved back on Rollback.
+//
+// Every step is recorded in TransactionJournal before it is done. If Lucy is
+// killed in the middle of a transaction, RecoverTransaction rolls it back on
+// the next run. An interrupt (Ctrl-C) or SIGTERM rolls back immediately.
+type Transaction struct {
+	mu       sync.Mutex
+	journal  transactionJournal
+	signals  chan os.Signal
+	finished bool
+}
+
+type transactionJournal struct {
+	// Installed are files moved into the server's directories.
+	Installed []string `json:"installed"`
+	// Replaced maps the original path of a file to where it is in the trash.
+	Replaced map[string]string `json:"replaced"`
+}
+
+var ErrorTransactionFinished = errors.New("transaction already committed or rolled back")
+
+func NewTransaction() *Transaction {
+	t := &Transaction{
+		journal: transactionJournal{
+			Installed: []string{},
+			Replaced:  map[string]string{},
+		},
+		signals: make(chan os.Signal, 1),
+	}
+	signal.Notify(t.signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		_, ok := <-t.signals
+		if !ok {
+			return
+		}
+		logger.Warning(errors.New("interrupted, rolling back"))
+		if err := t.Rollback(); err != nil {
+			logger.Error(err)
+		}
+		logger.WriteAll()
+		os.Exit(130)
+	}()
+	return t
+}
+
+// Install moves a verified download to dir/filename. A file that already exists
+// at the destination is moved to the trash first.
+func (t *Transaction) Install(downloaded string, dir string, filename string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.finished {
+		return ErrorTransactionFinished
+	}
+
+	destination := path.Join(dir, filename)
+	if _, err := os.Stat(destination); err == nil {
+		if _, err := t.remove(destination); err != nil {
+			return err
+		}
+	}
+
+	t.journal.Installed = append(t.journal.Installed, destination)
+	if err := t.writeJournal(); err != nil {
+		return err
+	}
+	if err := os.Rename(downloaded, destination); err != nil {
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// Remove moves a file to the trash, and gives where it is in the trash.
+func (t *Transaction) Remove(filePath string) (trashed string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.finished {
+		return "", ErrorTransactionFinished
+	}
+	return t.remove(filePath)
+}
+
+func (t *Transaction) remove(filePath string) (trashed string, err error) {
+	trashed, err = MoveToTrash(filePath)
+	if err != nil {
+		return "", err
+	}
+	t.journal.Replaced[filePath] = trashed
+	return trashed, t.writeJournal()
+}
+
+// Commit keeps every change. Removed and replaced files stay in the trash.
+func (t *Transaction) Commit() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.finished {
+		return ErrorTransactionFinished
+	}
+	t.finish()
+	return removeJournal()
+}
+
+// Rollback deletes the installed files and moves the replaced ones back. It is
+// a no-op on a transaction that is already finished.
+func (t *Transaction) Rollback() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.finished {
+		return nil
+	}
+	t.finish()
+	err := t.journal.rollback()
+	if err != nil {
+		return err
+	}
+	return removeJournal()
+}
+
+func (t *Transaction) finish() {
+	t.finished = true
+	signal.Stop(t.signals)
+	close(t.signals)
+}
+
+func (j *transactionJournal) rollback() (err error) {
+	for i := len(j.Installed) - 1; i >= 0; i-- {
+		e := os.Remove(j.Installed[i])
+		if e != nil && !os.IsNotExist(e) {
+			err = errors.Join(err, e)
+		}
+	}
+	for original, trashed := range j.Replaced {
+		e := os.Rename(trashed, original)
+		if e != nil {
+			err = errors.Join(err, fmt.Errorf("cannot restore %s: %w", original, e))
+		}
+	}
+	return err
+}
+
+func (t *Transaction) writeJournal() error {
+	data, err := json.Marshal(t.journal)
+	if err != nil {
+		return err
+	}
+	return writeFileSync(TransactionJournal, data)
+}
+
+func removeJournal() error {
+	err := os.Remove(TransactionJournal)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// RecoverTransaction rolls back a transaction left behind by a previous run
+// that did not finish. It does nothing if there is none.
+func RecoverTransaction() error {
+	data, err := os.ReadFile(TransactionJournal)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	j := &transactionJournal{}
+	if err := json.Unmarshal(data, j); err != nil {
+		return err
+	}
+	logger.Warning(errors.New("rolling back an unfinished transaction from a previous run"))
+	if err := j.rollback(); err != nil {
+		return err
+	}
+	return removeJournal()
+}
+
+func writeFileSync(filename string, data []byte) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// syncDir flushes a directory entry change to disk. Not every platform supports
+// this, so errors are ignored.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = d.Sync()
+	_ = d.Close()
+}
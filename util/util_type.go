@@ -20,9 +20,19 @@ import (
 	"lucy/lucytypes"
 )
 
+// LucyEnvConfig is stored at ConfigFile. It records the server that was detected
+// (and selected, if there were multiple executables) when `lucy init` was run,
+// so later runs do not need to ask again.
 type LucyEnvConfig struct {
-	LastRecordedInfo lucytypes.ServerInfo
-	BootCommand      string
+	Executable    string             `json:"executable"`
+	Platform      lucytypes.Platform `json:"platform"`
+	GameVersion   string             `json:"game_version"`
+	LoaderVersion string             `json:"loader_version"`
+	ModPath       string             `json:"mod_path"`
+	PluginPaths   []string           `json:"plugin_paths"`
+	// CurseForgeApiKey is needed for the CurseForge source, since its API does
+	// not allow anonymous access.
+	CurseForgeApiKey string `json:"curseforge_api_key,omitempty"`
 }
 
 type LucyGlobalConfig struct{}